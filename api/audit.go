@@ -0,0 +1,76 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+
+	"server/domain"
+	"server/validation"
+)
+
+// GetAuditLogRequest filters the audit log of tasks. All fields come from query parameters,
+// and all of them are optional. From and To are in any form domain.ParseTime accepts, and Limit
+// is the most revisions to list.
+type GetAuditLogRequest struct {
+	TaskID string
+	User   string
+	From   string
+	To     string
+	Limit  string
+}
+
+var _ Request = &GetAuditLogRequest{}
+
+// NewGetAuditLogRequest builds a GetAuditLogRequest from url query parameters
+func NewGetAuditLogRequest(values url.Values) GetAuditLogRequest {
+	return GetAuditLogRequest{
+		TaskID: values.Get("taskId"),
+		User:   values.Get("user"),
+		From:   values.Get("from"),
+		To:     values.Get("to"),
+		Limit:  values.Get("limit"),
+	}
+}
+
+func (g *GetAuditLogRequest) String() string {
+	return fmt.Sprintf(`{"taskId":"%s", "user":"%s", "from":"%s", "to":"%s", "limit":"%s"}`, g.TaskID, g.User, g.From, g.To, g.Limit)
+}
+
+// Validate is for conforming to api.Request interface
+func (g *GetAuditLogRequest) Validate() error {
+	v := validation.Validator{}
+	v.Field("taskId", g.TaskID, validation.Min(1))
+	v.Field("from", g.From, timeRule)
+	v.Field("to", g.To, timeRule)
+	v.Field("limit", g.Limit, validation.Range(1, maxPageSize))
+	return v.Err()
+}
+
+// RestoreTaskRequest restores a task to the state it had at a revision of the audit log.
+// Without a revision, the task is only taken out of the trash.
+type RestoreTaskRequest struct {
+	Revision int64 `json:"revision"`
+}
+
+var _ Request = &RestoreTaskRequest{}
+
+func (r *RestoreTaskRequest) String() string {
+	return fmt.Sprintf(`{"revision":%d}`, r.Revision)
+}
+
+// Validate is for conforming to api.Request interface. Revision is optional.
+func (r *RestoreTaskRequest) Validate() error {
+	v := validation.Validator{}
+	v.Field("revision", r.Revision, validation.Min(0))
+	return v.Err()
+}
+
+// GetAuditLogResponse lists revisions of tasks, oldest first
+type GetAuditLogResponse struct {
+	Response `json:"response"`
+	Entries  []domain.AuditEntry `json:"entries"`
+}
+
+func (r GetAuditLogResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "entries":%v}`, r.Response.String(), r.Entries)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"server/validation"
+)
+
+// Kinds of operations of a BulkTasksRequest
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// maxBulkOperations is the most operations a bulk request can have
+const maxBulkOperations = 100
+
+// BulkTasksRequest is a list of operations on tasks, applied all together or not at all
+type BulkTasksRequest struct {
+	Operations []BulkOperation `json:"operations"`
+}
+
+// BulkOperation is one operation of a BulkTasksRequest. Op is create, update or delete.
+// Task is a CreateTaskRequest for create, and an UpdateTaskRequest of the task ID for update.
+// Delete only takes ID. Validate decodes Task into Create or Update.
+type BulkOperation struct {
+	Op     string             `json:"op"`
+	ID     int64              `json:"id"`
+	Task   json.RawMessage    `json:"task"`
+	Create *CreateTaskRequest `json:"-"`
+	Update *UpdateTaskRequest `json:"-"`
+}
+
+var _ Request = &BulkTasksRequest{}
+
+func (b *BulkTasksRequest) String() string {
+	ops := make([]string, 0, len(b.Operations))
+	for _, op := range b.Operations {
+		ops = append(ops, fmt.Sprintf(`{"op":"%s", "id":%d, "task":%s}`, op.Op, op.ID, string(op.Task)))
+	}
+	return fmt.Sprintf(`{"operations":%v}`, ops)
+}
+
+// Validate is for conforming to api.Request interface. Every operation has to be valid on its
+// own, there are 1 to 100 of them.
+func (b *BulkTasksRequest) Validate() error {
+	v := validation.Validator{}
+	if !v.Field("operations", b.Operations, validation.Required) {
+		return v.Err()
+	}
+	if len(b.Operations) > maxBulkOperations {
+		v.Add("operations", "maxLength", "Cannot have more than %d operations", maxBulkOperations)
+		return v.Err()
+	}
+	for i := range b.Operations {
+		b.Operations[i].validate(&v, fmt.Sprintf("operations[%d]", i))
+	}
+	return v.Err()
+}
+
+func (o *BulkOperation) validate(v *validation.Validator, field string) {
+	switch o.Op {
+	case BulkCreate:
+		o.Create = new(CreateTaskRequest)
+		if o.decodeTask(v, field, o.Create) {
+			v.Merge(field+".task", o.Create.Validate())
+		}
+	case BulkUpdate:
+		v.Field(field+".id", o.ID, validation.Required, validation.Min(1))
+		o.Update = new(UpdateTaskRequest)
+		if o.decodeTask(v, field, o.Update) {
+			v.Merge(field+".task", o.Update.Validate())
+		}
+	case BulkDelete:
+		v.Field(field+".id", o.ID, validation.Required, validation.Min(1))
+	default:
+		v.Field(field+".op", o.Op, validation.Required, validation.OneOf(BulkCreate, BulkUpdate, BulkDelete))
+	}
+}
+
+// decodeTask decodes the task of an operation into t, and tells if it could
+func (o *BulkOperation) decodeTask(v *validation.Validator, field string, t interface{}) bool {
+	if !v.Field(field+".task", o.Task, validation.Required) {
+		return false
+	}
+	if err := json.Unmarshal(o.Task, t); err != nil {
+		v.Add(field+".task", "json", "%s", err.Error())
+		return false
+	}
+	return true
+}
+
+// BulkTasksResponse has the result of every operation of a BulkTasksRequest, in the same order
+type BulkTasksResponse struct {
+	Response `json:"response"`
+	Results  []BulkResult `json:"results"`
+}
+
+func (r BulkTasksResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "results":%v}`, r.Response.String(), r.Results)
+}
+
+// BulkResult is the result of one operation. TaskID is the id of the created task for create.
+// Applied is only set once all operations succeeded and were committed.
+type BulkResult struct {
+	Response `json:"response"`
+	Op       string `json:"op"`
+	TaskID   int64  `json:"taskId"`
+	Applied  bool   `json:"applied"`
+}
+
+func (r BulkResult) String() string {
+	return fmt.Sprintf(`{"response": %v, "op":"%s", "taskId":%d, "applied":%v}`, r.Response.String(), r.Op, r.TaskID, r.Applied)
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"server/domain"
+	"server/ical"
+	"server/validation"
+)
+
+const (
+	feedNameMaxLength = 100
+	maxImportedTodos  = 1000
+
+	// defaultImportedPriority is the priority of imported to-dos which have none
+	defaultImportedPriority = 3
+)
+
+// CreateCalendarFeedRequest makes a calendar feed, named after the app or device it is for
+type CreateCalendarFeedRequest struct {
+	Name string `json:"name"`
+}
+
+var _ Request = &CreateCalendarFeedRequest{}
+
+func (c *CreateCalendarFeedRequest) String() string {
+	return fmt.Sprintf(`{"name":"%s"}`, c.Name)
+}
+
+// Validate is for conforming to api.Request interface. Name is compulsory.
+func (c *CreateCalendarFeedRequest) Validate() error {
+	v := validation.Validator{}
+	v.Field("name", c.Name, validation.Required, validation.MaxLength(feedNameMaxLength))
+	return v.Err()
+}
+
+// GetCalendarRequest reads the calendar feed of Token. Filter takes the filters of
+// GetTasksRequest, from query parameters, and tasks which are done are always left out.
+type GetCalendarRequest struct {
+	Token  string
+	Filter GetTasksRequest
+}
+
+var _ Request = &GetCalendarRequest{}
+
+// NewGetCalendarRequest builds a GetCalendarRequest from url query parameters. The feed has every
+// matching task, so sorting and pagination parameters are ignored.
+func NewGetCalendarRequest(values url.Values) GetCalendarRequest {
+	filter := NewGetTasksRequest(values)
+	filter.Sort, filter.Order, filter.Cursor, filter.Limit = "", "", "", ""
+	return GetCalendarRequest{Token: values.Get("token"), Filter: filter}
+}
+
+// String leaves the token out, since requests are logged
+func (g *GetCalendarRequest) String() string {
+	return fmt.Sprintf(`{"filter":%s}`, g.Filter.String())
+}
+
+// Validate is for conforming to api.Request interface. Token is compulsory.
+func (g *GetCalendarRequest) Validate() error {
+	v := validation.Validator{}
+	v.Field("token", g.Token, validation.Required)
+	if errs, ok := g.Filter.Validate().(validation.Errors); ok {
+		for _, fe := range errs {
+			v.Add(fe.Field, fe.Rule, "%s", fe.Message)
+		}
+	}
+	return v.Err()
+}
+
+// ImportCalendarRequest creates a task for each to-do of a calendar file. Validate decodes Todos
+// into Tasks, leaving out the to-dos which are completed or cancelled, and UIDs has the uids of
+// the to-dos of Tasks, in the same order. The to-dos which have no due date are left out too,
+// and listed in Skipped.
+type ImportCalendarRequest struct {
+	Todos   []ical.Todo
+	Tasks   []CreateTaskRequest
+	UIDs    []string
+	Skipped []SkippedTodo
+}
+
+// SkippedTodo is a to-do which was left out of an import, by its index in the calendar, with
+// the reason why
+type SkippedTodo struct {
+	Index  int    `json:"index"`
+	UID    string `json:"uid"`
+	Reason string `json:"reason"`
+}
+
+var _ Request = &ImportCalendarRequest{}
+
+func (i *ImportCalendarRequest) String() string {
+	return fmt.Sprintf(`{"todos":%d}`, len(i.Todos))
+}
+
+// Validate is for conforming to api.Request interface. Each to-do has to make a valid task, but
+// its due date, or its start when it has no due date, can be in the past: calendars keep the
+// to-dos which are overdue. To-dos without a priority get the middle one.
+func (i *ImportCalendarRequest) Validate() error {
+	v := validation.Validator{}
+	if !v.Field("todos", i.Todos, validation.Required) {
+		return v.Err()
+	}
+	if len(i.Todos) > maxImportedTodos {
+		v.Add("todos", "maxLength", "Cannot have more than %d to-dos", maxImportedTodos)
+		return v.Err()
+	}
+	i.Tasks = make([]CreateTaskRequest, 0, len(i.Todos))
+	i.UIDs = make([]string, 0, len(i.Todos))
+	i.Skipped = make([]SkippedTodo, 0)
+	for n, todo := range i.Todos {
+		if todo.Status == ical.StatusCompleted || todo.Status == ical.StatusCancelled {
+			continue
+		}
+		if todo.Due.IsZero() {
+			i.Skipped = append(i.Skipped, SkippedTodo{Index: n, UID: todo.UID, Reason: "Has no due date nor start"})
+			continue
+		}
+		create := CreateTaskRequest{
+			Title:       todo.Summary,
+			Description: todo.Description,
+			DueDate:     todo.Due.Format(time.RFC3339),
+			Priority:    uint8(ical.TaskPriority(todo.Priority)),
+			Tags:        todo.Categories,
+		}
+		if create.Priority == 0 {
+			create.Priority = defaultImportedPriority
+		}
+		todoV := validation.Validator{}
+		create.validate(&todoV)
+		v.Merge(fmt.Sprintf("todos[%d]", n), todoV.Err())
+		i.Tasks = append(i.Tasks, create)
+		i.UIDs = append(i.UIDs, todo.UID)
+	}
+	return v.Err()
+}
+
+// ImportCalendarResponse has the results of the bulk operations a calendar was imported with,
+// which are not in the order of its to-dos, and the to-dos which were left out
+type ImportCalendarResponse struct {
+	BulkTasksResponse
+	Skipped []SkippedTodo `json:"skipped"`
+}
+
+func (r ImportCalendarResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "results":%v, "skipped":%v}`, r.Response.String(), r.Results, r.Skipped)
+}
+
+// CreateCalendarFeedResponse has the id of the new calendar feed, and the token it is read with.
+// The token is never shown again.
+type CreateCalendarFeedResponse struct {
+	Response `json:"response"`
+	FeedID   int64  `json:"feedId"`
+	Token    string `json:"token"`
+}
+
+func (r CreateCalendarFeedResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "feedId": %d}`, r.Response.String(), r.FeedID)
+}
+
+// GetCalendarFeedsResponse lists the calendar feeds, by id
+type GetCalendarFeedsResponse struct {
+	Response `json:"response"`
+	Feeds    []domain.CalendarFeed `json:"feeds"`
+}
+
+func (r GetCalendarFeedsResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "feeds":%v}`, r.Response.String(), r.Feeds)
+}
+
+// GetCalendarResponse has the tasks of a calendar feed, by due date
+type GetCalendarResponse struct {
+	Response `json:"response"`
+	Feed     domain.CalendarFeed `json:"feed"`
+	Tasks    []domain.Task       `json:"tasks"`
+}
+
+func (r GetCalendarResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "feed":%d, "tasks":%d}`, r.Response.String(), r.Feed.Rowid, len(r.Tasks))
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"context"
+)
+
+type contextKey string
+
+const usernameKey contextKey = "username"
+
+// WithUsername returns a copy of ctx carrying the username of the acting user
+func WithUsername(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, usernameKey, username)
+}
+
+// Username returns the username put in ctx by WithUsername, or an empty string
+func Username(ctx context.Context) string {
+	username, _ := ctx.Value(usernameKey).(string)
+	return username
+}
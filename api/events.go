@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"server/domain"
+	"server/validation"
+)
+
+// StreamedEvents are the events of tasks which are streamed. Status changes come as updates.
+var StreamedEvents = []domain.TaskEvent{domain.EventTaskCreated, domain.EventTaskUpdated, domain.EventTaskDeleted}
+
+// GetEventsRequest filters the event stream of tasks. Every field comes from query parameters,
+// and is optional: Events to some types of events, TaskID to the events of a task and Tag to the
+// events of tasks with the tag. LastEventID resumes the stream after the event with that id, it
+// is also read from the Last-Event-ID header browsers send when they reconnect.
+type GetEventsRequest struct {
+	Events      []string
+	TaskID      string
+	Tag         string
+	LastEventID string
+}
+
+var _ Request = &GetEventsRequest{}
+
+// NewGetEventsRequest builds a GetEventsRequest from url query parameters, and the Last-Event-ID
+// header, which wins over the lastEventId parameter.
+func NewGetEventsRequest(values url.Values, lastEventID string) GetEventsRequest {
+	if lastEventID == "" {
+		lastEventID = values.Get("lastEventId")
+	}
+	return GetEventsRequest{Events: values["event"], TaskID: values.Get("taskId"), Tag: values.Get("tag"), LastEventID: lastEventID}
+}
+
+func (g *GetEventsRequest) String() string {
+	return fmt.Sprintf(`{"event":%q, "taskId":"%s", "tag":"%s", "lastEventId":"%s"}`, g.Events, g.TaskID, g.Tag, g.LastEventID)
+}
+
+// Validate is for conforming to api.Request interface. Tag is normalized like tags of tasks.
+func (g *GetEventsRequest) Validate() error {
+	v := validation.Validator{}
+	events := make([]string, 0, len(StreamedEvents))
+	for _, e := range StreamedEvents {
+		events = append(events, string(e))
+	}
+	for i, e := range g.Events {
+		v.Field(fmt.Sprintf("event[%d]", i), e, validation.Required, validation.OneOf(events...))
+	}
+	v.Field("taskId", g.TaskID, validation.Min(1))
+	g.Tag = strings.ToLower(strings.TrimSpace(g.Tag))
+	v.Field("tag", g.Tag, validation.MaxLength(tagMaxLength))
+	v.Field("lastEventId", g.LastEventID, validation.Min(0))
+	return v.Err()
+}
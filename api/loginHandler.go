@@ -49,6 +49,17 @@ func IsLoggedIn(r *http.Request) bool {
 	return false
 }
 
+// SessionUsername returns the username stored by LoginFunc in an active session,
+// or an empty string if the user is not logged in
+func SessionUsername(r *http.Request) string {
+	if !IsLoggedIn(r) {
+		return ""
+	}
+	session, _ := store.Get(r, "session")
+	username, _ := session.Values["username"].(string)
+	return username
+}
+
 //LogoutFunc handles "/logout"
 func LogoutFunc(w http.ResponseWriter, r *http.Request) {
 	if IsLoggedIn(r) {
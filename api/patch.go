@@ -0,0 +1,253 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"server/domain"
+	"server/validation"
+)
+
+// Media types of the patch documents understood by PatchTaskRequest
+const (
+	// MergePatchType is a RFC 7396 merge patch. A null value clears a field.
+	MergePatchType = "application/merge-patch+json"
+	// JSONPatchType is a RFC 6902 json patch. Removing a field clears it.
+	JSONPatchType = "application/json-patch+json"
+)
+
+// TaskDocument is the json form of the editable fields of a task. Patches are applied on it.
+type TaskDocument struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	DueDate     string   `json:"dueDate"`
+	Priority    uint8    `json:"priority"`
+	Effort      string   `json:"effort"`
+	Status      string   `json:"status"`
+	ParentID    *int64   `json:"parentId"`
+	Recurrence  *string  `json:"recurrence"`
+	Reminders   []string `json:"reminders"`
+}
+
+// NewTaskDocument builds a TaskDocument from a task
+func NewTaskDocument(t domain.Task) TaskDocument {
+	var recurrence *string
+	if t.Recurrence != nil {
+		rule := t.Recurrence.String()
+		recurrence = &rule
+	}
+	reminders := make([]string, 0, len(t.Reminders))
+	for _, r := range t.Reminders {
+		reminders = append(reminders, r.String())
+	}
+	return TaskDocument{
+		Title:       t.Title,
+		Description: t.Description,
+		DueDate:     t.DueDate.Display(),
+		Priority:    uint8(t.Priority),
+		Effort:      t.Effort.String(),
+		Status:      string(t.Status),
+		ParentID:    t.ParentID,
+		Recurrence:  recurrence,
+		Reminders:   reminders,
+	}
+}
+
+// Validate checks a patched document with the same rules as CreateTaskRequest.Validate.
+// DueDate only has to be in future if the patch changed it, so that old tasks can still be edited.
+// A cleared effort goes back to the default of 24h.
+func (d *TaskDocument) Validate(original TaskDocument) error {
+	v := validation.Validator{}
+	v.Field("title", d.Title, validation.Required, validation.MaxLength(titleMaxLength))
+	v.Field("priority", d.Priority, validation.Required, priorityRule)
+	if d.DueDate != original.DueDate {
+		v.Field("dueDate", d.DueDate, validation.Required, timeRule, futureRule)
+	} else {
+		v.Field("dueDate", d.DueDate, validation.Required, timeRule)
+	}
+	v.Field("effort", d.Effort, validation.Duration)
+	if d.Effort == "" {
+		d.Effort = "24h"
+	}
+	if d.Recurrence != nil {
+		v.Field("recurrence", *d.Recurrence, recurrenceRule)
+	}
+	v.Field("status", d.Status, validation.Required, statusRule)
+	checkReminders(&v, "reminders", d.Reminders)
+	return v.Err()
+}
+
+// PatchTaskRequest is a partial update of a task, either as a merge patch or a json patch,
+// depending on ContentType.
+type PatchTaskRequest struct {
+	ContentType string
+	Patch       json.RawMessage
+}
+
+var _ Request = &PatchTaskRequest{}
+
+func (p *PatchTaskRequest) String() string {
+	return fmt.Sprintf(`{"contentType":"%s", "patch":%s}`, p.ContentType, string(p.Patch))
+}
+
+// Validate checks that the patch is well formed for its content type. Whether it can be
+// applied is only known once it is applied on a task.
+func (p *PatchTaskRequest) Validate() error {
+	v := validation.Validator{}
+	switch p.ContentType {
+	case MergePatchType:
+		var patch map[string]interface{}
+		if err := json.Unmarshal(p.Patch, &patch); err != nil {
+			v.Add("patch", "object", "Merge patch has to be a json object")
+		}
+	case JSONPatchType:
+		var ops []patchOperation
+		if err := json.Unmarshal(p.Patch, &ops); err != nil {
+			v.Add("patch", "array", "Json patch has to be an array of operations")
+		}
+		for i, op := range ops {
+			if err := op.validate(); err != nil {
+				v.Add(fmt.Sprintf("patch[%d]", i), "operation", "%s", err.Error())
+			}
+		}
+	default:
+		v.Add("contentType", "oneOf", "Unsupported patch type %s, use %s or %s", p.ContentType, MergePatchType, JSONPatchType)
+	}
+	return v.Err()
+}
+
+// Apply applies the patch on a document, and returns the patched document. Fields which
+// are removed by the patch come back with their zero value.
+func (p *PatchTaskRequest) Apply(doc TaskDocument) (TaskDocument, error) {
+	var target map[string]interface{}
+	j, _ := json.Marshal(doc)
+	json.Unmarshal(j, &target)
+
+	var patched interface{}
+	switch p.ContentType {
+	case MergePatchType:
+		var patch interface{}
+		if err := json.Unmarshal(p.Patch, &patch); err != nil {
+			return doc, err
+		}
+		patched = mergePatch(target, patch)
+	case JSONPatchType:
+		var ops []patchOperation
+		if err := json.Unmarshal(p.Patch, &ops); err != nil {
+			return doc, err
+		}
+		for _, op := range ops {
+			if err := op.apply(target); err != nil {
+				return doc, err
+			}
+		}
+		patched = target
+	default:
+		return doc, fmt.Errorf("Unsupported patch type %s", p.ContentType)
+	}
+
+	j, _ = json.Marshal(patched)
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.DisallowUnknownFields()
+	var result TaskDocument
+	if err := decoder.Decode(&result); err != nil {
+		return doc, fmt.Errorf("Patched task is invalid: %s", err.Error())
+	}
+	return result, nil
+}
+
+// mergePatch implements the MergePatch function of RFC 7396
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+// patchOperation is one operation of a RFC 6902 json patch. A task document is a
+// flat object, so only paths of the form "/field" are supported.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from"`
+	Value interface{} `json:"value"`
+}
+
+func (op patchOperation) validate() error {
+	switch op.Op {
+	case "add", "remove", "replace", "test":
+	case "move", "copy":
+		if _, err := patchField(op.From); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown json patch operation %q", op.Op)
+	}
+	_, err := patchField(op.Path)
+	return err
+}
+
+func (op patchOperation) apply(doc map[string]interface{}) error {
+	field, err := patchField(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case "add":
+		doc[field] = op.Value
+	case "remove":
+		if _, ok := doc[field]; !ok {
+			return fmt.Errorf("Cannot remove %s, it does not exist", op.Path)
+		}
+		delete(doc, field)
+	case "replace":
+		if _, ok := doc[field]; !ok {
+			return fmt.Errorf("Cannot replace %s, it does not exist", op.Path)
+		}
+		doc[field] = op.Value
+	case "move", "copy":
+		from, err := patchField(op.From)
+		if err != nil {
+			return err
+		}
+		value, ok := doc[from]
+		if !ok {
+			return fmt.Errorf("Cannot %s from %s, it does not exist", op.Op, op.From)
+		}
+		if op.Op == "move" {
+			delete(doc, from)
+		}
+		doc[field] = value
+	case "test":
+		if !reflect.DeepEqual(doc[field], op.Value) {
+			return fmt.Errorf("Test failed for %s", op.Path)
+		}
+	default:
+		return fmt.Errorf("Unknown json patch operation %q", op.Op)
+	}
+	return nil
+}
+
+// patchField converts a json pointer to the name of a top level field
+func patchField(pointer string) (string, error) {
+	if !strings.HasPrefix(pointer, "/") || strings.Contains(pointer[1:], "/") {
+		return "", fmt.Errorf("Unsupported path %q, only top level fields can be patched", pointer)
+	}
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(pointer[1:]), nil
+}
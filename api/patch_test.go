@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func document() TaskDocument {
+	parentID := int64(7)
+	recurrence := "FREQ=DAILY"
+	return TaskDocument{Title: "a", DueDate: "2030-01-02T10:00:00Z", Priority: 3, Effort: "1h", Status: "Pending", ParentID: &parentID, Recurrence: &recurrence}
+}
+
+func TestMergePatch(t *testing.T) {
+	cases := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		var target, patch, want interface{}
+		json.Unmarshal([]byte(c.target), &target)
+		json.Unmarshal([]byte(c.patch), &patch)
+		json.Unmarshal([]byte(c.want), &want)
+		if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+			t.Errorf("merging %s into %s: got %v, want %s", c.patch, c.target, got, c.want)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	p := PatchTaskRequest{ContentType: MergePatchType, Patch: json.RawMessage(`{"title":"b","parentId":null,"recurrence":null,"effort":null}`)}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Apply(document())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "b" || got.ParentID != nil || got.Recurrence != nil || got.Effort != "" || got.Priority != 3 {
+		t.Errorf("got %+v, want the title changed and the parent, recurrence and effort cleared", got)
+	}
+
+	for _, patch := range []string{`{"unknown":1}`, `{"priority":"high"}`} {
+		p := PatchTaskRequest{ContentType: MergePatchType, Patch: json.RawMessage(patch)}
+		if _, err := p.Apply(document()); err == nil {
+			t.Errorf("got no error for %s", patch)
+		}
+	}
+	if err := (&PatchTaskRequest{ContentType: MergePatchType, Patch: json.RawMessage(`[]`)}).Validate(); err == nil {
+		t.Errorf("got no error for a merge patch which is not an object")
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	cases := []struct {
+		patch string
+		check func(d TaskDocument) bool
+		fails bool
+	}{
+		{`[{"op":"replace","path":"/title","value":"b"}]`, func(d TaskDocument) bool { return d.Title == "b" }, false},
+		{`[{"op":"remove","path":"/parentId"},{"op":"remove","path":"/recurrence"}]`, func(d TaskDocument) bool { return d.ParentID == nil && d.Recurrence == nil }, false},
+		{`[{"op":"test","path":"/status","value":"Pending"},{"op":"replace","path":"/status","value":"Done"}]`, func(d TaskDocument) bool { return d.Status == "Done" }, false},
+		{`[{"op":"copy","from":"/title","path":"/description"}]`, func(d TaskDocument) bool { return d.Description == "a" }, false},
+		{`[{"op":"move","from":"/title","path":"/description"}]`, func(d TaskDocument) bool { return d.Description == "a" && d.Title == "" }, false},
+		{`[{"op":"test","path":"/status","value":"Done"},{"op":"replace","path":"/title","value":"b"}]`, nil, true},
+		{`[{"op":"remove","path":"/parentId"},{"op":"remove","path":"/parentId"}]`, nil, true},
+		{`[{"op":"replace","path":"/unknown","value":1}]`, nil, true},
+		{`[{"op":"add","path":"/unknown","value":1}]`, nil, true},
+	}
+	for _, c := range cases {
+		p := PatchTaskRequest{ContentType: JSONPatchType, Patch: json.RawMessage(c.patch)}
+		if err := p.Validate(); err != nil {
+			t.Errorf("%s: %v", c.patch, err)
+			continue
+		}
+		got, err := p.Apply(document())
+		if c.fails {
+			if err == nil {
+				t.Errorf("%s: got %+v, want an error", c.patch, got)
+			}
+			continue
+		}
+		if err != nil || !c.check(got) {
+			t.Errorf("%s: got %+v, %v", c.patch, got, err)
+		}
+	}
+
+	for _, patch := range []string{`{}`, `[{"op":"increment","path":"/priority"}]`, `[{"op":"add","path":"title"}]`, `[{"op":"add","path":"/reminders/0"}]`, `[{"op":"copy","from":"","path":"/title"}]`} {
+		if err := (&PatchTaskRequest{ContentType: JSONPatchType, Patch: json.RawMessage(patch)}).Validate(); err == nil {
+			t.Errorf("got no error for %s", patch)
+		}
+	}
+	if err := (&PatchTaskRequest{ContentType: "text/plain", Patch: json.RawMessage(`{}`)}).Validate(); err == nil {
+		t.Errorf("got no error for an unsupported content type")
+	}
+}
+
+func TestPatchField(t *testing.T) {
+	if f, err := patchField("/a~1b~0c"); err != nil || f != "a/b~c" {
+		t.Errorf("got %q, %v, want escapes decoded", f, err)
+	}
+}
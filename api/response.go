@@ -1,36 +1,60 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
+
+	"server/errors"
+	"server/validation"
 )
 
-// Error is a custom error, defined to facilitate JSON marshalling
+// Error is an error of a response. Code is the code of an errors.AggError, and Field is the
+// field of the request the error is about, if any. Rule is the validation rule Field broke.
 type Error struct {
-	e string
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule,omitempty"`
 }
 
-// MarshalJSON converts error to json, which is not otherwise possible in golang
-func (v Error) MarshalJSON() ([]byte, error) {
-	return json.Marshal(v.e)
+func (v Error) Error() string {
+	return v.Message
 }
 
-func (v Error) Error() string {
-	return v.e
+// NewError converts err to an Error. Errors which are not errors.AggError are internal errors.
+func NewError(err error) Error {
+	if e, ok := err.(Error); ok {
+		return e
+	}
+	e := errors.From(err)
+	return Error{Code: e.StringCode(), Message: e.Description(), Field: e.Field}
 }
 
-// NewError creates an error
-func NewError(s string) Error {
-	return Error{s}
+// newErrors converts err to Errors. validation.Errors give an invalid argument error for
+// every broken rule.
+func newErrors(err error) []Error {
+	fieldErrors, ok := err.(validation.Errors)
+	if !ok {
+		return []Error{NewError(err)}
+	}
+	errs := make([]Error, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		errs = append(errs, Error{
+			Code:    errors.ErrorInvalidArgument.StringCode(),
+			Message: fe.Message,
+			Field:   fe.Field,
+			Rule:    fe.Rule,
+		})
+	}
+	return errs
 }
 
-// NewErrors returns a list of errors from a list of string
+// NewErrors returns a list of internal errors from a list of string
 func NewErrors(s ...string) []Error {
-	errors := make([]Error, 0)
+	errs := make([]Error, 0)
 	for _, e := range s {
-		errors = append(errors, Error{e})
+		errs = append(errs, NewError(errors.ErrorInternal.WithMessage("%s", e)))
 	}
-	return errors
+	return errs
 }
 
 // Response is the standard API response interface
@@ -49,14 +73,12 @@ type StdResponse struct {
 
 // AddError adds an error to response
 func (r *StdResponse) AddError(err error) {
-	errors := append(r.Errors, Error{err.Error()})
-	r.Errors = errors
+	r.Errors = append(r.Errors, newErrors(err)...)
 }
 
 // AddNewError adds a string error to response
 func (r *StdResponse) AddNewError(err string) {
-	errors := append(r.Errors, Error{err})
-	r.Errors = errors
+	r.Errors = append(r.Errors, NewErrors(err)...)
 }
 
 // Success returns success status of response
@@ -72,7 +94,7 @@ func (r StdResponse) GetErrors() []Error {
 func (r StdResponse) String() string {
 	errs := "["
 	for _, e := range r.Errors {
-		errs = errs + fmt.Sprintf("\"%v\", ", e.e)
+		errs = errs + fmt.Sprintf("\"%v: %v\", ", e.Code, e.Message)
 	}
 	errs = errs + "]"
 	return fmt.Sprintf(`{"successful":%v, "errors":%v}`, r.Success(), errs)
@@ -87,6 +109,6 @@ func NewStdResponse() *StdResponse {
 func NewErrorResponse(err error) *StdResponse {
 	return &StdResponse{
 		Successful: false,
-		Errors:     []Error{Error{err.Error()}},
+		Errors:     newErrors(err),
 	}
 }
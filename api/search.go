@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"server/domain"
+	"server/validation"
+)
+
+// maxSearchResults is the most results a search can ask for
+const maxSearchResults = 100
+
+// SearchTasksRequest is a full text search over tasks. Q is a list of words, a word ending in *
+// matches as a prefix, and words in double quotes match as a phrase. Limit is optional.
+type SearchTasksRequest struct {
+	Q     string
+	Limit string
+}
+
+var _ Request = &SearchTasksRequest{}
+
+// NewSearchTasksRequest builds a SearchTasksRequest from url query parameters
+func NewSearchTasksRequest(values url.Values) SearchTasksRequest {
+	return SearchTasksRequest{
+		Q:     values.Get("q"),
+		Limit: values.Get("limit"),
+	}
+}
+
+func (s *SearchTasksRequest) String() string {
+	return fmt.Sprintf(`{"q":%q, "limit":"%s"}`, s.Q, s.Limit)
+}
+
+// Validate is for conforming to api.Request interface. Q is compulsory, and phrases have to be closed.
+func (s *SearchTasksRequest) Validate() error {
+	v := validation.Validator{}
+	if v.Field("q", strings.TrimSpace(s.Q), validation.Required) && strings.Count(s.Q, `"`)%2 != 0 {
+		v.Add("q", "phrase", "Search has an unterminated phrase")
+	}
+	v.Field("limit", s.Limit, validation.Range(1, maxSearchResults))
+	return v.Err()
+}
+
+// SearchTasksResponse lists the tasks matching a search, best matches first
+type SearchTasksResponse struct {
+	Response `json:"response"`
+	Results  []domain.SearchResult `json:"results"`
+}
+
+func (r SearchTasksResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "results":%v}`, r.Response.String(), r.Results)
+}
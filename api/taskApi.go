@@ -2,9 +2,12 @@ package api
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"server/domain"
+	"server/validation"
 )
 
 // Request section
@@ -12,69 +15,113 @@ import (
 const (
 	titleMaxLength       = 30
 	descriptionMaxLength = 600
+	tagMaxLength         = 30
 )
 
-// CreateTaskRequest used for creating a task
+// CreateTaskRequest used for creating a task. ParentID is optional, and makes the task a subtask.
+// Tags are optional too, and so is Recurrence, which takes anything domain.ParseRecurrence does.
+// Reminders are optional lead times, like 1h or 30m, at which a reminder is sent before the task
+// is due, on top of the one sent when it is due.
 type CreateTaskRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	DueDate     string `json:"dueDate"`
-	Priority    uint8  `json:"priority"`
-	Effort      string `json:"effort"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	DueDate     string   `json:"dueDate"`
+	Priority    uint8    `json:"priority"`
+	Effort      string   `json:"effort"`
+	ParentID    int64    `json:"parentId"`
+	Tags        []string `json:"tags"`
+	Recurrence  string   `json:"recurrence"`
+	Reminders   []string `json:"reminders"`
 }
 
 func (c *CreateTaskRequest) String() string {
-	return fmt.Sprintf(`{"title":"%s", "description":"%s", "dueDate":"%s", "Priority": "%d", "Effort": "%s"}`, c.Title, c.Description, c.DueDate, c.Priority, c.Effort)
+	return fmt.Sprintf(`{"title":"%s", "description":"%s", "dueDate":"%s", "Priority": "%d", "Effort": "%s", "parentId": %d, "tags": %q, "recurrence": "%s", "reminders": %q}`, c.Title, c.Description, c.DueDate, c.Priority, c.Effort, c.ParentID, c.Tags, c.Recurrence, c.Reminders)
 }
 
 // Validate is for conforming to api.Request interface.
 // Title, Priority and DueDate are compulsory columns of a task.
-// DueDate should be entered in domain.DateFormat layout, and has to be in future.
+// DueDate can be in any form domain.ParseTime accepts, and has to be in future.
 func (c *CreateTaskRequest) Validate() error {
-	// Validate title
-	if c.Title == "" {
-		return fmt.Errorf("Cannot have empty title")
-	}
-	if len(c.Title) > titleMaxLength {
-		return fmt.Errorf("Title length cannot be greater than %d", titleMaxLength)
-	}
+	v := validation.Validator{}
+	c.validate(&v, futureRule)
+	return v.Err()
+}
 
-	// Validate priority
-	if c.Priority == 0 {
-		return fmt.Errorf("Cannot have empty Priority")
-	}
-	if c.Priority > 5 {
-		return fmt.Errorf("Priority ranges from 1 to 5 (1 being the least, 5 being the max)")
+// validate checks the fields of c into v. dueDateRules apply to DueDate on top of it being a
+// time.
+func (c *CreateTaskRequest) validate(v *validation.Validator, dueDateRules ...validation.Rule) {
+	v.Field("title", c.Title, validation.Required, validation.MaxLength(titleMaxLength))
+	v.Field("priority", c.Priority, validation.Required, priorityRule)
+	v.Field("dueDate", c.DueDate, append([]validation.Rule{validation.Required, timeRule}, dueDateRules...)...)
+	v.Field("effort", c.Effort, validation.Duration)
+	if c.Effort == "" {
+		c.Effort = "24h"
 	}
+	c.Tags = normalizeTags(v, "tags", c.Tags)
+	v.Field("recurrence", c.Recurrence, recurrenceRule)
+	checkReminders(v, "reminders", c.Reminders)
+}
 
-	// Validate due date
-	if c.DueDate == "" {
-		return fmt.Errorf("Cannot have empty due date")
-	}
-	t, err := time.Parse(domain.DateFormat, c.DueDate)
-	if err != nil {
+// Rules shared by the requests on tasks
+var (
+	priorityRule = validation.Range(1, 5)
+	timeRule     = validation.Func("time", func(value interface{}) error {
+		_, err := domain.ParseTime(value.(string), time.Now())
 		return err
+	})
+	futureRule = validation.Func("future", func(value interface{}) error {
+		now := time.Now()
+		if t, err := domain.ParseTime(value.(string), now); err == nil && !t.After(now) {
+			return fmt.Errorf("Has to be in future")
+		}
+		return nil
+	})
+	statusRule     = validation.OneOf(string(domain.Pending), string(domain.InProgress), string(domain.Done))
+	recurrenceRule = validation.Func("recurrence", func(value interface{}) error {
+		_, err := domain.ParseRecurrence(value.(string))
+		return err
+	})
+	leadTimeRule = validation.Func("leadTime", func(value interface{}) error {
+		d, err := time.ParseDuration(value.(string))
+		if err != nil || d < 0 || d > domain.MaxLeadTime {
+			return fmt.Errorf("Has to be a duration like 1h30m, of at most %s", domain.MaxLeadTime)
+		}
+		return nil
+	})
+)
+
+// checkReminders checks that reminders are lead times before the due date of a task
+func checkReminders(v *validation.Validator, field string, reminders []string) {
+	for i, r := range reminders {
+		v.Field(fmt.Sprintf("%s[%d]", field, i), r, validation.Required, leadTimeRule)
 	}
-	if !t.After(time.Now()) {
-		return fmt.Errorf("DueDate has to be in future")
-	}
+}
 
-	// Vaidate Effort
-	if c.Effort != "" {
-		if _, err := time.ParseDuration(c.Effort); err != nil {
-			return fmt.Errorf("Invalid duration %s", c.Effort)
+// normalizeTags trims and lower cases tags, and checks that they are not empty or too long.
+// Tags end up in urls, so they can't have a slash.
+func normalizeTags(v *validation.Validator, field string, tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		tagField := fmt.Sprintf("%s[%d]", field, i)
+		if !v.Field(tagField, tag, validation.Required, validation.MaxLength(tagMaxLength)) {
+			continue
 		}
-	} else {
-		c.Effort = "24h"
+		if strings.Contains(tag, "/") {
+			v.Add(tagField, "noSlash", "Cannot have a /")
+			continue
+		}
+		normalized = append(normalized, tag)
 	}
-
-	return nil
+	return normalized
 }
 
 var _ Request = &CreateTaskRequest{}
 
 // UpdateTaskRequest is for updating a task. It contains the same field as
 // CreateTaskRequest, with addition of a status field.
+// When updating by title, Title identifies the task. When updating by id, a non empty Title renames the task.
+// Reminders replace the ones of the task when they are present, even as an empty list.
 type UpdateTaskRequest struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
@@ -82,54 +129,158 @@ type UpdateTaskRequest struct {
 	Priority    uint8  `json:"priority"`
 	Effort      string `json:"effort"`
 	Status      string
+	ParentID    int64    `json:"parentId"`
+	Recurrence  string   `json:"recurrence"`
+	Reminders   []string `json:"reminders"`
 }
 
 var _ Request = &UpdateTaskRequest{}
 
 func (u *UpdateTaskRequest) String() string {
-	return fmt.Sprintf(`{"title":"%s", "description":"%s", "dueDate":"%s", "Priority": "%d", "Effort": "%s", "Status":"%s"}`, u.Title, u.Description, u.DueDate, u.Priority, u.Effort, u.Status)
+	return fmt.Sprintf(`{"title":"%s", "description":"%s", "dueDate":"%s", "Priority": "%d", "Effort": "%s", "Status":"%s", "parentId": %d, "recurrence": "%s", "reminders": %q}`, u.Title, u.Description, u.DueDate, u.Priority, u.Effort, u.Status, u.ParentID, u.Recurrence, u.Reminders)
 }
 
 // Validate is for conforming to api.Request interface.
-// Status should be a valid domain.Status
+// Status should be a valid domain.Status. Zero values are not changed, so only what is present is validated.
 func (u *UpdateTaskRequest) Validate() error {
-	// Validate title
-	if u.Title == "" {
-		return fmt.Errorf("Cannot have empty title")
-	}
-	if len(u.Title) > titleMaxLength {
-		return fmt.Errorf("Title length cannot be greater than %d", titleMaxLength)
-	}
+	v := validation.Validator{}
+	v.Field("title", u.Title, validation.MaxLength(titleMaxLength))
+	v.Field("priority", u.Priority, priorityRule)
+	v.Field("dueDate", u.DueDate, timeRule, futureRule)
+	v.Field("effort", u.Effort, validation.Duration)
+	v.Field("recurrence", u.Recurrence, recurrenceRule)
+	v.Field("status", u.Status, statusRule)
+	checkReminders(&v, "reminders", u.Reminders)
+	return v.Err()
+}
+
+// GetTasksRequest is for listing tasks. All fields come from query parameters, and
+// all of them are optional.
+type GetTasksRequest struct {
+	Status      []string
+	MinPriority string
+	MaxPriority string
+	DueBefore   string
+	DueAfter    string
+	Title       string
+	Tag         []string
+	TagMode     string
+	Sort        string
+	Order       string
+	Cursor      string
+	Limit       string
+}
+
+var _ Request = &GetTasksRequest{}
 
-	// Validate priority
-	if u.Priority > 5 || u.Priority < 0 {
-		return fmt.Errorf("Priority ranges from 1 to 5 (1 being the least, 5 being the max)")
+// Sort fields and orders supported by GetTasksRequest
+var (
+	taskSortFields = []string{"id", "dueDate", "priority", "created", "title"}
+	sortOrders     = []string{"asc", "desc"}
+	tagModes       = []string{"any", "all"}
+)
+
+const maxPageSize = 500
+
+// NewGetTasksRequest builds a GetTasksRequest from url query parameters
+func NewGetTasksRequest(values url.Values) GetTasksRequest {
+	return GetTasksRequest{
+		Status:      values["status"],
+		MinPriority: values.Get("minPriority"),
+		MaxPriority: values.Get("maxPriority"),
+		DueBefore:   values.Get("dueBefore"),
+		DueAfter:    values.Get("dueAfter"),
+		Title:       values.Get("title"),
+		Tag:         values["tag"],
+		TagMode:     values.Get("tagMode"),
+		Sort:        values.Get("sort"),
+		Order:       values.Get("order"),
+		Cursor:      values.Get("cursor"),
+		Limit:       values.Get("limit"),
 	}
+}
 
-	// Validate due date
-	if u.DueDate == "" {
-		t, err := time.Parse(domain.DateFormat, u.DueDate)
-		if err != nil {
-			return err
-		}
-		if !t.After(time.Now()) {
-			return fmt.Errorf("DueDate has to be in future")
-		}
+func (g *GetTasksRequest) String() string {
+	return fmt.Sprintf(`{"status":%q, "minPriority":"%s", "maxPriority":"%s", "dueBefore":"%s", "dueAfter":"%s", "title":"%s", "tag":%q, "tagMode":"%s", "sort":"%s", "order":"%s", "cursor":"%s", "limit":"%s"}`, g.Status, g.MinPriority, g.MaxPriority, g.DueBefore, g.DueAfter, g.Title, g.Tag, g.TagMode, g.Sort, g.Order, g.Cursor, g.Limit)
+}
+
+// Validate is for conforming to api.Request interface.
+// Priorities range from 1 to 5, dates are in any form domain.ParseTime accepts and limit
+// cannot exceed maxPageSize. Tasks can match any or all of the tags.
+func (g *GetTasksRequest) Validate() error {
+	v := validation.Validator{}
+	for i, s := range g.Status {
+		v.Field(fmt.Sprintf("status[%d]", i), s, validation.Required, statusRule)
 	}
+	v.Field("minPriority", g.MinPriority, priorityRule)
+	v.Field("maxPriority", g.MaxPriority, priorityRule)
+	v.Field("dueBefore", g.DueBefore, timeRule)
+	v.Field("dueAfter", g.DueAfter, timeRule)
+	g.Tag = normalizeTags(&v, "tag", g.Tag)
+	v.Field("tagMode", g.TagMode, validation.OneOf(tagModes...))
+	v.Field("sort", g.Sort, validation.OneOf(taskSortFields...))
+	v.Field("order", g.Order, validation.OneOf(sortOrders...))
+	v.Field("limit", g.Limit, validation.Range(1, maxPageSize))
+	return v.Err()
+}
 
-	// Vaidate Effort
-	if u.Effort != "" {
-		if _, err := time.ParseDuration(u.Effort); err != nil {
-			return fmt.Errorf("Invalid duration %s", u.Effort)
-		}
+// AddDependencyRequest marks a task as blocked by the task BlockedBy
+type AddDependencyRequest struct {
+	BlockedBy int64 `json:"blockedBy"`
+}
+
+var _ Request = &AddDependencyRequest{}
+
+func (a *AddDependencyRequest) String() string {
+	return fmt.Sprintf(`{"blockedBy":%d}`, a.BlockedBy)
+}
+
+// Validate is for conforming to api.Request interface. BlockedBy is compulsory.
+func (a *AddDependencyRequest) Validate() error {
+	v := validation.Validator{}
+	v.Field("blockedBy", a.BlockedBy, validation.Required, validation.Min(1))
+	return v.Err()
+}
+
+// ReopenTaskRequest moves a task back to Status, Pending by default
+type ReopenTaskRequest struct {
+	Status string `json:"status"`
+}
+
+var _ Request = &ReopenTaskRequest{}
+
+func (r *ReopenTaskRequest) String() string {
+	return fmt.Sprintf(`{"status":"%s"}`, r.Status)
+}
+
+// Validate is for conforming to api.Request interface. Status defaults to Pending.
+func (r *ReopenTaskRequest) Validate() error {
+	if r.Status == "" {
+		r.Status = string(domain.Pending)
 	}
+	v := validation.Validator{}
+	v.Field("status", r.Status, statusRule)
+	return v.Err()
+}
+
+// TagTaskRequest adds tags to a task
+type TagTaskRequest struct {
+	Tags []string `json:"tags"`
+}
 
-	// Status checks
-	tempStatus := domain.Status(u.Status)
-	if u.Status != "" && !(tempStatus == domain.InProgress || tempStatus == domain.Done || tempStatus == domain.Pending) {
-		return fmt.Errorf("Only valid status are: Pending, In-Progress and Done")
+var _ Request = &TagTaskRequest{}
+
+func (t *TagTaskRequest) String() string {
+	return fmt.Sprintf(`{"tags":%q}`, t.Tags)
+}
+
+// Validate is for conforming to api.Request interface. Tags are normalized to lower case.
+func (t *TagTaskRequest) Validate() error {
+	v := validation.Validator{}
+	if v.Field("tags", t.Tags, validation.Required) {
+		t.Tags = normalizeTags(&v, "tags", t.Tags)
 	}
-	return nil
+	return v.Err()
 }
 
 // Response section
@@ -154,12 +305,73 @@ func (r GetTaskResponse) String() string {
 	return fmt.Sprintf(`{"response": %v, "task":%v}`, r.Response.String(), r.Task)
 }
 
-// GetBulkTasksResponse used for returning multiple tasks
-type GetBulkTasksResponse struct {
+// TaskTree is a task along with its subtasks
+type TaskTree struct {
+	Task     domain.Task `json:"task"`
+	Children []TaskTree  `json:"children"`
+}
+
+// GetTaskTreeResponse is used for returning a task with all its descendants
+type GetTaskTreeResponse struct {
 	Response `json:"response"`
-	Tasks    []domain.Task `json:"tasks"`
+	Tree     TaskTree `json:"tree"`
+}
+
+func (r GetTaskTreeResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "tree":%v}`, r.Response.String(), r.Tree.Task)
+}
+
+// GetBulkTasksResponse used for returning multiple tasks. NextCursor is set
+// when there are more tasks to fetch, and Total is the count of all matching tasks.
+type GetBulkTasksResponse struct {
+	Response   `json:"response"`
+	Tasks      []domain.Task `json:"tasks"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+	Total      int64         `json:"total"`
 }
 
 func (r GetBulkTasksResponse) String() string {
-	return fmt.Sprintf(`{"response": %v, "tasks":%v}`, r.Response.String(), "")
+	return fmt.Sprintf(`{"response": %v, "tasks":%v, "nextCursor":"%s", "total":%d}`, r.Response.String(), "", r.NextCursor, r.Total)
+}
+
+// GetBlockersResponse lists the tasks blocking a task. Blocked is true as long
+// as any of them is not done.
+type GetBlockersResponse struct {
+	Response `json:"response"`
+	Blockers []domain.Task `json:"blockers"`
+	Blocked  bool          `json:"blocked"`
+}
+
+func (r GetBlockersResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "blockers":%v, "blocked":%v}`, r.Response.String(), r.Blockers, r.Blocked)
+}
+
+// GetTagCountsResponse lists every tag in use, with the number of tasks having it
+type GetTagCountsResponse struct {
+	Response `json:"response"`
+	Tags     []domain.TagCount `json:"tags"`
+}
+
+func (r GetTagCountsResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "tags":%v}`, r.Response.String(), r.Tags)
+}
+
+// GetCompletionsResponse lists the completed occurrences of a recurring task
+type GetCompletionsResponse struct {
+	Response    `json:"response"`
+	Completions []domain.Completion `json:"completions"`
+}
+
+func (r GetCompletionsResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "completions":%v}`, r.Response.String(), r.Completions)
+}
+
+// GetHistoryResponse lists the status transitions of a task, oldest first
+type GetHistoryResponse struct {
+	Response `json:"response"`
+	History  []domain.HistoryEntry `json:"history"`
+}
+
+func (r GetHistoryResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "history":%v}`, r.Response.String(), r.History)
 }
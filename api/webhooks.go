@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+
+	"server/domain"
+	"server/validation"
+)
+
+const (
+	urlMaxLength    = 2000
+	secretMaxLength = 200
+)
+
+// CreateWebhookRequest subscribes URL to events of tasks, to every event when Events is empty.
+// Deliveries are signed with Secret, or with a secret made up by the server if it is empty.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+var _ Request = &CreateWebhookRequest{}
+
+// String leaves the secret out, since requests are logged
+func (c *CreateWebhookRequest) String() string {
+	return fmt.Sprintf(`{"url":"%s", "events":%q}`, c.URL, c.Events)
+}
+
+// Validate is for conforming to api.Request interface. URL has to be an absolute http or https URL.
+func (c *CreateWebhookRequest) Validate() error {
+	v := validation.Validator{}
+	if v.Field("url", c.URL, validation.Required, validation.MaxLength(urlMaxLength)) {
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.Add("url", "url", "Has to be an http or https URL")
+		}
+	}
+	v.Field("secret", c.Secret, validation.MaxLength(secretMaxLength))
+	events := make([]string, 0, len(domain.TaskEvents))
+	for _, e := range domain.TaskEvents {
+		events = append(events, string(e))
+	}
+	for i, e := range c.Events {
+		v.Field(fmt.Sprintf("events[%d]", i), e, validation.Required, validation.OneOf(events...))
+	}
+	return v.Err()
+}
+
+// GetDeliveriesRequest filters the delivery log of a webhook. Both fields come from query
+// parameters, and are optional.
+type GetDeliveriesRequest struct {
+	Status string
+	Limit  string
+}
+
+var _ Request = &GetDeliveriesRequest{}
+
+// NewGetDeliveriesRequest builds a GetDeliveriesRequest from url query parameters
+func NewGetDeliveriesRequest(values url.Values) GetDeliveriesRequest {
+	return GetDeliveriesRequest{Status: values.Get("status"), Limit: values.Get("limit")}
+}
+
+func (g *GetDeliveriesRequest) String() string {
+	return fmt.Sprintf(`{"status":"%s", "limit":"%s"}`, g.Status, g.Limit)
+}
+
+// Validate is for conforming to api.Request interface
+func (g *GetDeliveriesRequest) Validate() error {
+	v := validation.Validator{}
+	v.Field("status", g.Status, validation.OneOf(string(domain.DeliveryPending), string(domain.DeliveryDelivered), string(domain.DeliveryFailed)))
+	v.Field("limit", g.Limit, validation.Range(1, maxPageSize))
+	return v.Err()
+}
+
+// CreateWebhookResponse has the id of the new webhook, and the secret its deliveries are signed
+// with. The secret is never shown again.
+type CreateWebhookResponse struct {
+	Response  `json:"response"`
+	WebhookID int64  `json:"webhookId"`
+	Secret    string `json:"secret"`
+}
+
+func (r CreateWebhookResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "webhookId": %d}`, r.Response.String(), r.WebhookID)
+}
+
+// GetWebhooksResponse lists the webhooks, by id
+type GetWebhooksResponse struct {
+	Response `json:"response"`
+	Webhooks []domain.Webhook `json:"webhooks"`
+}
+
+func (r GetWebhooksResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "webhooks":%v}`, r.Response.String(), r.Webhooks)
+}
+
+// GetDeliveriesResponse is the delivery log of a webhook, newest first
+type GetDeliveriesResponse struct {
+	Response   `json:"response"`
+	Deliveries []domain.WebhookDelivery `json:"deliveries"`
+}
+
+func (r GetDeliveriesResponse) String() string {
+	return fmt.Sprintf(`{"response": %v, "deliveries":%d}`, r.Response.String(), len(r.Deliveries))
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"time"
 )
 
 type config struct {
@@ -22,12 +23,82 @@ type config struct {
 	HashedPassword string
 }
 
-// TaskConfig stores configuration for task management database
+// TaskConfig stores configuration for task management database. Transitions and Reopens
+// replace the default status workflow of tasks, see domain.Workflow. Deleted tasks stay in
+// the trash for TrashRetentionDays, 30 by default. A database statement is cancelled after
+// QueryTimeoutSeconds, 10 by default. A MEMORY DbType keeps tasks in memory, and DbURL is then
+// an optional json file they are saved to on shutdown and loaded from on start. Timezone is the
+// IANA name of the timezone of the user, like Europe/Paris, UTC by default. Dates given without
+// an offset are in it, and dates are shown in it. Reminders configures the reminders of tasks
+// which are coming due.
 type TaskConfig struct {
-	DbURL      string
-	DbUser     string
-	DbPassword string
-	DbType     string
+	DbURL               string
+	DbUser              string
+	DbPassword          string
+	DbType              string
+	Transitions         map[string][]string
+	Reopens             map[string][]string
+	TrashRetentionDays  int
+	QueryTimeoutSeconds int
+	Timezone            string
+	Reminders           ReminderConfig
+}
+
+// ReminderConfig configures where reminders of tasks go: by mail through SMTP, to every URL of
+// Webhooks, and to LogFile, which can be - for the standard error. No reminders are sent unless
+// one of them is set. LeadTimes, like 1h or 24h, apply to every task on top of its own. Due
+// reminders are looked for every IntervalSeconds, 60 by default.
+type ReminderConfig struct {
+	IntervalSeconds int
+	LeadTimes       []string
+	SMTP            SMTPConfig
+	Webhooks        []string
+	LogFile         string
+}
+
+// SMTPConfig is the SMTP server reminders are mailed through. Addr is the host:port of the
+// server, and mails are only sent if it is set.
+type SMTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// defaultReminderInterval is used when IntervalSeconds is not set
+const defaultReminderInterval = time.Minute
+
+// Interval is how often due reminders are looked for
+func (r ReminderConfig) Interval() time.Duration {
+	if r.IntervalSeconds <= 0 {
+		return defaultReminderInterval
+	}
+	return time.Duration(r.IntervalSeconds) * time.Second
+}
+
+// defaultTrashRetentionDays is used when TrashRetentionDays is not set
+const defaultTrashRetentionDays = 30
+
+// TrashRetention is how long deleted tasks are kept in the trash before they are purged
+func (t TaskConfig) TrashRetention() time.Duration {
+	days := t.TrashRetentionDays
+	if days <= 0 {
+		days = defaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// defaultQueryTimeout is used when QueryTimeoutSeconds is not set. It is kept below the
+// WriteTimeout of the server, so that a request can still answer after a slow query.
+const defaultQueryTimeout = 10 * time.Second
+
+// QueryTimeout is the longest a single database statement can run
+func (t TaskConfig) QueryTimeout() time.Duration {
+	if t.QueryTimeoutSeconds <= 0 {
+		return defaultQueryTimeout
+	}
+	return time.Duration(t.QueryTimeoutSeconds) * time.Second
 }
 
 // IsNotEmpty is opposite of IsEmpty
@@ -37,7 +108,7 @@ func (t TaskConfig) IsNotEmpty() bool {
 
 // IsEmpty checks whether taskConfig has all it's fields declared properly or not.
 func (t TaskConfig) IsEmpty() bool {
-	return t == TaskConfig{} || t.DbURL == "" || t.DbType == ""
+	return t.DbType == "" || (t.DbURL == "" && t.DbType != "MEMORY")
 }
 
 // config stores the configuration
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"server/api"
+	"server/errors"
+	"server/ical"
+)
+
+// CreateCalendarFeed makes a calendar feed. The answer has the token the feed is read with,
+// which is never shown again.
+func (pc TaskController) CreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	var createCalendarFeedRequest api.CreateCalendarFeedRequest
+	err := NewValidationDecoder(r).DecodeAndValidate(&createCalendarFeedRequest)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	log.Printf("createCalendarFeedRequest:[%v]", createCalendarFeedRequest)
+
+	resp := pc.TaskService.CreateCalendarFeed(r.Context(), createCalendarFeedRequest)
+	log.Printf("createCalendarFeedResponse:[%v]", resp)
+	handleCreated(resp, w)
+}
+
+// GetCalendarFeeds lists the calendar feeds
+func (pc TaskController) GetCalendarFeeds(w http.ResponseWriter, r *http.Request) {
+	resp := pc.TaskService.GetCalendarFeeds(r.Context())
+	log.Printf("GetCalendarFeedsResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// DeleteCalendarFeed deletes a calendar feed, its token stops working
+func (pc TaskController) DeleteCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "calendar feed")
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.DeleteCalendarFeed(r.Context(), id)
+	log.Printf("DeleteCalendarFeedResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetCalendar is the iCalendar feed of the tasks which are not done, read with the token query
+// parameter of a calendar feed. The filters of GetAllTasks apply, and errors are answered in json.
+func (pc TaskController) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	getCalendarRequest := api.NewGetCalendarRequest(r.URL.Query())
+	if err := getCalendarRequest.Validate(); err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.GetCalendar(r.Context(), getCalendarRequest)
+	log.Printf("GetCalendarResponse:[%v]", resp)
+	if !resp.Success() {
+		handleResponse(resp, w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="tasks.ics"`)
+	if err := ical.WriteCalendar(w, resp.Feed.Name, r.Host, resp.Tasks, time.Now()); err != nil {
+		log.Printf("Could not write calendar feed %d: %v", resp.Feed.Rowid, err)
+	}
+}
+
+// maxCalendarSize is the most bytes of an imported calendar file
+const maxCalendarSize = 2 << 20
+
+// ImportCalendar creates a task for each to-do of the iCalendar file in the body, which are not
+// completed or cancelled, or updates the task of a to-do imported before. Either all of them are
+// imported, or none is. To-dos without a due date are left out, and listed in the response.
+func (pc TaskController) ImportCalendar(w http.ResponseWriter, r *http.Request) {
+	todos, err := ical.ParseTodos(http.MaxBytesReader(w, r.Body, maxCalendarSize))
+	if err != nil {
+		handleRequestError(errors.ErrorMalformedRequest.WithMessage("Invalid calendar: %s", err.Error()), w)
+		return
+	}
+	importCalendarRequest := api.ImportCalendarRequest{Todos: todos}
+	if err := importCalendarRequest.Validate(); err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	log.Printf("ImportCalendarRequest:[%v]", importCalendarRequest.String())
+
+	resp := pc.TaskService.ImportCalendar(r.Context(), importCalendarRequest)
+	log.Printf("ImportCalendarResponse:[%v]", resp)
+	handleCreated(resp, w)
+}
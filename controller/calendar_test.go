@@ -0,0 +1,18 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestImportCalendarTooLarge(t *testing.T) {
+	// the service is not reached, the stub would panic
+	pc := TaskController{TaskService: stubService{}}
+	todo := "BEGIN:VTODO\r\nSUMMARY:" + strings.Repeat("x", 1000) + "\r\nEND:VTODO\r\n"
+	body := "BEGIN:VCALENDAR\r\n" + strings.Repeat(todo, maxCalendarSize/len(todo)+1) + "END:VCALENDAR\r\n"
+	w := serve(pc.ImportCalendar, "/api/calendar/import", "POST", "/api/calendar/import", body)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "too large") {
+		t.Errorf("got %d %s, want a calendar too large refused", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+	"server/events"
+)
+
+const (
+	// eventStreamDuration is how long an event stream is kept open. It ends before the write
+	// timeout of the server, and the client reconnects, resuming from the last event it got.
+	eventStreamDuration = 10 * time.Second
+
+	// eventStreamRetry is how long clients wait before they reconnect
+	eventStreamRetry = time.Second
+
+	// eventReset is sent when events were missed, and what the client shows has to be reloaded
+	eventReset = "reset"
+)
+
+// StreamEvents streams the events of tasks as server sent events. Query parameters event (which
+// can be repeated), taskId and tag filter them. A client which reconnects with the Last-Event-ID
+// header, or the lastEventId parameter, first gets the events it missed.
+func (pc TaskController) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	getEventsRequest := api.NewGetEventsRequest(r.URL.Query(), r.Header.Get("Last-Event-ID"))
+	if err := getEventsRequest.Validate(); err != nil {
+		handleRequestError(err, w)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok || pc.Events == nil {
+		handleRequestError(errors.ErrorInternal.WithMessage("Event streams are not supported"), w)
+		return
+	}
+
+	filter := events.Filter{Tag: getEventsRequest.Tag}
+	for _, e := range getEventsRequest.Events {
+		filter.Types = append(filter.Types, domain.TaskEvent(e))
+	}
+	filter.TaskID, _ = strconv.ParseInt(getEventsRequest.TaskID, 10, 64)
+	lastID, _ := strconv.ParseInt(getEventsRequest.LastEventID, 10, 64)
+	log.Printf("getEventsRequest:[%v]", getEventsRequest)
+
+	sub := pc.Events.Subscribe(lastID, filter)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry/time.Millisecond)
+	if sub.Missed {
+		writeEvent(w, sub.LastID, eventReset, struct{}{})
+	}
+	for _, e := range sub.Backlog {
+		writeEvent(w, e.ID, string(e.Type), e)
+	}
+	flusher.Flush()
+
+	timer := time.NewTimer(eventStreamDuration)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// the client fell behind and was dropped, it resumes when it reconnects
+				return
+			}
+			writeEvent(w, e.ID, string(e.Type), e)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes a server sent event, with data as JSON
+func writeEvent(w http.ResponseWriter, id int64, event string, data interface{}) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Could not write event %d: %v", id, err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, b)
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"server/domain"
+	"server/events"
+)
+
+// sse is a server sent event, with its data left as json
+type sse struct {
+	id, event, data string
+}
+
+// readEvents reads the server sent events of a stream, until it ends or n of them are read
+func readEvents(r io.Reader, n int) []sse {
+	read := make([]sse, 0)
+	var e sse
+	scanner := bufio.NewScanner(r)
+	for len(read) < n && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if e.event != "" {
+				read = append(read, e)
+			}
+			e = sse{}
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return read
+}
+
+// resume streams the events after lastID, and the events it missed, from hub. The request is
+// cancelled beforehand, so that the stream ends once the backlog is written.
+func resume(hub *events.Hub, query, lastID string) []sse {
+	pc := TaskController{Events: hub}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("GET", "/api/events?"+query, nil).WithContext(ctx)
+	if lastID != "" {
+		r.Header.Set("Last-Event-ID", lastID)
+	}
+	w := httptest.NewRecorder()
+	pc.StreamEvents(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		return []sse{{event: fmt.Sprintf("status %d", w.Code), data: w.Body.String()}}
+	}
+	return readEvents(w.Body, 100)
+}
+
+// publish publishes events of tasks 1 to 3 on hub, and returns the id of the latest one before
+func publish(hub *events.Hub) int64 {
+	start := hub.Subscribe(0, events.Filter{})
+	start.Close()
+	hub.Publish(events.Event{Type: domain.EventTaskCreated, TaskID: 1, Task: domain.Task{Rowid: 1, Tags: []string{"home"}}})
+	hub.Publish(events.Event{Type: domain.EventTaskUpdated, TaskID: 2, Task: domain.Task{Rowid: 2, Tags: []string{"work"}}})
+	hub.Publish(events.Event{Type: domain.EventTaskCreated, TaskID: 3, Task: domain.Task{Rowid: 3, Tags: []string{"work"}}})
+	return start.LastID
+}
+
+func TestStreamEventsBacklog(t *testing.T) {
+	hub := events.NewHub(10)
+	start := publish(hub)
+	id := func(n int64) string { return fmt.Sprint(start + n) }
+
+	cases := []struct {
+		name, query, lastID string
+		want                []string
+	}{
+		{"new stream", "", "", []string{}},
+		{"all missed", "", id(0), []string{"task.created " + id(1), "task.updated " + id(2), "task.created " + id(3)}},
+		{"after the first", "", id(1), []string{"task.updated " + id(2), "task.created " + id(3)}},
+		{"up to date", "", id(3), []string{}},
+		{"by type", "event=task.created", id(0), []string{"task.created " + id(1), "task.created " + id(3)}},
+		{"by task", "taskId=2", id(0), []string{"task.updated " + id(2)}},
+		{"by tag", "tag=Work", id(1), []string{"task.updated " + id(2), "task.created " + id(3)}},
+		{"by parameter", "lastEventId=" + id(2), "", []string{"task.created " + id(3)}},
+		{"before a restart", "", "42", []string{"reset " + id(3), "task.created " + id(1), "task.updated " + id(2), "task.created " + id(3)}},
+		{"from a later id", "", id(9), []string{"reset " + id(3)}},
+	}
+	for _, c := range cases {
+		got := make([]string, 0)
+		for _, e := range resume(hub, c.query, c.lastID) {
+			got = append(got, e.event+" "+e.id)
+		}
+		if strings.Join(got, ", ") != strings.Join(c.want, ", ") {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestStreamEventsReset(t *testing.T) {
+	// only the latest 2 events are kept, the first one is missed
+	hub := events.NewHub(2)
+	start := publish(hub)
+	got := resume(hub, "tag=work", fmt.Sprint(start))
+	if len(got) != 3 || got[0].event != "reset" || got[0].id != fmt.Sprint(start+3) || got[0].data != "{}" {
+		t.Fatalf("got %v, want a reset event before the backlog", got)
+	}
+	if got[1].id != fmt.Sprint(start+2) || got[2].id != fmt.Sprint(start+3) || !strings.Contains(got[2].data, `"taskId":3`) {
+		t.Errorf("got backlog %v, want events %d and %d", got[1:], start+2, start+3)
+	}
+}
+
+func TestStreamEventsLive(t *testing.T) {
+	hub := events.NewHub(10)
+	pc := TaskController{Events: hub}
+	server := httptest.NewServer(http.HandlerFunc(pc.StreamEvents))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequest("GET", server.URL+"?taskId=3&event=task.created", nil)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// the headers come once the stream is subscribed
+	start := publish(hub)
+	got := readEvents(resp.Body, 1)
+	if len(got) != 1 || got[0].event != "task.created" || got[0].id != fmt.Sprint(start+3) {
+		t.Errorf("got %v, want the creation of task 3", got)
+	}
+}
+
+func TestStreamEventsInvalid(t *testing.T) {
+	got := resume(events.NewHub(10), "taskId=x", "")
+	if len(got) != 1 || got[0].event != fmt.Sprintf("status %d", http.StatusUnprocessableEntity) {
+		t.Errorf("got %v, want the request refused", got)
+	}
+}
@@ -6,28 +6,80 @@ import (
 	"net/http"
 
 	"server/api"
+	"server/errors"
+	"server/validation"
 )
 
 func init() {
 
 }
 
+// errorStatuses are the http statuses of the codes of errors.AggError
+var errorStatuses = map[string]int{
+	errors.ErrorObjectNotFound.StringCode():      http.StatusNotFound,
+	errors.ErrorObjectAlreadyExists.StringCode(): http.StatusConflict,
+	errors.ErrorConflict.StringCode():            http.StatusConflict,
+	errors.ErrorInvalidArgument.StringCode():     http.StatusUnprocessableEntity,
+	errors.ErrorArgumentMismatch.StringCode():    http.StatusUnprocessableEntity,
+	errors.ErrorInvalidType.StringCode():         http.StatusUnprocessableEntity,
+	errors.ErrorInternal.StringCode():            http.StatusInternalServerError,
+	errors.ErrorMalformedRequest.StringCode():    http.StatusBadRequest,
+}
+
+// handleResponse writes resp as json, with 200 OK if it is successful
 func handleResponse(resp api.Response, w http.ResponseWriter) {
+	writeResponse(resp, responseStatus(resp, http.StatusOK), w)
+}
+
+// handleCreated writes resp as json, with 201 Created if it is successful
+func handleCreated(resp api.Response, w http.ResponseWriter) {
+	writeResponse(resp, responseStatus(resp, http.StatusCreated), w)
+}
+
+// handleRequestError writes an error response for a request which could not be read or is not
+// valid. Errors without a code, like the ones of a body which is not json, are malformed requests.
+func handleRequestError(err error, w http.ResponseWriter) {
+	switch err.(type) {
+	case validation.Errors, errors.AggError:
+	default:
+		err = errors.ErrorMalformedRequest.WithMessage("%s", err.Error())
+	}
+	handleResponse(api.NewErrorResponse(err), w)
+}
+
+// writeResponse writes resp as json with status
+func writeResponse(resp api.Response, status int, w http.ResponseWriter) {
 	j, err := json.Marshal(resp)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 	log.Printf("response: %v", string(j))
-	if resp.Success() {
-		w.WriteHeader(http.StatusCreated)
-	} else {
-		w.WriteHeader(http.StatusBadRequest)
-	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	w.Write(j)
 	w.Write([]byte{'\n'})
 }
 
+// responseStatus is successStatus for a successful response, or else the status of its first error
+func responseStatus(resp api.Response, successStatus int) int {
+	if resp.Success() {
+		return successStatus
+	}
+	return errorStatus(resp.GetErrors())
+}
+
+// errorStatus is the http status of the first error. Unknown codes are internal errors.
+func errorStatus(errs []api.Error) int {
+	if len(errs) == 0 {
+		return http.StatusInternalServerError
+	}
+	if status, ok := errorStatuses[errs[0].Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
 // ValidationDecoder is a wrapper around a json decoder, so that I can perform request validations
 // automatically after decoding. Prevents lots of boiler-plate code
 type ValidationDecoder struct {
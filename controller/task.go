@@ -2,18 +2,25 @@
 package controller
 
 import (
-	_ "io/ioutil" // gonna use this later
+	"io"
+	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"server/api"
+	"server/errors"
+	"server/events"
 	"server/service"
 )
 
-// TaskController is for task crud operations
+// TaskController is for task crud operations. Events streams the changes of tasks.
 type TaskController struct {
 	TaskService service.ITaskService
+	Events      *events.Hub
 }
 
 // CreateTask ...
@@ -21,7 +28,7 @@ func (pc TaskController) CreateTask(w http.ResponseWriter, r *http.Request) {
 	var createTaskRequest api.CreateTaskRequest
 	err := NewValidationDecoder(r).DecodeAndValidate(&createTaskRequest)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		handleRequestError(err, w)
 		return
 	}
 
@@ -29,12 +36,20 @@ func (pc TaskController) CreateTask(w http.ResponseWriter, r *http.Request) {
 
 	resp := pc.TaskService.CreateTask(r.Context(), createTaskRequest)
 	log.Printf("createTaskResponse:[%v]", resp)
-	handleResponse(resp, w)
+	handleCreated(resp, w)
 }
 
-// GetAllTasks ...
+// GetAllTasks lists tasks. Query parameters status, minPriority, maxPriority, dueBefore,
+// dueAfter, title and tag (with tagMode any or all) filter the tasks, sort and order sort them,
+// and limit and cursor paginate them.
 func (pc TaskController) GetAllTasks(w http.ResponseWriter, r *http.Request) {
-	resp := pc.TaskService.GetAllTasks(r.Context())
+	getTasksRequest := api.NewGetTasksRequest(r.URL.Query())
+	if err := getTasksRequest.Validate(); err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.GetAllPaginatedTasks(r.Context(), getTasksRequest)
 	log.Printf("GetAllTasksResponse: [%v]", resp)
 	handleResponse(resp, w)
 }
@@ -44,7 +59,7 @@ func (pc TaskController) GetTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name, ok := vars["name"]
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		handleRequestError(errors.ErrorArgumentMismatch.WithMessage("Missing task name").WithField("name"), w)
 		return
 	}
 
@@ -58,7 +73,7 @@ func (pc TaskController) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	var updateTaskRequest api.UpdateTaskRequest
 	err := NewValidationDecoder(r).DecodeAndValidate(&updateTaskRequest)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		handleRequestError(err, w)
 		return
 	}
 
@@ -75,10 +90,369 @@ func (pc TaskController) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name, ok := vars["name"]
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		handleRequestError(errors.ErrorArgumentMismatch.WithMessage("Missing task name").WithField("name"), w)
 		return
 	}
 	resp := pc.TaskService.DeleteTask(r.Context(), name)
 	log.Printf("DeleteTaskResponse:[%v]", resp)
 	handleResponse(resp, w)
 }
+
+// taskID reads the numeric id of a task from the route variables
+func taskID(r *http.Request) (int64, error) {
+	return pathID(r, "task")
+}
+
+// pathID reads the id variable of the path of r, the id of a what
+func pathID(r *http.Request, what string) (int64, error) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		return 0, errors.ErrorInvalidArgument.WithMessage("Invalid %s id", what).WithField("id")
+	}
+	return id, nil
+}
+
+// GetTaskByID ...
+func (pc TaskController) GetTaskByID(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.GetTaskByID(r.Context(), id)
+	log.Printf("GetTaskByIDResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// UpdateTaskByID updates a task by its id. Unlike UpdateTask, the title can be changed.
+func (pc TaskController) UpdateTaskByID(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	var updateTaskRequest api.UpdateTaskRequest
+	err = NewValidationDecoder(r).DecodeAndValidate(&updateTaskRequest)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	log.Printf("updateTaskByIDRequest:[%d, %v]", id, updateTaskRequest)
+
+	resp := pc.TaskService.UpdateTaskByID(r.Context(), id, updateTaskRequest)
+	log.Printf("updateTaskByIDResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// DeleteTaskByID ...
+func (pc TaskController) DeleteTaskByID(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.DeleteTaskByID(r.Context(), id)
+	log.Printf("DeleteTaskByIDResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// PatchTaskByID partially updates a task. The body is a merge patch by default, or a json patch
+// when sent with Content-Type application/json-patch+json. Fields can be cleared with either.
+func (pc TaskController) PatchTaskByID(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	contentType := api.MergePatchType
+	if header := r.Header.Get("Content-Type"); header != "" {
+		mediaType, _, err := mime.ParseMediaType(header)
+		if err != nil {
+			writeResponse(api.NewErrorResponse(errors.ErrorInvalidArgument.WithMessage("%s", err.Error()).WithField("Content-Type")), http.StatusUnsupportedMediaType, w)
+			return
+		}
+		// plain json is treated as a merge patch
+		if mediaType != "application/json" {
+			contentType = mediaType
+		}
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	patchTaskRequest := api.PatchTaskRequest{ContentType: contentType, Patch: body}
+	if err := patchTaskRequest.Validate(); err != nil {
+		if contentType != api.MergePatchType && contentType != api.JSONPatchType {
+			writeResponse(api.NewErrorResponse(err), http.StatusUnsupportedMediaType, w)
+			return
+		}
+		handleRequestError(err, w)
+		return
+	}
+
+	log.Printf("patchTaskRequest:[%d, %v]", id, patchTaskRequest.String())
+
+	resp := pc.TaskService.PatchTaskByID(r.Context(), id, patchTaskRequest)
+	log.Printf("patchTaskResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetChildTasks lists the direct subtasks of a task
+func (pc TaskController) GetChildTasks(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.GetChildTasks(r.Context(), id)
+	log.Printf("GetChildTasksResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetSubtree returns a task with all of its descendants, nested as a tree
+func (pc TaskController) GetSubtree(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.GetSubtree(r.Context(), id)
+	log.Printf("GetSubtreeResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// AddDependency marks the task as blocked by another task, given in the body as blockedBy
+func (pc TaskController) AddDependency(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	var addDependencyRequest api.AddDependencyRequest
+	err = NewValidationDecoder(r).DecodeAndValidate(&addDependencyRequest)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.AddDependency(r.Context(), id, addDependencyRequest)
+	log.Printf("AddDependencyResponse:[%v]", resp)
+	handleCreated(resp, w)
+}
+
+// RemoveDependency ...
+func (pc TaskController) RemoveDependency(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+	blockedBy, err := strconv.ParseInt(mux.Vars(r)["blockedBy"], 10, 64)
+	if err != nil {
+		handleRequestError(errors.ErrorInvalidArgument.WithMessage("Invalid blocking task id").WithField("blockedBy"), w)
+		return
+	}
+
+	resp := pc.TaskService.RemoveDependency(r.Context(), id, blockedBy)
+	log.Printf("RemoveDependencyResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetBlockers lists the tasks blocking a task
+func (pc TaskController) GetBlockers(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.GetBlockers(r.Context(), id)
+	log.Printf("GetBlockersResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetTasksInDependencyOrder lists all tasks, with blocking tasks before the tasks they block
+func (pc TaskController) GetTasksInDependencyOrder(w http.ResponseWriter, r *http.Request) {
+	resp := pc.TaskService.GetTasksInDependencyOrder(r.Context())
+	log.Printf("GetTasksInDependencyOrderResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// AddTags tags a task with the tags in the body
+func (pc TaskController) AddTags(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	var tagTaskRequest api.TagTaskRequest
+	err = NewValidationDecoder(r).DecodeAndValidate(&tagTaskRequest)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.AddTags(r.Context(), id, tagTaskRequest)
+	log.Printf("AddTagsResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// RemoveTag ...
+func (pc TaskController) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	tag := strings.ToLower(mux.Vars(r)["tag"])
+	resp := pc.TaskService.RemoveTag(r.Context(), id, tag)
+	log.Printf("RemoveTagResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetTagCounts lists every tag with the number of tasks having it
+func (pc TaskController) GetTagCounts(w http.ResponseWriter, r *http.Request) {
+	resp := pc.TaskService.GetTagCounts(r.Context())
+	log.Printf("GetTagCountsResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetCompletions lists the completed occurrences of a recurring task
+func (pc TaskController) GetCompletions(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.GetCompletions(r.Context(), id)
+	log.Printf("GetCompletionsResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// ReopenTask moves a task back to an earlier status. The body is optional, and defaults to
+// {"status": "Pending"}.
+func (pc TaskController) ReopenTask(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	var reopenTaskRequest api.ReopenTaskRequest
+	err = NewValidationDecoder(r).DecodeAndValidate(&reopenTaskRequest)
+	if err == io.EOF {
+		err = reopenTaskRequest.Validate()
+	}
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.ReopenTask(r.Context(), id, reopenTaskRequest)
+	log.Printf("ReopenTaskResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetHistory lists the status transitions of a task
+func (pc TaskController) GetHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.GetHistory(r.Context(), id)
+	log.Printf("GetHistoryResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetAuditLog lists revisions of tasks. Query parameters taskId, user, from and to filter them,
+// and limit caps how many are listed.
+func (pc TaskController) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	getAuditLogRequest := api.NewGetAuditLogRequest(r.URL.Query())
+	if err := getAuditLogRequest.Validate(); err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.GetAuditLog(r.Context(), getAuditLogRequest)
+	log.Printf("GetAuditLogResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// RestoreTask takes a task out of the trash, or brings it back to an earlier revision.
+// The body is optional, and defaults to taking the task out of the trash.
+func (pc TaskController) RestoreTask(w http.ResponseWriter, r *http.Request) {
+	id, err := taskID(r)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	var restoreTaskRequest api.RestoreTaskRequest
+	err = NewValidationDecoder(r).DecodeAndValidate(&restoreTaskRequest)
+	if err == io.EOF {
+		err = restoreTaskRequest.Validate()
+	}
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.RestoreTask(r.Context(), id, restoreTaskRequest)
+	log.Printf("RestoreTaskResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetTrash lists the deleted tasks which have not been purged yet
+func (pc TaskController) GetTrash(w http.ResponseWriter, r *http.Request) {
+	resp := pc.TaskService.GetTrash(r.Context())
+	log.Printf("GetTrashResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// SearchTasks ranks tasks by the words of the query parameter q. Words ending in * match as
+// prefixes, and words in double quotes as phrases. limit caps the number of results.
+func (pc TaskController) SearchTasks(w http.ResponseWriter, r *http.Request) {
+	searchTasksRequest := api.NewSearchTasksRequest(r.URL.Query())
+	if err := searchTasksRequest.Validate(); err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.SearchTasks(r.Context(), searchTasksRequest)
+	log.Printf("SearchTasksResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// BulkTasks runs a list of create, update and delete operations. Either all of them are applied,
+// or none is.
+func (pc TaskController) BulkTasks(w http.ResponseWriter, r *http.Request) {
+	var bulkTasksRequest api.BulkTasksRequest
+	err := NewValidationDecoder(r).DecodeAndValidate(&bulkTasksRequest)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	log.Printf("BulkTasksRequest:[%v]", bulkTasksRequest.String())
+
+	resp := pc.TaskService.BulkTasks(r.Context(), bulkTasksRequest)
+	log.Printf("BulkTasksResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"server/api"
+	"server/errors"
+	"server/service"
+)
+
+// stubService answers every GetTaskByID and CreateTask with the response it holds. The other
+// methods of service.ITaskService are not implemented.
+type stubService struct {
+	service.ITaskService
+	resp api.Response
+}
+
+func (s stubService) GetTaskByID(ctx context.Context, id int64) api.GetTaskResponse {
+	return api.GetTaskResponse{Response: s.resp}
+}
+
+func (s stubService) CreateTask(ctx context.Context, r api.CreateTaskRequest) api.CreateTaskResponse {
+	return api.CreateTaskResponse{Response: s.resp, TaskID: 1}
+}
+
+// serve runs handler on a request to the route path, as the router of the server would
+func serve(handler http.HandlerFunc, route, method, path, body string) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	r.HandleFunc(route, handler).Methods(method)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestErrorStatus(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{nil, http.StatusOK},
+		{errors.ErrorObjectNotFound, http.StatusNotFound},
+		{errors.ErrorObjectAlreadyExists, http.StatusConflict},
+		{errors.ErrorConflict.WithMessage("Cannot start task 1"), http.StatusConflict},
+		{errors.ErrorInvalidArgument.WithField("parentId"), http.StatusUnprocessableEntity},
+		{errors.ErrorMalformedRequest, http.StatusBadRequest},
+		{errors.ErrorInternal, http.StatusInternalServerError},
+		{fmt.Errorf("database is locked"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		resp := api.NewStdResponse()
+		if c.err != nil {
+			resp = api.NewErrorResponse(c.err)
+		}
+		pc := TaskController{TaskService: stubService{resp: resp}}
+		w := serve(pc.GetTaskByID, "/api/tasks/{id}", "GET", "/api/tasks/1", "")
+		if w.Code != c.status {
+			t.Errorf("%v: got status %d, want %d", c.err, w.Code, c.status)
+		}
+	}
+}
+
+func TestInternalErrorMessage(t *testing.T) {
+	pc := TaskController{TaskService: stubService{resp: api.NewErrorResponse(fmt.Errorf("open /var/lib/tasks.db: permission denied"))}}
+	w := serve(pc.GetTaskByID, "/api/tasks/{id}", "GET", "/api/tasks/1", "")
+	if strings.Contains(w.Body.String(), "tasks.db") {
+		t.Errorf("got %s, want the cause of the error kept out of the response", w.Body.String())
+	}
+	var body struct {
+		Response api.StdResponse `json:"response"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if errs := body.Response.Errors; len(errs) != 1 || errs[0].Code != errors.ErrorInternal.StringCode() || errs[0].Message != "Internal error" {
+		t.Errorf("got %+v, want a generic internal error", errs)
+	}
+}
+
+func TestRequestErrorStatus(t *testing.T) {
+	pc := TaskController{TaskService: stubService{resp: api.NewStdResponse()}}
+	cases := []struct {
+		handler     http.HandlerFunc
+		route, path string
+		method      string
+		body        string
+		status      int
+	}{
+		{pc.CreateTask, "/api/task", "/api/task", "POST", `{"title":`, http.StatusBadRequest},
+		{pc.CreateTask, "/api/task", "/api/task", "POST", `{"title":"", "priority": 9}`, http.StatusUnprocessableEntity},
+		{pc.CreateTask, "/api/task", "/api/task", "POST", `{"title":"water plants","dueDate":"+1d","priority":3}`, http.StatusCreated},
+		{pc.GetTaskByID, "/api/tasks/{id}", "/api/tasks/x", "GET", "", http.StatusUnprocessableEntity},
+	}
+	for _, c := range cases {
+		w := serve(c.handler, c.route, c.method, c.path, c.body)
+		if w.Code != c.status {
+			t.Errorf("%s %s %s: got status %d, want %d: %s", c.method, c.path, c.body, w.Code, c.status, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+
+	"server/api"
+)
+
+// CreateWebhook subscribes a URL to events of tasks. The answer has the secret deliveries are
+// signed with, which is never shown again.
+func (pc TaskController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var createWebhookRequest api.CreateWebhookRequest
+	err := NewValidationDecoder(r).DecodeAndValidate(&createWebhookRequest)
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	log.Printf("createWebhookRequest:[%v]", createWebhookRequest)
+
+	resp := pc.TaskService.CreateWebhook(r.Context(), createWebhookRequest)
+	log.Printf("createWebhookResponse:[%v]", resp)
+	handleCreated(resp, w)
+}
+
+// GetWebhooks lists the webhooks
+func (pc TaskController) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	resp := pc.TaskService.GetWebhooks(r.Context())
+	log.Printf("GetWebhooksResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// DeleteWebhook unsubscribes a webhook
+func (pc TaskController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "webhook")
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.DeleteWebhook(r.Context(), id)
+	log.Printf("DeleteWebhookResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// GetWebhookDeliveries is the delivery log of a webhook, newest first. Query parameters status
+// and limit filter it.
+func (pc TaskController) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "webhook")
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+	getDeliveriesRequest := api.NewGetDeliveriesRequest(r.URL.Query())
+	if err := getDeliveriesRequest.Validate(); err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.GetWebhookDeliveries(r.Context(), id, getDeliveriesRequest)
+	log.Printf("GetWebhookDeliveriesResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
+
+// ReplayDelivery sends a failed delivery again
+func (pc TaskController) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "delivery")
+	if err != nil {
+		handleRequestError(err, w)
+		return
+	}
+
+	resp := pc.TaskService.ReplayDelivery(r.Context(), id)
+	log.Printf("ReplayDeliveryResponse:[%v]", resp)
+	handleResponse(resp, w)
+}
@@ -1,5 +1,13 @@
 package db
 
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
 // Result interface is used to get query execution info
 type Result interface {
 	LastInsertId() (int64, error)
@@ -12,19 +20,70 @@ type Row interface {
 	StructScan(dest interface{}) error
 }
 
-// Rows are just a collection of Row
+// Rows are just a collection of Row. Close them once done, and check Err after the last one,
+// which tells if the iteration stopped early because of an error.
 type Rows interface {
 	Row
 	Next() bool
+	Err() error
+	Close() error
 }
 
 // Handler interface to db. Underlying implementation can be sqlite, postgres, etc.
-// Should be used in repositories.
+// Should be used in repositories. Every statement is cancelled along with its context, or
+// when it runs past the query timeout of the handler.
 type Handler interface {
 	Type() Type
-	Execute(statement string, args ...interface{}) (Result, error)
-	QueryRow(statement string, args ...interface{}) Row
-	Query(statement string, args ...interface{}) (Rows, error)
+	Execute(ctx context.Context, statement string, args ...interface{}) (Result, error)
+	QueryRow(ctx context.Context, statement string, args ...interface{}) Row
+	Query(ctx context.Context, statement string, args ...interface{}) (Rows, error)
+	Begin(ctx context.Context) (Tx, error)
+	WithTx(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// Tx is a database transaction. It is a Handler itself, so that a repository can run the same
+// statements in a transaction. Statements are only applied once Commit is called, and the
+// transaction is rolled back if its context is cancelled first.
+//
+// WithTx on a Tx runs fn in the same transaction, so functions using WithTx can call each other.
+type Tx interface {
+	Handler
+	Commit() error
+	Rollback() error
+}
+
+// ErrNestedTx is returned when beginning a transaction inside another one
+var ErrNestedTx = errors.New("Transactions cannot be nested")
+
+// ErrNoRows is returned by the Scan and StructScan of a Row which matched nothing
+var ErrNoRows = sql.ErrNoRows
+
+// IsUniqueViolation tells if err is a unique or primary key constraint failing, in any db
+func IsUniqueViolation(err error) bool {
+	return isSqliteUniqueViolation(err) || isPostgresUniqueViolation(err)
+}
+
+// withTx begins a transaction on handler and calls fn with it. The transaction is committed if
+// fn returns nil, and rolled back if it returns an error or panics.
+func withTx(ctx context.Context, handler Handler, fn func(tx Tx) error) (err error) {
+	tx, err := handler.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%s, and rollback failed: %s", err.Error(), rollbackErr.Error())
+		}
+		return err
+	}
+	return tx.Commit()
 }
 
 // Type is enum for which type of database
@@ -33,4 +92,16 @@ type Type string
 const (
 	// SQLITE represents SQLITE database type
 	SQLITE Type = "SQLITE"
+	// POSTGRES represents POSTGRES database type
+	POSTGRES Type = "POSTGRES"
+	// MEMORY keeps the tasks in memory, it has no Handler
+	MEMORY Type = "MEMORY"
 )
+
+// withTimeout bounds ctx by timeout. A timeout of 0 leaves ctx as it is.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
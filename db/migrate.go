@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Migration is a numbered change of the schema. Up and Down are lists of statements, Down
+// undoes Up. Migrations are applied in the order of their versions. Before, when set, runs
+// ahead of Up in the same transaction, for the changes which depend on the schema found, like
+// the one of a db set up by hand.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+	Before  func(ctx context.Context, tx Tx) error
+}
+
+// MigrationStatus tells whether a migration is applied, and when. A migration which is
+// recorded as applied, but not known to this build, has an empty Name.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt string
+}
+
+// Migrator applies migrations to a database, and keeps track of the applied ones in the
+// schema_migrations table. Every migration runs in its own transaction.
+type Migrator struct {
+	handler    Handler
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for migrations. Versions have to be unique.
+func NewMigrator(handler Handler, migrations []Migration) (*Migrator, error) {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("Migration version %d is used twice", sorted[i].Version)
+		}
+	}
+	return &Migrator{handler: handler, migrations: sorted}, nil
+}
+
+// Up applies every migration which is not applied yet, and returns them
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0)
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := m.handler.WithTx(ctx, func(tx Tx) error {
+			if migration.Before != nil {
+				if err := migration.Before(ctx, tx); err != nil {
+					return err
+				}
+			}
+			if err := run(ctx, tx, migration.Up); err != nil {
+				return err
+			}
+			_, err := tx.Execute(ctx, "INSERT INTO schema_migrations (version, name, applied) VALUES ($1, $2, $3)", migration.Version, migration.Name, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("Migration %d %s failed: %s", migration.Version, migration.Name, err.Error())
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the last applied migration, and returns it. It fails if no migration is applied.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+	last := 0
+	for version := range applied {
+		if version > last {
+			last = version
+		}
+	}
+	if last == 0 {
+		return Migration{}, fmt.Errorf("No migration is applied")
+	}
+
+	migration, ok := m.find(last)
+	if !ok {
+		return Migration{}, fmt.Errorf("Migration %d is applied, but not known to this build", last)
+	}
+	err = m.handler.WithTx(ctx, func(tx Tx) error {
+		if err := run(ctx, tx, migration.Down); err != nil {
+			return err
+		}
+		_, err := tx.Execute(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+		return err
+	})
+	if err != nil {
+		return migration, fmt.Errorf("Rolling back migration %d %s failed: %s", migration.Version, migration.Name, err.Error())
+	}
+	return migration, nil
+}
+
+// Status lists every known migration, along with those applied but unknown, by version
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+	for version, appliedAt := range applied {
+		if _, ok := m.find(version); !ok {
+			statuses = append(statuses, MigrationStatus{Migration: Migration{Version: version}, Applied: true, AppliedAt: appliedAt})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// applied returns when each applied migration was applied, by version. It creates the
+// schema_migrations table if needed.
+func (m *Migrator) applied(ctx context.Context) (map[int]string, error) {
+	if _, err := m.handler.Execute(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied TEXT NOT NULL)"); err != nil {
+		return nil, err
+	}
+	rows, err := m.handler.Query(ctx, "SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+func run(ctx context.Context, tx Tx, statements []string) error {
+	for _, statement := range statements {
+		if _, err := tx.Execute(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+var migrations = []Migration{
+	{
+		Version: 2,
+		Name:    "class",
+		Up:      []string{schema[1]},
+		Down:    []string{"DROP TABLE class"},
+	},
+	{
+		Version: 1,
+		Name:    "student",
+		Up:      []string{schema[0]},
+		Down:    []string{"DROP TABLE student"},
+	},
+}
+
+func tableExists(t *testing.T, handler Handler, table string) bool {
+	var n int
+	if err := handler.QueryRow(context.Background(), "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n == 1
+}
+
+func TestMigrator(t *testing.T) {
+	handler := NewSqliteHandler(":memory:", 0)
+	handler.Conn.SetMaxOpenConns(1)
+	ctx := context.Background()
+	migrator, err := NewMigrator(handler, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0].Version != 1 || applied[1].Version != 2 {
+		t.Errorf("got %v, want migrations 1 and 2 in order", applied)
+	}
+	if applied, err = migrator.Up(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("got %v, %v when nothing is pending", applied, err)
+	}
+
+	rolledBack, err := migrator.Down(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rolledBack.Version != 2 || tableExists(t, handler, "class") || !tableExists(t, handler, "student") {
+		t.Errorf("expected only migration 2 to be rolled back, got %v", rolledBack)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || !statuses[0].Applied || statuses[0].AppliedAt == "" || statuses[1].Applied {
+		t.Errorf("got statuses %v", statuses)
+	}
+}
+
+func TestMigratorFailure(t *testing.T) {
+	handler := NewSqliteHandler(":memory:", 0)
+	handler.Conn.SetMaxOpenConns(1)
+	ctx := context.Background()
+	broken := Migration{Version: 3, Name: "broken", Up: []string{schema[2], "NOT SQL"}}
+	migrator, err := NewMigrator(handler, append(migrations, broken))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err == nil {
+		t.Fatalf("expected migration 3 to fail")
+	}
+	if len(applied) != 2 {
+		t.Errorf("got %v, want migrations 1 and 2 applied", applied)
+	}
+	if tableExists(t, handler, "studentClass") {
+		t.Errorf("a failed migration should be rolled back")
+	}
+
+	if _, err := NewMigrator(handler, append(migrations, Migration{Version: 1})); err == nil {
+		t.Errorf("expected an error for a version used twice")
+	}
+}
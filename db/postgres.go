@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+	"github.com/lib/pq"
+)
+
+// PostgresHandler implements Handler intrface. Timeout is the longest a statement can run,
+// 0 means no limit.
+//
+// Postgres folds unquoted column names to lower case, so columns are mapped to struct fields,
+// and their db tags, in lower case.
+type PostgresHandler struct {
+	Conn    *sqlx.DB
+	Timeout time.Duration
+}
+
+// Type of PostgresHandler is POSTGRES
+func (handler *PostgresHandler) Type() Type {
+	return POSTGRES
+}
+
+// Execute is for non-select queries.
+func (handler *PostgresHandler) Execute(ctx context.Context, statement string, args ...interface{}) (Result, error) {
+	return execute(ctx, handler.Conn, handler.Timeout, statement, args...)
+}
+
+// Query is for select queries which return multiple rows
+func (handler *PostgresHandler) Query(ctx context.Context, statement string, args ...interface{}) (Rows, error) {
+	return query(ctx, handler.Conn, handler.Timeout, statement, args...)
+}
+
+// QueryRow is for those queries which return only 1 row.
+func (handler *PostgresHandler) QueryRow(ctx context.Context, statement string, args ...interface{}) Row {
+	return queryRow(ctx, handler.Conn, handler.Timeout, statement, args...)
+}
+
+// Begin starts a transaction, which is rolled back if ctx is cancelled before it is committed
+func (handler *PostgresHandler) Begin(ctx context.Context) (Tx, error) {
+	tx, err := handler.Conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresTx{Tx: tx, Timeout: handler.Timeout}, nil
+}
+
+// WithTx calls fn in a transaction, which is committed if fn returns nil and rolled back otherwise
+func (handler *PostgresHandler) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	return withTx(ctx, handler, fn)
+}
+
+// NewPostgresHandler returns a PostgresHandler for a postgres:// url. user and password, if
+// not empty, take the place of those of the url. Statements time out after timeout, 0 means
+// no limit.
+func NewPostgresHandler(dbURL, user, password string, timeout time.Duration) (*PostgresHandler, error) {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	if user != "" {
+		u.User = url.UserPassword(user, password)
+	}
+	conn, err := sqlx.Open("postgres", u.String())
+	if err != nil {
+		return nil, err
+	}
+	conn.Mapper = reflectx.NewMapperTagFunc("db", strings.ToLower, strings.ToLower)
+	return &PostgresHandler{Conn: conn, Timeout: timeout}, nil
+}
+
+// PostgresTx implements Tx interface. Timeout applies to each statement, like in PostgresHandler.
+type PostgresTx struct {
+	Tx      *sqlx.Tx
+	Timeout time.Duration
+}
+
+// Type of PostgresTx is POSTGRES
+func (tx *PostgresTx) Type() Type {
+	return POSTGRES
+}
+
+// Execute is for non-select queries.
+func (tx *PostgresTx) Execute(ctx context.Context, statement string, args ...interface{}) (Result, error) {
+	return execute(ctx, tx.Tx, tx.Timeout, statement, args...)
+}
+
+// Query is for select queries which return multiple rows
+func (tx *PostgresTx) Query(ctx context.Context, statement string, args ...interface{}) (Rows, error) {
+	return query(ctx, tx.Tx, tx.Timeout, statement, args...)
+}
+
+// QueryRow is for those queries which return only 1 row.
+func (tx *PostgresTx) QueryRow(ctx context.Context, statement string, args ...interface{}) Row {
+	return queryRow(ctx, tx.Tx, tx.Timeout, statement, args...)
+}
+
+// Begin always fails, nested transactions are not supported
+func (tx *PostgresTx) Begin(ctx context.Context) (Tx, error) {
+	return nil, ErrNestedTx
+}
+
+// WithTx calls fn in this transaction. It is committed or rolled back by whoever began it.
+func (tx *PostgresTx) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	return fn(tx)
+}
+
+// Commit applies the statements of the transaction
+func (tx *PostgresTx) Commit() error {
+	return tx.Tx.Commit()
+}
+
+// Rollback discards the statements of the transaction
+func (tx *PostgresTx) Rollback() error {
+	return tx.Tx.Rollback()
+}
+
+// uniqueViolation is the SQLSTATE of postgres for a unique or primary key constraint failing
+const uniqueViolation = "23505"
+
+// isPostgresUniqueViolation tells if err is a unique or primary key constraint of postgres failing
+func isPostgresUniqueViolation(err error) bool {
+	var e *pq.Error
+	return errors.As(err, &e) && e.Code == uniqueViolation
+}
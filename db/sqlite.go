@@ -1,94 +1,119 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3" // For sqlite3 driver
+	"github.com/mattn/go-sqlite3"
 )
 
-// SqliteRow - implements Row interface
-type SqliteRow struct {
-	Row *sqlx.Row
+// SqliteHandler implements Handler intrface. Timeout is the longest a statement can run,
+// 0 means no limit.
+type SqliteHandler struct {
+	Conn    *sqlx.DB
+	Timeout time.Duration
 }
 
-// Scan is a wrapper around sql.Scan. Used for scanning
-// db values in a row.
-func (r SqliteRow) Scan(dest ...interface{}) error {
-	return r.Row.Scan(dest...)
+// Type of SqliteHandler is SQLITE
+func (handler *SqliteHandler) Type() Type {
+	return SQLITE
 }
 
-// StructScan is a wrapper around sqlx.Row.StructScan
-func (r SqliteRow) StructScan(dest interface{}) error {
-	return r.Row.StructScan(dest)
+// Execute is for non-select queries.
+func (handler *SqliteHandler) Execute(ctx context.Context, statement string, args ...interface{}) (Result, error) {
+	return execute(ctx, handler.Conn, handler.Timeout, statement, args...)
 }
 
-// SqliteRows - implements Rows iterface
-type SqliteRows struct {
-	Rows *sqlx.Rows
+// Query is for select queries which return multiple rows
+func (handler *SqliteHandler) Query(ctx context.Context, statement string, args ...interface{}) (Rows, error) {
+	return query(ctx, handler.Conn, handler.Timeout, statement, args...)
 }
 
-// Scan is a wrapper around sql.Scan. Used for scanning
-// db values in a row.
-func (r SqliteRows) Scan(dest ...interface{}) error {
-	return r.Rows.Scan(dest...)
+// QueryRow is for those queries which return only 1 row.
+func (handler *SqliteHandler) QueryRow(ctx context.Context, statement string, args ...interface{}) Row {
+	return queryRow(ctx, handler.Conn, handler.Timeout, statement, args...)
 }
 
-// StructScan is a wrapper around sqlx.Rows.StructScan
-func (r SqliteRows) StructScan(dest interface{}) error {
-	return r.Rows.StructScan(dest)
+// NewSqliteHandler returns an SqliteHandler, whose statements time out after timeout.
+// A timeout of 0 means no limit.
+func NewSqliteHandler(dbfileName string, timeout time.Duration) *SqliteHandler {
+	conn, _ := sqlx.Open("sqlite3", dbfileName)
+	sqliteHandler := new(SqliteHandler)
+	sqliteHandler.Conn = conn
+	sqliteHandler.Timeout = timeout
+	return sqliteHandler
 }
 
-// Next is a wrapper around sql.Next. Used for iterating
-// on multiple rows
-func (r SqliteRows) Next() bool {
-	return r.Rows.Next()
+// InMemorySqliteHandler is an in memory db, useful for testing
+var InMemorySqliteHandler = NewSqliteHandler(":memory:", 0)
+
+// Begin starts a transaction, which is rolled back if ctx is cancelled before it is committed
+func (handler *SqliteHandler) Begin(ctx context.Context) (Tx, error) {
+	tx, err := handler.Conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SqliteTx{Tx: tx, Timeout: handler.Timeout}, nil
 }
 
-// SqliteHandler implements Handler intrface.
-type SqliteHandler struct {
-	Conn *sqlx.DB
+// WithTx calls fn in a transaction, which is committed if fn returns nil and rolled back otherwise
+func (handler *SqliteHandler) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	return withTx(ctx, handler, fn)
 }
 
-// Type of SqliteHandler is SQLITE
-func (handler *SqliteHandler) Type() Type {
+// SqliteTx implements Tx interface. Timeout applies to each statement, like in SqliteHandler.
+type SqliteTx struct {
+	Tx      *sqlx.Tx
+	Timeout time.Duration
+}
+
+// Type of SqliteTx is SQLITE
+func (tx *SqliteTx) Type() Type {
 	return SQLITE
 }
 
 // Execute is for non-select queries.
-func (handler *SqliteHandler) Execute(statement string, args ...interface{}) (Result, error) {
-	stmt, err := handler.Conn.Preparex(statement)
-	if err != nil {
-		return nil, err
-	}
-	// return stmt.MustExec(args...), nil
-	return stmt.Exec(args...)
+func (tx *SqliteTx) Execute(ctx context.Context, statement string, args ...interface{}) (Result, error) {
+	return execute(ctx, tx.Tx, tx.Timeout, statement, args...)
 }
 
-// Query is for those queries which return only 1 row.
-func (handler *SqliteHandler) Query(statement string, args ...interface{}) (Rows, error) {
-	r, err := handler.Conn.Queryx(statement, args...)
-	if err != nil {
-		return new(SqliteRows), err
-	}
-	rows := new(SqliteRows)
-	rows.Rows = r
-	return rows, nil
+// Query is for select queries which return multiple rows
+func (tx *SqliteTx) Query(ctx context.Context, statement string, args ...interface{}) (Rows, error) {
+	return query(ctx, tx.Tx, tx.Timeout, statement, args...)
 }
 
-// QueryRow is for those select queries which return multiple rows
-func (handler *SqliteHandler) QueryRow(statement string, args ...interface{}) Row {
-	r := handler.Conn.QueryRowx(statement, args...)
-	row := new(SqliteRow)
-	row.Row = r
-	return row
+// QueryRow is for those queries which return only 1 row.
+func (tx *SqliteTx) QueryRow(ctx context.Context, statement string, args ...interface{}) Row {
+	return queryRow(ctx, tx.Tx, tx.Timeout, statement, args...)
 }
 
-// NewSqliteHandler returns an SqliteHandler
-func NewSqliteHandler(dbfileName string) *SqliteHandler {
-	conn, _ := sqlx.Open("sqlite3", dbfileName)
-	sqliteHandler := new(SqliteHandler)
-	sqliteHandler.Conn = conn
-	return sqliteHandler
+// Begin always fails, sqlite has no nested transactions
+func (tx *SqliteTx) Begin(ctx context.Context) (Tx, error) {
+	return nil, ErrNestedTx
 }
 
-// InMemorySqliteHandler is an in memory db, useful for testing
-var InMemorySqliteHandler = NewSqliteHandler(":memory:")
+// WithTx calls fn in this transaction. It is committed or rolled back by whoever began it.
+func (tx *SqliteTx) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	return fn(tx)
+}
+
+// Commit applies the statements of the transaction
+func (tx *SqliteTx) Commit() error {
+	return tx.Tx.Commit()
+}
+
+// Rollback discards the statements of the transaction
+func (tx *SqliteTx) Rollback() error {
+	return tx.Tx.Rollback()
+}
+
+// isSqliteUniqueViolation tells if err is a unique or primary key constraint of sqlite failing
+func isSqliteUniqueViolation(err error) bool {
+	var e sqlite3.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.ExtendedCode == sqlite3.ErrConstraintUnique || e.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
@@ -1,7 +1,10 @@
 package db
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 )
 
 var schema = []string{`CREATE TABLE student ( rowid INTEGER primary key AUTOINCREMENT, name TEXT, constraint unique_student_name unique (name));`,
@@ -20,7 +23,7 @@ func initDB() *SqliteHandler {
 	sqlHandler := InMemorySqliteHandler
 	for _, stmt := range schema {
 
-		_, err := sqlHandler.Execute(stmt)
+		_, err := sqlHandler.Execute(context.Background(), stmt)
 		if err != nil {
 			panic(err.Error())
 		}
@@ -49,3 +52,158 @@ type StudentClass struct {
 
 func TestScan(t *testing.T) {
 }
+
+func TestTx(t *testing.T) {
+	handler := NewSqliteHandler(":memory:", 0)
+	// every connection to :memory: opens a new db, so stick to one
+	handler.Conn.SetMaxOpenConns(1)
+	if _, err := handler.Execute(context.Background(), schema[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func() int {
+		var n int
+		if err := handler.QueryRow(context.Background(), "SELECT count(*) FROM student").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	tx, err := handler.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Execute(context.Background(), "INSERT INTO student values (null, ?)", "Neha"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Begin(context.Background()); err != ErrNestedTx {
+		t.Errorf("got %v, want %v", err, ErrNestedTx)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 0 {
+		t.Errorf("got %d students after a rollback, want 0", n)
+	}
+
+	tx, err = handler.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Execute(context.Background(), "INSERT INTO student values (null, ?)", "Aditya"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("got %d students after a commit, want 1", n)
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	handler := NewSqliteHandler(":memory:", 0)
+	handler.Conn.SetMaxOpenConns(1)
+	if _, err := handler.Execute(context.Background(), schema[0]); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	insert := func(name string) func(tx Tx) error {
+		return func(tx Tx) error {
+			_, err := tx.Execute(ctx, "INSERT INTO student values (null, ?)", name)
+			return err
+		}
+	}
+
+	failed := errors.New("failed")
+	err := handler.WithTx(ctx, func(tx Tx) error {
+		// joins the outer transaction
+		if err := tx.WithTx(ctx, insert("Neha")); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Errorf("got %v, want %v", err, failed)
+	}
+
+	if err := handler.WithTx(ctx, insert("Aditya")); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := handler.WithTx(cancelled, insert("Rahul")); err == nil {
+		t.Errorf("expected an error with a cancelled context")
+	}
+
+	var names []string
+	rows, err := handler.Query(ctx, "SELECT name FROM student")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if len(names) != 1 || names[0] != "Aditya" {
+		t.Errorf("got %v, want [Aditya]", names)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	handler := NewSqliteHandler(":memory:", 10*time.Millisecond)
+	slow := "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 100000000) SELECT count(*) FROM c"
+
+	var n int
+	if err := handler.QueryRow(context.Background(), slow).Scan(&n); err == nil {
+		t.Errorf("expected a slow query to time out")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := handler.Execute(ctx, "SELECT 1"); err == nil {
+		t.Errorf("expected an error with a cancelled context")
+	}
+
+	if err := handler.QueryRow(context.Background(), "SELECT 1").Scan(&n); err != nil || n != 1 {
+		t.Errorf("got %d, %v, want 1", n, err)
+	}
+}
+
+func TestRows(t *testing.T) {
+	handler := NewSqliteHandler(":memory:", 10*time.Millisecond)
+	handler.Conn.SetMaxOpenConns(1)
+
+	// the only connection is given back by Close, even though some rows were not read
+	rows, err := handler.Query(context.Background(), "SELECT 1 UNION ALL SELECT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal("expected a row")
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := handler.QueryRow(context.Background(), "SELECT 3").Scan(&n); err != nil || n != 3 {
+		t.Errorf("got %d, %v after closing rows, want 3", n, err)
+	}
+
+	// a query running past its timeout stops the iteration, Err tells it apart from the end
+	slow := "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 100000000) SELECT x FROM c"
+	rows, err = handler.Query(context.Background(), slow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	if rows.Err() == nil {
+		t.Errorf("expected an error once the query timed out")
+	}
+}
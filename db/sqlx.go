@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SqlxRow - implements Row interface, for any database behind sqlx
+type SqlxRow struct {
+	Row    *sqlx.Row
+	cancel context.CancelFunc
+}
+
+// Scan is a wrapper around sql.Scan. Used for scanning
+// db values in a row.
+func (r SqlxRow) Scan(dest ...interface{}) error {
+	defer r.done()
+	return r.Row.Scan(dest...)
+}
+
+// StructScan is a wrapper around sqlx.Row.StructScan
+func (r SqlxRow) StructScan(dest interface{}) error {
+	defer r.done()
+	return r.Row.StructScan(dest)
+}
+
+// done releases the context of the query, once the row is read
+func (r SqlxRow) done() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// SqlxRows - implements Rows iterface, for any database behind sqlx
+type SqlxRows struct {
+	Rows   *sqlx.Rows
+	cancel context.CancelFunc
+}
+
+// Scan is a wrapper around sql.Scan. Used for scanning
+// db values in a row.
+func (r SqlxRows) Scan(dest ...interface{}) error {
+	return r.Rows.Scan(dest...)
+}
+
+// StructScan is a wrapper around sqlx.Rows.StructScan
+func (r SqlxRows) StructScan(dest interface{}) error {
+	return r.Rows.StructScan(dest)
+}
+
+// Next is a wrapper around sql.Next. Used for iterating
+// on multiple rows. The context of the query is released after the last row.
+func (r SqlxRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	if r.cancel != nil {
+		r.cancel()
+	}
+	return false
+}
+
+// Err is a wrapper around sql.Rows.Err, the error which ended the iteration, if any
+func (r SqlxRows) Err() error {
+	if r.Rows == nil {
+		return nil
+	}
+	return r.Rows.Err()
+}
+
+// Close is a wrapper around sql.Rows.Close, which releases the context of the query too.
+// Rows can be closed more than once.
+func (r SqlxRows) Close() error {
+	if r.cancel != nil {
+		defer r.cancel()
+	}
+	if r.Rows == nil {
+		return nil
+	}
+	return r.Rows.Close()
+}
+
+// sqlxQueryer is what handlers and transactions of sqlx have in common. Statements are
+// written with ? placeholders, and rebound to the placeholders of the database.
+type sqlxQueryer interface {
+	Rebind(query string) string
+	PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error)
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
+}
+
+// execute runs a non-select statement on q, within timeout
+func execute(ctx context.Context, q sqlxQueryer, timeout time.Duration, statement string, args ...interface{}) (Result, error) {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	stmt, err := q.PreparexContext(ctx, q.Rebind(statement))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	return stmt.ExecContext(ctx, args...)
+}
+
+// query runs a select statement on q. The timeout covers reading the rows too.
+func query(ctx context.Context, q sqlxQueryer, timeout time.Duration, statement string, args ...interface{}) (Rows, error) {
+	ctx, cancel := withTimeout(ctx, timeout)
+	r, err := q.QueryxContext(ctx, q.Rebind(statement), args...)
+	if err != nil {
+		cancel()
+		return new(SqlxRows), err
+	}
+	return &SqlxRows{Rows: r, cancel: cancel}, nil
+}
+
+// queryRow runs a select statement returning one row on q. The timeout covers scanning the row too.
+func queryRow(ctx context.Context, q sqlxQueryer, timeout time.Duration, statement string, args ...interface{}) Row {
+	ctx, cancel := withTimeout(ctx, timeout)
+	return &SqlxRow{Row: q.QueryRowxContext(ctx, q.Rebind(statement), args...), cancel: cancel}
+}
@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// AuditAction is the kind of change recorded by an AuditEntry
+type AuditAction string
+
+const (
+	// AuditCreate is recorded when a task is added
+	AuditCreate AuditAction = "create"
+	// AuditUpdate is recorded when any field of a task changes
+	AuditUpdate AuditAction = "update"
+	// AuditDelete is recorded when a task is deleted
+	AuditDelete AuditAction = "delete"
+	// AuditReopen is recorded when a task is explicitly reopened
+	AuditReopen AuditAction = "reopen"
+	// AuditRestore is recorded when a task is restored to an earlier revision
+	AuditRestore AuditAction = "restore"
+)
+
+// AuditEntry is one revision of a task: what changed, who changed it and when. Snapshot is the
+// task as it was after the change, or right before it was deleted, so a task can be restored to
+// any revision. The rowid of the entry is the revision number.
+type AuditEntry struct {
+	Rowid    int64        `json:"rowid"`
+	TaskID   int64        `json:"taskId" db:"taskId"`
+	Action   AuditAction  `json:"action"`
+	Changes  FieldChanges `json:"changes"`
+	Snapshot Snapshot     `json:"snapshot"`
+	Changed  Time         `json:"changed"`
+	Username string       `json:"username"`
+}
+
+// FieldChange is the change of a single field of a task. Values are in their json form,
+// an empty string means that the field was not set.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// FieldChanges is stored as a json array
+type FieldChanges []FieldChange
+
+// Scan is for use in StructScan in repository layers.
+func (c *FieldChanges) Scan(v interface{}) error {
+	return scanJSON(v, c)
+}
+
+// Value driver
+func (c FieldChanges) Value() (driver.Value, error) {
+	b, err := json.Marshal(c)
+	return string(b), err
+}
+
+// Snapshot is a copy of a task, stored as json
+type Snapshot Task
+
+// Scan is for use in StructScan in repository layers.
+func (s *Snapshot) Scan(v interface{}) error {
+	return scanJSON(v, s)
+}
+
+// Value driver
+func (s Snapshot) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+// UnmarshalJSON reads a snapshot back. Snapshots may have no creation time, which Time does
+// not decode, so the times are parsed here.
+func (s *Snapshot) UnmarshalJSON(b []byte) error {
+	type snapshot Snapshot
+	var raw struct {
+		snapshot
+		DueDate string `json:"dueDate"`
+		Effort  string `json:"effort"`
+		Created string `json:"created"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*s = Snapshot(raw.snapshot)
+	if err := s.DueDate.Scan(raw.DueDate); err != nil {
+		return err
+	}
+	if err := s.Effort.Scan(raw.Effort); err != nil {
+		return err
+	}
+	if raw.Created != "" {
+		return s.Created.Scan(raw.Created)
+	}
+	return nil
+}
+
+func scanJSON(v interface{}, dest interface{}) error {
+	switch value := v.(type) {
+	case string:
+		return json.Unmarshal([]byte(value), dest)
+	case []byte:
+		return json.Unmarshal(value, dest)
+	}
+	return errors.New("Could not parse")
+}
+
+// auditedFields are the fields compared by Diff, in the order changes are reported
+var auditedFields = []string{"title", "description", "dueDate", "status", "priority", "effort", "parentId", "recurrence", "reminders"}
+
+// Diff returns the changes of the audited fields between two versions of a task. A nil old
+// task means it was just created, and a nil task means it was deleted.
+func Diff(old, task *Task) FieldChanges {
+	oldValues, newValues := auditValues(old), auditValues(task)
+	changes := make(FieldChanges, 0)
+	for _, field := range auditedFields {
+		if oldValues[field] != newValues[field] {
+			changes = append(changes, FieldChange{Field: field, Old: oldValues[field], New: newValues[field]})
+		}
+	}
+	return changes
+}
+
+func auditValues(t *Task) map[string]string {
+	values := make(map[string]string)
+	if t == nil {
+		return values
+	}
+	values["title"] = t.Title
+	values["description"] = t.Description
+	values["dueDate"] = t.DueDate.String()
+	values["status"] = string(t.Status)
+	values["priority"] = strconv.Itoa(int(t.Priority))
+	values["effort"] = t.Effort.String()
+	if t.ParentID != nil {
+		values["parentId"] = strconv.FormatInt(*t.ParentID, 10)
+	}
+	if t.Recurrence != nil {
+		values["recurrence"] = t.Recurrence.String()
+	}
+	if len(t.Reminders) > 0 {
+		leadTimes := make([]string, 0, len(t.Reminders))
+		for _, d := range t.Reminders {
+			leadTimes = append(leadTimes, d.String())
+		}
+		values["reminders"] = strings.Join(leadTimes, ",")
+	}
+	return values
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// CalendarFeed lets calendar apps, which cannot log in, subscribe to tasks. The feed is read with
+// its token, of which only a hash is kept.
+type CalendarFeed struct {
+	Rowid     int64  `json:"rowid"`
+	Name      string `json:"name"`
+	TokenHash string `json:"-" db:"tokenHash"`
+	Created   Time   `json:"created"`
+}
+
+// ImportedTodo is a to-do of a calendar file which was imported as a task. Its uid is kept, so
+// that importing the to-do again updates the task rather than making another one.
+type ImportedTodo struct {
+	UID    string `json:"uid"`
+	TaskID int64  `json:"taskId" db:"taskId"`
+}
+
+// HashToken is the hash kept of the token of a calendar feed
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
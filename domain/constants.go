@@ -1,8 +1,15 @@
 package domain
 
 const (
-	// DateFormat is the standard parsing format for all kinds of dates.
-	DateFormat = "2006-01-02 03:04:05"
+	// DateFormat is the layout of dates without an offset, on a 24 hour clock. It is also the
+	// layout of sqlite's CURRENT_TIMESTAMP, in UTC.
+	DateFormat = "2006-01-02 15:04:05"
+
+	// dateOnlyFormat is the layout of dates without a time of day
+	dateOnlyFormat = "2006-01-02"
+
+	// sqliteDriverFormat is the layout the sqlite driver writes time.Time values in
+	sqliteDriverFormat = "2006-01-02 15:04:05.999999999-07:00"
 )
 
 const (
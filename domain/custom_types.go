@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"encoding/json"
 	"errors"
 	"log"
 	"time"
@@ -8,39 +9,65 @@ import (
 	"database/sql/driver"
 )
 
-// Time is custom time, so that I could add scanner on it
+// Time is custom time, so that I could add scanner on it. Times are kept in UTC, and rendered
+// in the timezone of the user.
 type Time time.Time
 
-// Scan is to conform to StructScan
-func (t *Time) Scan(v interface{}) error {
-	stringTime, ok := v.(string)
-	if ok {
-		vt, err := time.Parse(DateFormat, stringTime)
+// storedLayouts are the layouts times are read from the db and from json in. Times without
+// an offset are in UTC.
+var storedLayouts = []string{time.RFC3339Nano, DateFormat, sqliteDriverFormat}
 
-		if err != nil {
-			return err
+// Scan is to conform to StructScan. sqlite stores times as strings, postgres as timestamps.
+func (t *Time) Scan(v interface{}) error {
+	switch value := v.(type) {
+	case time.Time:
+		*t = Time(value.UTC())
+		return nil
+	case []byte:
+		return t.Scan(string(value))
+	case string:
+		for _, layout := range storedLayouts {
+			if vt, err := time.Parse(layout, value); err == nil {
+				*t = Time(vt.UTC())
+				return nil
+			}
 		}
-
-		*t = Time(vt)
+	case nil:
+		*t = Time{}
 		return nil
 	}
 	log.Printf("Error parsing time %v", v)
 	return errors.New("Could not parse")
 }
 
+// String is the time in UTC, in RFC 3339. It is the form times are stored in.
 func (t Time) String() string {
-	return time.Time(t).Format(DateFormat)
+	return time.Time(t).UTC().Format(time.RFC3339)
+}
+
+// Display is the time in the timezone of the user, in RFC 3339
+func (t Time) Display() string {
+	return time.Time(t).In(location).Format(time.RFC3339)
 }
 
-// MarshalJSON is json encoding for Time
+// MarshalJSON is json encoding for Time, in the timezone of the user
 func (t Time) MarshalJSON() ([]byte, error) {
-	return []byte("\"" + t.String() + "\""), nil
+	return []byte("\"" + t.Display() + "\""), nil
+
+}
 
+// UnmarshalJSON reads a Time back from its json encoding
+func (t *Time) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return t.Scan(s)
 }
 
 // Value driver
 func (t Time) Value() (driver.Value, error) {
-	return time.Time(t), nil
+	return t.String(), nil
 }
 
 // Duration is just an alias for time.Duration
@@ -48,6 +75,9 @@ type Duration time.Duration
 
 // Scan is for use in StructScan in repository layers.
 func (d *Duration) Scan(v interface{}) error {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
 	stringDuration, ok := v.(string)
 	if ok {
 		vd, err := time.ParseDuration(stringDuration)
@@ -78,3 +108,12 @@ func (d Duration) MarshalJSON() ([]byte, error) {
 	return []byte("\"" + d.String() + "\""), nil
 
 }
+
+// UnmarshalJSON reads a Duration back from its json encoding
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return d.Scan(s)
+}
@@ -0,0 +1,179 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// location is the timezone of the user. Times without an offset are read in it, and times are
+// rendered in it.
+var location = time.UTC
+
+// SetTimezone sets the timezone of the user, by its IANA name like Europe/Paris. An empty name
+// is UTC.
+func SetTimezone(name string) error {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("Invalid timezone %q: %s", name, err.Error())
+	}
+	location = loc
+	return nil
+}
+
+// Location is the timezone of the user, set by SetTimezone
+func Location() *time.Location {
+	return location
+}
+
+// localLayouts are the layouts of times without an offset, which are in the timezone of the user
+var localLayouts = []string{
+	DateFormat,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	dateOnlyFormat,
+}
+
+var (
+	offsetPattern    = regexp.MustCompile(`^([+-]\d+)\s*(m|min|mins|h|hr|hrs|d|day|days|w|wk|week|weeks)$`)
+	timeOfDayPattern = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+)
+
+var dayNames = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// TimeFormats describes the forms ParseTime accepts, for error messages
+const TimeFormats = "RFC 3339 like 2030-01-02T15:04:05+01:00, 2030-01-02 15:04:05, 2030-01-02, now, +3d, -2h, tomorrow 5pm or next friday 9:30"
+
+// ParseTime reads a time given by a user, and returns it in UTC. It accepts
+//   - RFC 3339, with its own offset
+//   - 2006-01-02 15:04:05 and 2006-01-02, optionally with a T and without seconds, in the timezone of the user
+//   - now, or an offset from now in minutes, hours, days or weeks, like +3d or -2h
+//   - today, tomorrow, yesterday, a day of the week like friday or next friday (both the first
+//     friday after today), optionally followed by a time of day like 5pm, 17:30, noon or at 9am
+//
+// Dates without a time of day are at midnight, at the start of the day, in the timezone of the user.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, s, location); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if t, ok := parseRelative(strings.ToLower(s), now.In(location)); ok {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid time %q, use %s", s, TimeFormats)
+}
+
+// parseRelative reads the relative forms of ParseTime, from now in the timezone of the user
+func parseRelative(s string, now time.Time) (time.Time, bool) {
+	if s == "now" {
+		return now, true
+	}
+	if m := offsetPattern.FindStringSubmatch(strings.Replace(s, " ", "", 1)); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, false
+		}
+		switch m[2][0] {
+		case 'm':
+			return now.Add(time.Duration(n) * time.Minute), true
+		case 'h':
+			return now.Add(time.Duration(n) * time.Hour), true
+		case 'd':
+			return now.AddDate(0, 0, n), true
+		default:
+			return now.AddDate(0, 0, 7*n), true
+		}
+	}
+
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return time.Time{}, false
+	}
+	days := 0
+	switch words[0] {
+	case "today":
+	case "tomorrow":
+		days = 1
+	case "yesterday":
+		days = -1
+	case "next":
+		words = words[1:]
+		if len(words) == 0 {
+			return time.Time{}, false
+		}
+		fallthrough
+	default:
+		day, ok := dayNames[words[0]]
+		if !ok {
+			return time.Time{}, false
+		}
+		days = (int(day)-int(now.Weekday())+6)%7 + 1
+	}
+	words = words[1:]
+	if len(words) > 0 && words[0] == "at" {
+		words = words[1:]
+	}
+
+	hour, minute := 0, 0
+	if len(words) > 0 {
+		var ok bool
+		if hour, minute, ok = parseTimeOfDay(strings.Join(words, " ")); !ok {
+			return time.Time{}, false
+		}
+	}
+	return time.Date(now.Year(), now.Month(), now.Day()+days, hour, minute, 0, 0, now.Location()), true
+}
+
+// parseTimeOfDay reads times of day like 5pm, 5:30 pm, 17:30, noon and midnight
+func parseTimeOfDay(s string) (int, int, bool) {
+	switch s {
+	case "noon":
+		return 12, 0, true
+	case "midnight":
+		return 0, 0, true
+	}
+	m := timeOfDayPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, false
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	switch m[3] {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if m[3] == "pm" {
+			hour += 12
+		}
+	case "":
+		// a bare number is not a time, 17 could be anything
+		if m[2] == "" {
+			return 0, 0, false
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
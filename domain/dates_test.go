@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	if err := SetTimezone("America/New_York"); err != nil {
+		t.Skip(err)
+	}
+	defer SetTimezone("UTC")
+	ny := Location()
+	// a wednesday afternoon in New York
+	now := time.Date(2030, 1, 30, 15, 30, 0, 0, ny)
+
+	cases := map[string]time.Time{
+		"2030-02-01T10:00:00+01:00": time.Date(2030, 2, 1, 9, 0, 0, 0, time.UTC),
+		"2030-02-01 17:00:00":       time.Date(2030, 2, 1, 17, 0, 0, 0, ny),
+		"2030-02-01T17:00":          time.Date(2030, 2, 1, 17, 0, 0, 0, ny),
+		"2030-02-01":                time.Date(2030, 2, 1, 0, 0, 0, 0, ny),
+		"now":                       now,
+		"+3d":                       time.Date(2030, 2, 2, 15, 30, 0, 0, ny),
+		"-2h":                       time.Date(2030, 1, 30, 13, 30, 0, 0, ny),
+		"+1 week":                   time.Date(2030, 2, 6, 15, 30, 0, 0, ny),
+		"today":                     time.Date(2030, 1, 30, 0, 0, 0, 0, ny),
+		"Tomorrow 5pm":              time.Date(2030, 1, 31, 17, 0, 0, 0, ny),
+		"yesterday at 12:15am":      time.Date(2030, 1, 29, 0, 15, 0, 0, ny),
+		"friday noon":               time.Date(2030, 2, 1, 12, 0, 0, 0, ny),
+		"next wednesday 9:30":       time.Date(2030, 2, 6, 9, 30, 0, 0, ny),
+	}
+	for input, want := range cases {
+		got, err := ParseTime(input, now)
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("%s: got %v, want %v", input, got, want.UTC())
+		}
+	}
+
+	for _, input := range []string{"", "2030-02-30", "soon", "tomorrow 17", "next", "friday 13pm", "+3y"} {
+		if _, err := ParseTime(input, now); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestTimeRendering(t *testing.T) {
+	if err := SetTimezone("Asia/Kolkata"); err != nil {
+		t.Skip(err)
+	}
+	defer SetTimezone("UTC")
+	due := Time(time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC))
+	if due.String() != "2030-01-02T15:00:00Z" {
+		t.Errorf("got %s", due.String())
+	}
+	j, _ := due.MarshalJSON()
+	if string(j) != `"2030-01-02T20:30:00+05:30"` {
+		t.Errorf("got %s", j)
+	}
+
+	var back Time
+	if err := back.UnmarshalJSON(j); err != nil || !time.Time(back).Equal(time.Time(due)) {
+		t.Errorf("got %v, %v", back, err)
+	}
+	for _, stored := range []interface{}{"2030-01-02 15:00:00", "2030-01-02T15:00:00Z", []byte("2030-01-02 15:00:00+00:00"), time.Time(due).In(Location())} {
+		var scanned Time
+		if err := scanned.Scan(stored); err != nil || scanned != due {
+			t.Errorf("%v: got %v, %v", stored, scanned, err)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency of a recurrence
+type Frequency string
+
+const (
+	// Daily repeats every INTERVAL days
+	Daily Frequency = "DAILY"
+	// Weekly repeats every INTERVAL weeks, on the days of ByDay
+	Weekly Frequency = "WEEKLY"
+	// Monthly repeats every INTERVAL months, on ByMonthDay
+	Monthly Frequency = "MONTHLY"
+	// Yearly repeats every INTERVAL years
+	Yearly Frequency = "YEARLY"
+)
+
+// untilFormat is the UTC date-time layout of UNTIL in RFC 5545
+const untilFormat = "20060102T150405Z"
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Recurrence is a subset of the RFC 5545 RRULE: FREQ, INTERVAL, BYDAY (weekly only),
+// BYMONTHDAY (monthly only, negative counts from the end of the month) and UNTIL.
+type Recurrence struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay int
+	Until      *time.Time
+}
+
+// ParseRecurrence parses either a RRULE, with or without the "RRULE:" prefix, or one of
+// the short forms "daily", "weekly", "weekly:MO,TH", "monthly", "monthly:15" and "yearly".
+func ParseRecurrence(s string) (Recurrence, error) {
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+	switch {
+	case lower == "daily":
+		return Recurrence{Freq: Daily, Interval: 1}, nil
+	case lower == "weekly":
+		return Recurrence{Freq: Weekly, Interval: 1}, nil
+	case lower == "monthly":
+		return Recurrence{Freq: Monthly, Interval: 1}, nil
+	case lower == "yearly":
+		return Recurrence{Freq: Yearly, Interval: 1}, nil
+	case strings.HasPrefix(lower, "weekly:"):
+		return parseRRule("FREQ=WEEKLY;BYDAY=" + s[len("weekly:"):])
+	case strings.HasPrefix(lower, "monthly:"):
+		return parseRRule("FREQ=MONTHLY;BYMONTHDAY=" + s[len("monthly:"):])
+	}
+	return parseRRule(strings.TrimPrefix(s, "RRULE:"))
+}
+
+func parseRRule(rule string) (Recurrence, error) {
+	r := Recurrence{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return r, fmt.Errorf("Invalid recurrence rule part %q", part)
+		}
+		key, value := strings.ToUpper(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		switch key {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return r, fmt.Errorf("Invalid recurrence interval %q", value)
+			}
+			r.Interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				day = strings.ToUpper(strings.TrimSpace(day))
+				if len(day) > 2 {
+					// allow MON or MONDAY as well as MO
+					day = day[:2]
+				}
+				weekday, ok := weekdays[day]
+				if !ok {
+					return r, fmt.Errorf("Invalid recurrence weekday %q", day)
+				}
+				r.ByDay = append(r.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			day, err := strconv.Atoi(value)
+			if err != nil || day == 0 || day > 31 || day < -31 {
+				return r, fmt.Errorf("Invalid recurrence month day %q", value)
+			}
+			r.ByMonthDay = day
+		case "UNTIL":
+			until, err := time.Parse(untilFormat, value)
+			if err != nil {
+				// a date alone includes the whole day
+				until, err = time.Parse("20060102", value)
+				until = until.Add(24*time.Hour - time.Second)
+			}
+			if err != nil {
+				return r, fmt.Errorf("Invalid recurrence end %q", value)
+			}
+			r.Until = &until
+		default:
+			return r, fmt.Errorf("Unsupported recurrence rule part %s", key)
+		}
+	}
+
+	switch r.Freq {
+	case Daily, Yearly:
+		if len(r.ByDay) > 0 || r.ByMonthDay != 0 {
+			return r, fmt.Errorf("BYDAY and BYMONTHDAY are not supported for %s recurrence", r.Freq)
+		}
+	case Weekly:
+		if r.ByMonthDay != 0 {
+			return r, fmt.Errorf("BYMONTHDAY is only supported for MONTHLY recurrence")
+		}
+	case Monthly:
+		if len(r.ByDay) > 0 {
+			return r, fmt.Errorf("BYDAY is only supported for WEEKLY recurrence")
+		}
+		// an interval of 12 months with BYMONTHDAY=31 never occurs when started in april
+		for month := time.January; month <= time.December; month++ {
+			if r.nextMonthly(time.Date(2001, month, 1, 0, 0, 0, 0, time.UTC), r.Interval).IsZero() {
+				return r, fmt.Errorf("BYMONTHDAY=%d never occurs every %d months from %s", r.ByMonthDay, r.Interval, month)
+			}
+		}
+	default:
+		return r, fmt.Errorf("Recurrence frequency has to be one of DAILY, WEEKLY, MONTHLY and YEARLY")
+	}
+	sort.Slice(r.ByDay, func(i, j int) bool { return mondayFirst(r.ByDay[i]) < mondayFirst(r.ByDay[j]) })
+	return r, nil
+}
+
+// mondayFirst numbers weekdays from monday, the default week start of RFC 5545
+func mondayFirst(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+// Next returns the first occurrence strictly after from, keeping the time of day of from.
+// It returns false once the recurrence has ended.
+func (r Recurrence) Next(from time.Time) (time.Time, bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	var next time.Time
+	switch r.Freq {
+	case Daily:
+		next = from.AddDate(0, 0, interval)
+	case Weekly:
+		next = r.nextWeekly(from, interval)
+	case Monthly:
+		if next = r.nextMonthly(from, interval); next.IsZero() {
+			return time.Time{}, false
+		}
+	case Yearly:
+		next = from.AddDate(interval, 0, 0)
+	default:
+		return time.Time{}, false
+	}
+
+	if r.Until != nil && next.After(*r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func (r Recurrence) nextWeekly(from time.Time, interval int) time.Time {
+	if len(r.ByDay) == 0 {
+		return from.AddDate(0, 0, 7*interval)
+	}
+	weekStart := from.AddDate(0, 0, -mondayFirst(from.Weekday()))
+	for week := 0; ; week += interval {
+		for _, day := range r.ByDay {
+			candidate := weekStart.AddDate(0, 0, 7*week+mondayFirst(day))
+			if candidate.After(from) {
+				return candidate
+			}
+		}
+	}
+}
+
+// maxMonthlySteps bounds the intervals searched for a month having the day of a monthly
+// recurrence. 48 covers every cycle of months, leap years included.
+const maxMonthlySteps = 48
+
+// nextMonthly returns the zero time when none of the months of the next maxMonthlySteps
+// intervals has the day of the recurrence.
+func (r Recurrence) nextMonthly(from time.Time, interval int) time.Time {
+	monthDay := r.ByMonthDay
+	if monthDay == 0 {
+		monthDay = from.Day()
+	}
+	firstOfMonth := time.Date(from.Year(), from.Month(), 1, from.Hour(), from.Minute(), from.Second(), 0, from.Location())
+	for step := 0; step <= maxMonthlySteps; step++ {
+		first := firstOfMonth.AddDate(0, step*interval, 0)
+		daysInMonth := first.AddDate(0, 1, -1).Day()
+		day := monthDay
+		if day < 0 {
+			day = daysInMonth + day + 1
+		}
+		// months without this day are skipped, like RFC 5545 does
+		if day < 1 || day > daysInMonth {
+			continue
+		}
+		candidate := first.AddDate(0, 0, day-1)
+		if candidate.After(from) {
+			return candidate
+		}
+	}
+	return time.Time{}
+}
+
+// String returns the recurrence as a RRULE, without the "RRULE:" prefix
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, d := range r.ByDay {
+			days = append(days, strings.ToUpper(d.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.ByMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.ByMonthDay))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilFormat))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Scan is for use in StructScan in repository layers.
+func (r *Recurrence) Scan(v interface{}) error {
+	var s string
+	switch value := v.(type) {
+	case string:
+		s = value
+	case []byte:
+		s = string(value)
+	default:
+		return errors.New("Could not parse recurrence")
+	}
+	parsed, err := ParseRecurrence(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Value driver
+func (r Recurrence) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// MarshalJSON is json encoding for Recurrence. It is encoded as its RRULE.
+func (r Recurrence) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON accepts everything ParseRecurrence does
+func (r *Recurrence) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseRecurrence(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	cases := map[string]string{
+		"daily":          "FREQ=DAILY",
+		"Weekly:mon,thu": "FREQ=WEEKLY;BYDAY=MO,TH",
+		"monthly:-1":     "FREQ=MONTHLY;BYMONTHDAY=-1",
+		"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=FR,MO": "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR",
+		"FREQ=DAILY;UNTIL=20301231":                "FREQ=DAILY;UNTIL=20301231T235959Z",
+		"FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=29":   "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=29",
+	}
+	for input, want := range cases {
+		r, err := ParseRecurrence(input)
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if r.String() != want {
+			t.Errorf("%s: got %s, want %s", input, r.String(), want)
+		}
+	}
+
+	for _, input := range []string{"", "hourly", "FREQ=DAILY;BYDAY=MO", "FREQ=WEEKLY;BYDAY=XX", "FREQ=MONTHLY;BYMONTHDAY=32", "FREQ=DAILY;COUNT=3", "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=31"} {
+		if _, err := ParseRecurrence(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	// a wednesday
+	from := time.Date(2030, 1, 30, 9, 30, 0, 0, time.UTC)
+	cases := []struct {
+		rule string
+		want time.Time
+	}{
+		{"daily", time.Date(2030, 1, 31, 9, 30, 0, 0, time.UTC)},
+		{"FREQ=DAILY;INTERVAL=3", time.Date(2030, 2, 2, 9, 30, 0, 0, time.UTC)},
+		{"weekly", time.Date(2030, 2, 6, 9, 30, 0, 0, time.UTC)},
+		{"weekly:MO,FR", time.Date(2030, 2, 1, 9, 30, 0, 0, time.UTC)},
+		{"weekly:MO,WE", time.Date(2030, 2, 4, 9, 30, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", time.Date(2030, 2, 11, 9, 30, 0, 0, time.UTC)},
+		{"monthly", time.Date(2030, 3, 30, 9, 30, 0, 0, time.UTC)},
+		{"monthly:31", time.Date(2030, 1, 31, 9, 30, 0, 0, time.UTC)},
+		{"monthly:-1", time.Date(2030, 1, 31, 9, 30, 0, 0, time.UTC)},
+		{"monthly:15", time.Date(2030, 2, 15, 9, 30, 0, 0, time.UTC)},
+		{"yearly", time.Date(2031, 1, 30, 9, 30, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		r, err := ParseRecurrence(c.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", c.rule, err)
+		}
+		got, ok := r.Next(from)
+		if !ok || !got.Equal(c.want) {
+			t.Errorf("%s: got %v, want %v", c.rule, got, c.want)
+		}
+	}
+
+	// february has no 30th, so "monthly" from the 30th of january skips to march
+	r, _ := ParseRecurrence("monthly:30")
+	if got, _ := r.Next(time.Date(2030, 1, 30, 0, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2030, 3, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("monthly:30 got %v", got)
+	}
+
+	r, _ = ParseRecurrence("FREQ=DAILY;UNTIL=20300131")
+	if _, ok := r.Next(from); !ok {
+		t.Error("expected one more occurrence before UNTIL")
+	}
+	if _, ok := r.Next(from.AddDate(0, 0, 1)); ok {
+		t.Error("expected no occurrence after UNTIL")
+	}
+
+	// no april has a 31st, the search gives up instead of looping for ever
+	r = Recurrence{Freq: Monthly, Interval: 12, ByMonthDay: 31}
+	if got, ok := r.Next(time.Date(2030, 4, 10, 0, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=31 from april got %v", got)
+	}
+	// the 29th of february comes back with the next leap year
+	r, _ = ParseRecurrence("FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=29")
+	if got, _ := r.Next(time.Date(2029, 2, 10, 0, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2032, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=29 got %v", got)
+	}
+}
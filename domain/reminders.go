@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Reminder is a notification that a task is coming due. It is sent LeadTime before the due date
+// of the task, and once more when the task is due, with a LeadTime of 0.
+type Reminder struct {
+	Task     Task     `json:"task"`
+	LeadTime Duration `json:"leadTime"`
+}
+
+// Message describes the reminder in a sentence
+func (r Reminder) Message() string {
+	if r.LeadTime == 0 {
+		return fmt.Sprintf("Task %q is due at %s", r.Task.Title, r.Task.DueDate.Display())
+	}
+	return fmt.Sprintf("Task %q is due in %s, at %s", r.Task.Title, r.LeadTime, r.Task.DueDate.Display())
+}
+
+// MaxLeadTime is the longest a reminder can be sent before a task is due
+const MaxLeadTime = 30 * 24 * time.Hour
+
+// SentReminder records that the reminder of a task went out through a notifier. Reminders are
+// tracked by the due date they were sent for, so that a task which is due again, because it was
+// rescheduled or recurs, is reminded again.
+type SentReminder struct {
+	TaskID   int64    `json:"taskId" db:"taskId"`
+	DueDate  Time     `json:"dueDate" db:"dueDate"`
+	LeadTime Duration `json:"leadTime" db:"leadTime"`
+	Notifier string   `json:"notifier"`
+	Sent     Time     `json:"sent"`
+}
+
+// SortLeadTimes sorts lead times, shortest first, and drops the repeated ones. It never returns nil.
+func SortLeadTimes(leadTimes []Duration) []Duration {
+	sorted := make([]Duration, 0, len(leadTimes))
+	sorted = append(sorted, leadTimes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	unique := sorted[:0]
+	for i, d := range sorted {
+		if i == 0 || d != sorted[i-1] {
+			unique = append(unique, d)
+		}
+	}
+	return unique
+}
+
+// DueReminder returns the lead time of the reminder of task which is due at now, among leadTimes
+// and the due date itself. Only the shortest lead time which is reached counts, so that a task
+// which is overdue is not reminded that it is coming due. ok is false when there is no such
+// reminder, or when it is in sent already.
+func DueReminder(task Task, leadTimes []Duration, sent []SentReminder, now time.Time) (leadTime Duration, ok bool) {
+	due := time.Time(task.DueDate)
+	if due.IsZero() {
+		return 0, false
+	}
+	found := false
+	for _, lead := range append([]Duration{0}, leadTimes...) {
+		if lead < 0 || due.Add(-time.Duration(lead)).After(now) {
+			continue
+		}
+		if !found || lead < leadTime {
+			leadTime, found = lead, true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	for _, s := range sent {
+		if s.TaskID == task.Rowid && s.LeadTime == leadTime && time.Time(s.DueDate).Equal(due) {
+			return 0, false
+		}
+	}
+	return leadTime, true
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDueReminder(t *testing.T) {
+	due := time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)
+	task := Task{Rowid: 3, DueDate: Time(due)}
+	leadTimes := SortLeadTimes([]Duration{Duration(24 * time.Hour), Duration(time.Hour), Duration(time.Hour)})
+	if len(leadTimes) != 2 || leadTimes[0] != Duration(time.Hour) {
+		t.Fatalf("got lead times %v, want 1h and 24h", leadTimes)
+	}
+
+	cases := []struct {
+		now      time.Time
+		sent     []SentReminder
+		leadTime Duration
+		ok       bool
+	}{
+		{now: due.Add(-48 * time.Hour)},
+		{now: due.Add(-24 * time.Hour), leadTime: Duration(24 * time.Hour), ok: true},
+		{now: due.Add(-30 * time.Minute), leadTime: Duration(time.Hour), ok: true},
+		{now: due.Add(time.Hour), leadTime: 0, ok: true},
+		{now: due.Add(-30 * time.Minute), sent: []SentReminder{{TaskID: 3, DueDate: Time(due), LeadTime: Duration(time.Hour)}}},
+		// a reminder sent for another due date does not count
+		{now: due.Add(-30 * time.Minute), sent: []SentReminder{{TaskID: 3, DueDate: Time(due.Add(-24 * time.Hour)), LeadTime: Duration(time.Hour)}}, leadTime: Duration(time.Hour), ok: true},
+	}
+	for i, c := range cases {
+		leadTime, ok := DueReminder(task, leadTimes, c.sent, c.now)
+		if leadTime != c.leadTime || ok != c.ok {
+			t.Errorf("case %d: got %v, %v, want %v, %v", i, leadTime, ok, c.leadTime, c.ok)
+		}
+	}
+
+	if _, ok := DueReminder(Task{Rowid: 4}, leadTimes, nil, due); ok {
+		t.Errorf("a task without a due date should not be reminded")
+	}
+}
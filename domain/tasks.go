@@ -6,22 +6,70 @@ import (
 
 // Task is for storing tasks. Every task should have a title, with
 // an optional description, as well as a due date and status.
-// Also,  a priority
+// Also,  a priority. A task can be a subtask of another task, its parent.
+// Tags are stored separately from the task, and are sorted alphabetically.
+// A task with a Recurrence comes back with its next due date when it is done.
+// A deleted task stays in the trash, with its DeletedAt set, until it is purged.
+// Reminders are the lead times before the due date at which the task is reminded, sorted.
 type Task struct {
-	Rowid       int64    `json:"rowid"`
-	Title       string   `json:"title"`
-	Description string   `json:"description"`
-	DueDate     Time     `json:"dueDate" db:"dueDate"`
-	Status      Status   `json:"status"`
-	Priority    Priority `json:"priority"`
-	Effort      Duration `json:"effort"`
-	Created     Time     `json:"created"`
+	Rowid       int64       `json:"rowid"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	DueDate     Time        `json:"dueDate" db:"dueDate"`
+	Status      Status      `json:"status"`
+	Priority    Priority    `json:"priority"`
+	Effort      Duration    `json:"effort"`
+	Created     Time        `json:"created"`
+	ParentID    *int64      `json:"parentId" db:"parentId"`
+	Tags        []string    `json:"tags" db:"-"`
+	Recurrence  *Recurrence `json:"recurrence" db:"recurrence"`
+	Reminders   []Duration  `json:"reminders" db:"-"`
+	DeletedAt   *Time       `json:"deletedAt,omitempty" db:"deletedAt"`
+}
+
+// Completion records one completed occurrence of a recurring task
+type Completion struct {
+	Rowid     int64 `json:"rowid"`
+	TaskID    int64 `json:"taskId" db:"taskId"`
+	DueDate   Time  `json:"dueDate" db:"dueDate"`
+	Completed Time  `json:"completed"`
+}
+
+// HistoryEntry records one status transition of a task, and who made it
+type HistoryEntry struct {
+	Rowid     int64  `json:"rowid"`
+	TaskID    int64  `json:"taskId" db:"taskId"`
+	OldStatus Status `json:"oldStatus" db:"oldStatus"`
+	NewStatus Status `json:"newStatus" db:"newStatus"`
+	Changed   Time   `json:"changed"`
+	Username  string `json:"username"`
 }
 
 func (t Task) String() string {
 	return fmt.Sprintf(`Task:{"title":"%s", dueDate:"%s"}`, t.Title, t.DueDate)
 }
 
+// Dependency means that task TaskID cannot be started until task BlockedBy is done
+type Dependency struct {
+	TaskID    int64 `json:"taskId" db:"taskId"`
+	BlockedBy int64 `json:"blockedBy" db:"blockedBy"`
+}
+
+// SearchResult is a task matching a full text search. Snippet is the best matching part of
+// its title or description, as escaped HTML with the matching words in <b> tags. A higher Score
+// is a better match.
+type SearchResult struct {
+	Task    Task    `json:"task"`
+	Snippet string  `json:"snippet"`
+	Score   float64 `json:"score"`
+}
+
+// TagCount is the number of tasks having a tag
+type TagCount struct {
+	Tag   string `json:"tag" db:"tag"`
+	Count int64  `json:"count" db:"count"`
+}
+
 // Status is an enum - NotStarted, Doing, Finished
 type Status string
 
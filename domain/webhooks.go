@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// TaskEvent is a change in the life of a task, which webhooks and event streams can subscribe to
+type TaskEvent string
+
+const (
+	// EventTaskCreated is sent when a task is added, or comes back from the trash or a purge
+	EventTaskCreated TaskEvent = "task.created"
+	// EventTaskUpdated is sent when any field of a task changes
+	EventTaskUpdated TaskEvent = "task.updated"
+	// EventTaskStatusChanged is sent along with EventTaskUpdated when the status changes
+	EventTaskStatusChanged TaskEvent = "task.status_changed"
+	// EventTaskDeleted is sent when a task is moved to the trash
+	EventTaskDeleted TaskEvent = "task.deleted"
+)
+
+// TaskEvents are all the events, in the order they are documented
+var TaskEvents = []TaskEvent{EventTaskCreated, EventTaskUpdated, EventTaskStatusChanged, EventTaskDeleted}
+
+// Events returns the webhook events of a change of a task. old is nil for a new task, or one
+// taken out of the trash, and task is nil for a deleted one.
+func Events(old, task *Task) []TaskEvent {
+	switch {
+	case task == nil:
+		return []TaskEvent{EventTaskDeleted}
+	case old == nil:
+		return []TaskEvent{EventTaskCreated}
+	case old.Status != task.Status:
+		return []TaskEvent{EventTaskUpdated, EventTaskStatusChanged}
+	}
+	return []TaskEvent{EventTaskUpdated}
+}
+
+// EventList is stored as a json array
+type EventList []TaskEvent
+
+// Has tells if the list has event. An empty list has every event.
+func (l EventList) Has(event TaskEvent) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, e := range l {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan is for use in StructScan in repository layers.
+func (l *EventList) Scan(v interface{}) error {
+	return scanJSON(v, l)
+}
+
+// Value driver
+func (l EventList) Value() (driver.Value, error) {
+	if l == nil {
+		l = EventList{}
+	}
+	b, err := json.Marshal(l)
+	return string(b), err
+}
+
+// Webhook is a URL which is sent the events of tasks it subscribed to, every event when Events
+// is empty. Deliveries are signed with Secret, which is never shown once the webhook is created.
+type Webhook struct {
+	Rowid   int64     `json:"rowid"`
+	URL     string    `json:"url"`
+	Secret  string    `json:"-"`
+	Events  EventList `json:"events"`
+	Created Time      `json:"created"`
+}
+
+// DeliveryStatus tells where a delivery is in its life
+type DeliveryStatus string
+
+const (
+	// DeliveryPending is a delivery which has yet to go out, or is waiting for a retry
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDelivered is a delivery which the webhook accepted
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed is a delivery which failed MaxDeliveryAttempts times. It can be replayed.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+const (
+	// MaxDeliveryAttempts is how many times a delivery is tried before it fails
+	MaxDeliveryAttempts = 6
+	// firstRetryDelay is the wait before the second attempt. It doubles on every attempt after.
+	firstRetryDelay = 30 * time.Second
+)
+
+// WebhookDelivery is an event on its way to a webhook. Payload is the json body, kept as it was
+// first built so that every attempt and replay sends the same bytes. LastStatus is the http status
+// of the last attempt, 0 if it got no answer, and LastError why it failed.
+type WebhookDelivery struct {
+	Rowid       int64          `json:"rowid"`
+	WebhookID   int64          `json:"webhookId" db:"webhookId"`
+	Event       TaskEvent      `json:"event"`
+	Payload     string         `json:"payload"`
+	Status      DeliveryStatus `json:"status"`
+	Attempts    int            `json:"attempts"`
+	NextAttempt Time           `json:"nextAttempt" db:"nextAttempt"`
+	LastStatus  int            `json:"lastStatus" db:"lastStatus"`
+	LastError   string         `json:"lastError" db:"lastError"`
+	Created     Time           `json:"created"`
+}
+
+// Delivered records an attempt which the webhook accepted with status
+func (d *WebhookDelivery) Delivered(status int) {
+	d.Attempts++
+	d.Status = DeliveryDelivered
+	d.LastStatus = status
+	d.LastError = ""
+}
+
+// Failed records an attempt at a time which failed, with the http status if there was an
+// answer. The next attempt waits twice as long as the one before, until the delivery has been
+// tried MaxDeliveryAttempts times and fails for good.
+func (d *WebhookDelivery) Failed(at time.Time, status int, err error) {
+	d.Attempts++
+	d.LastStatus = status
+	d.LastError = err.Error()
+	if d.Attempts >= MaxDeliveryAttempts {
+		d.Status = DeliveryFailed
+		return
+	}
+	d.Status = DeliveryPending
+	d.NextAttempt = Time(at.Add(firstRetryDelay << uint(d.Attempts-1)))
+}
+
+// Replay makes a failed delivery pending again, to be tried from now with a fresh set of attempts
+func (d *WebhookDelivery) Replay(now time.Time) {
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.NextAttempt = Time(now)
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	pending := Task{Title: "a", Status: Pending}
+	renamed := Task{Title: "b", Status: Pending}
+	done := Task{Title: "a", Status: Done}
+	cases := []struct {
+		old, task *Task
+		want      string
+	}{
+		{nil, &pending, "[task.created]"},
+		{&pending, &renamed, "[task.updated]"},
+		{&pending, &done, "[task.updated task.status_changed]"},
+		{&pending, nil, "[task.deleted]"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(Events(c.old, c.task)); got != c.want {
+			t.Errorf("got %s, want %s", got, c.want)
+		}
+	}
+
+	if !(EventList{}).Has(EventTaskDeleted) || (EventList{EventTaskCreated}).Has(EventTaskDeleted) {
+		t.Errorf("an empty list should have every event, and others only theirs")
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := WebhookDelivery{Status: DeliveryPending, NextAttempt: Time(now)}
+	wait := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute}
+	for i, w := range wait {
+		d.Failed(now, 503, errors.New("unavailable"))
+		if d.Status != DeliveryPending || d.Attempts != i+1 || !time.Time(d.NextAttempt).Equal(now.Add(w)) {
+			t.Fatalf("attempt %d: got %+v, want a retry in %s", i+1, d, w)
+		}
+	}
+	d.Failed(now, 0, errors.New("refused"))
+	if d.Status != DeliveryFailed || d.Attempts != MaxDeliveryAttempts || d.LastError != "refused" {
+		t.Errorf("got %+v, want a failed delivery", d)
+	}
+
+	d.Replay(now)
+	if d.Status != DeliveryPending || d.Attempts != 0 {
+		t.Errorf("got %+v after a replay", d)
+	}
+	d.Delivered(200)
+	if d.Status != DeliveryDelivered || d.Attempts != 1 || d.LastStatus != 200 || d.LastError != "" {
+		t.Errorf("got %+v, want a delivered delivery", d)
+	}
+}
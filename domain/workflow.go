@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"fmt"
+
+	"server/errors"
+)
+
+// Workflow is the graph of allowed status transitions. Transitions lists the statuses a task can
+// move to from each status. Reopens lists the transitions which are only allowed when a task is
+// explicitly reopened, like Done to Pending.
+type Workflow struct {
+	Transitions map[Status][]Status
+	Reopens     map[Status][]Status
+}
+
+// DefaultWorkflow lets a task move freely between Pending and In-Progress, and to Done from
+// either of them. A done task has to be reopened.
+var DefaultWorkflow = Workflow{
+	Transitions: map[Status][]Status{
+		Pending:    {InProgress, Done},
+		InProgress: {Pending, Done},
+	},
+	Reopens: map[Status][]Status{
+		Done: {Pending, InProgress},
+	},
+}
+
+// NewWorkflow builds a Workflow from the string form used in configuration, like
+// {"Pending": ["In-Progress"]}. Every status has to be a valid Status.
+func NewWorkflow(transitions, reopens map[string][]string) (Workflow, error) {
+	w := Workflow{Transitions: make(map[Status][]Status), Reopens: make(map[Status][]Status)}
+	if err := addTransitions(w.Transitions, transitions); err != nil {
+		return w, err
+	}
+	if err := addTransitions(w.Reopens, reopens); err != nil {
+		return w, err
+	}
+	return w, nil
+}
+
+func addTransitions(graph map[Status][]Status, transitions map[string][]string) error {
+	for from, tos := range transitions {
+		if !Status(from).IsValid() {
+			return fmt.Errorf("Invalid status %q in workflow", from)
+		}
+		for _, to := range tos {
+			if !Status(to).IsValid() {
+				return fmt.Errorf("Invalid status %q in workflow", to)
+			}
+			graph[Status(from)] = append(graph[Status(from)], Status(to))
+		}
+	}
+	return nil
+}
+
+// IsValid checks that s is one of Pending, In-Progress and Done
+func (s Status) IsValid() bool {
+	return s == Pending || s == InProgress || s == Done
+}
+
+// CheckTransition returns an error if a task cannot move from one status to another.
+// Staying in the same status is always allowed. Reopen transitions are only allowed when
+// reopen is true.
+func (w Workflow) CheckTransition(from, to Status, reopen bool) error {
+	if from == to {
+		return nil
+	}
+	if contains(w.Transitions[from], to) {
+		return nil
+	}
+	if contains(w.Reopens[from], to) {
+		if reopen {
+			return nil
+		}
+		return errors.ErrorConflict.WithMessage("Task has to be reopened to move from %s to %s", from, to)
+	}
+	return errors.ErrorConflict.WithMessage("Task cannot move from %s to %s", from, to)
+}
+
+func contains(statuses []Status, s Status) bool {
+	for _, status := range statuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
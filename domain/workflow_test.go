@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"testing"
+)
+
+func TestWorkflowCheckTransition(t *testing.T) {
+	cases := []struct {
+		from, to Status
+		reopen   bool
+		allowed  bool
+	}{
+		{Pending, InProgress, false, true},
+		{InProgress, Done, false, true},
+		{Done, Done, false, true},
+		{Done, Pending, false, false},
+		{Done, Pending, true, true},
+	}
+	for _, c := range cases {
+		err := DefaultWorkflow.CheckTransition(c.from, c.to, c.reopen)
+		if (err == nil) != c.allowed {
+			t.Errorf("%s -> %s (reopen %v): got %v", c.from, c.to, c.reopen, err)
+		}
+	}
+
+	w, err := NewWorkflow(map[string][]string{"Pending": {"In-Progress"}, "In-Progress": {"Done"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.CheckTransition(Pending, Done, false); err == nil {
+		t.Error("expected Pending -> Done to be rejected")
+	}
+	if _, err := NewWorkflow(map[string][]string{"Pending": {"Blocked"}}, nil); err == nil {
+		t.Error("expected an error for an unknown status")
+	}
+}
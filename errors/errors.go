@@ -2,6 +2,10 @@
 package errors
 
 import (
+	"errors"
+	"fmt"
+	"log"
+
 	"server/utils"
 )
 
@@ -11,10 +15,16 @@ const (
 	invalidType
 	objectNotFound
 	objectAlreadyExists
+	invalidArgument
+	conflict
+	internal
+	malformedRequest
 )
 
 var (
 	errorCodesToStringMap map[errorCode]string
+	// defaultMessages describe errors which have no message of their own
+	defaultMessages map[errorCode]string
 
 	// ErrorObjectNotFound when you don't find an entity from dao
 	ErrorObjectNotFound = AggError{Code: objectNotFound}
@@ -25,6 +35,14 @@ var (
 	ErrorArgumentMismatch = AggError{Code: argumentMismatch}
 	// ErrorInvalidType is
 	ErrorInvalidType = AggError{Code: invalidType}
+	// ErrorInvalidArgument is when an argument is present, but its value can't be used
+	ErrorInvalidArgument = AggError{Code: invalidArgument}
+	// ErrorConflict is when an operation is not allowed in the current state of an entity
+	ErrorConflict = AggError{Code: conflict}
+	// ErrorInternal is any other error, like a failing db
+	ErrorInternal = AggError{Code: internal}
+	// ErrorMalformedRequest is when a request cannot be read at all, like a body which is not json
+	ErrorMalformedRequest = AggError{Code: malformedRequest}
 )
 
 func init() {
@@ -33,15 +51,30 @@ func init() {
 		invalidType:         "InvalidType",
 		objectNotFound:      "ObjectNotFound",
 		objectAlreadyExists: "ObjectAlreadyExists",
+		invalidArgument:     "InvalidArgument",
+		conflict:            "Conflict",
+		internal:            "Internal",
+		malformedRequest:    "MalformedRequest",
+	}
+	defaultMessages = map[errorCode]string{
+		argumentMismatch:    "Wrong number of arguments",
+		invalidType:         "Argument has an invalid type",
+		objectNotFound:      "Not found",
+		objectAlreadyExists: "Already exists",
+		invalidArgument:     "Invalid value",
+		conflict:            "Not allowed in the current state",
+		internal:            "Internal error",
+		malformedRequest:    "Malformed request",
 	}
 }
 
 type errorCode int
 
-// AggError is customized error
+// AggError is customized error. Field is the field of a request the error is about, if any.
 type AggError struct {
 	Code    errorCode
 	Message string
+	Field   string
 }
 
 // Error implements error interface
@@ -57,3 +90,45 @@ func (e AggError) Error() string {
 func (e AggError) StringCode() string {
 	return errorCodesToStringMap[e.Code]
 }
+
+// Description is the message of the error, or a generic one for its code
+func (e AggError) Description() string {
+	if utils.IsBlank(e.Message) {
+		return defaultMessages[e.Code]
+	}
+	return e.Message
+}
+
+// WithMessage returns a copy of the error with a message, formatted as in fmt.Sprintf
+func (e AggError) WithMessage(format string, args ...interface{}) AggError {
+	e.Message = fmt.Sprintf(format, args...)
+	return e
+}
+
+// WithField returns a copy of the error about a field of a request
+func (e AggError) WithField(field string) AggError {
+	e.Field = field
+	return e
+}
+
+// Is makes errors.Is match an AggError by its code, whatever its message and field are
+func (e AggError) Is(target error) bool {
+	t, ok := target.(AggError)
+	return ok && t.Code == e.Code
+}
+
+// Is tells if err, or any error it wraps, has the code of target
+func Is(err error, target AggError) bool {
+	return errors.Is(err, target)
+}
+
+// From returns err as an AggError. Errors which are not AggErrors are internal errors. They are
+// only logged, their message could tell clients about the db or the file system.
+func From(err error) AggError {
+	var e AggError
+	if errors.As(err, &e) {
+		return e
+	}
+	log.Printf("Internal error: %s", err.Error())
+	return ErrorInternal
+}
@@ -0,0 +1,28 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAggError(t *testing.T) {
+	err := ErrorObjectNotFound.WithMessage("Task %d not found", 3).WithField("id")
+	if err.Description() != "Task 3 not found" || err.Field != "id" || err.StringCode() != "ObjectNotFound" {
+		t.Errorf("got %+v", err)
+	}
+	if ErrorObjectNotFound.Description() != "Not found" {
+		t.Errorf("got %q, want the default message", ErrorObjectNotFound.Description())
+	}
+
+	wrapped := fmt.Errorf("loading: %w", err)
+	if !Is(wrapped, ErrorObjectNotFound) || Is(wrapped, ErrorConflict) {
+		t.Errorf("Is should match by code only")
+	}
+	if From(wrapped) != err {
+		t.Errorf("got %+v, want %+v", From(wrapped), err)
+	}
+	if e := From(errors.New("disk full")); e.Code != internal || e.Description() != "Internal error" {
+		t.Errorf("got %+v, want an internal error without the message", e)
+	}
+}
@@ -0,0 +1,179 @@
+// Package events is an in process publish and subscribe hub of the changes of tasks. The task
+// service publishes every change, and each subscriber, like an event stream of the api, gets the
+// ones matching its filter. The latest events are kept, so that a subscriber which lost its
+// connection can resume where it left off.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"server/domain"
+)
+
+// Event is a change of a task. ID is given by the hub, and grows with every event. Task is the
+// task after the change, or right before it was deleted.
+type Event struct {
+	ID     int64            `json:"id"`
+	Type   domain.TaskEvent `json:"type"`
+	TaskID int64            `json:"taskId"`
+	Task   domain.Task      `json:"task"`
+	Time   domain.Time      `json:"time"`
+}
+
+// Filter selects events. Zero values mean "no filter". Events match Types if they are of any of
+// them, and Tag if their task has it.
+type Filter struct {
+	Types  []domain.TaskEvent
+	TaskID int64
+	Tag    string
+}
+
+// Matches tells if an event passes the filter
+func (f Filter) Matches(e Event) bool {
+	if len(f.Types) > 0 && !domain.EventList(f.Types).Has(e.Type) {
+		return false
+	}
+	if f.TaskID != 0 && e.TaskID != f.TaskID {
+		return false
+	}
+	if f.Tag != "" {
+		for _, tag := range e.Task.Tags {
+			if tag == f.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Publisher takes events. The hub sends them to its subscribers, a Batch holds them back.
+type Publisher interface {
+	Publish(e Event)
+}
+
+// subscriberBuffer is how many events a subscriber can fall behind before it is dropped
+const subscriberBuffer = 64
+
+// Hub sends published events to its subscribers, and keeps the latest ones. It is safe for
+// concurrent use.
+type Hub struct {
+	mu     sync.Mutex
+	size   int
+	recent []Event
+	first  int64
+	lastID int64
+	subs   map[*Subscription]bool
+}
+
+// NewHub returns a hub keeping the latest size events. Its ids start from the time it is made,
+// in microseconds, so that they are not given again after a restart: a client resuming with an
+// id of the hub before it knows that it missed events.
+func NewHub(size int) *Hub {
+	return newHub(size, time.Now().UnixNano()/int64(time.Microsecond))
+}
+
+// newHub returns a hub whose first event gets the id after first
+func newHub(size int, first int64) *Hub {
+	return &Hub{size: size, recent: make([]Event, 0, size), first: first, lastID: first, subs: make(map[*Subscription]bool)}
+}
+
+// Publish gives the event the next id, and sends it to the subscribers whose filter it matches.
+// A subscriber which is too far behind is dropped rather than waited for: its channel is closed,
+// and it can subscribe again from the last event it got.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastID++
+	e.ID = h.lastID
+	if time.Time(e.Time).IsZero() {
+		e.Time = domain.Time(time.Now())
+	}
+	if len(h.recent) == h.size {
+		h.recent = append(h.recent[:0], h.recent[1:]...)
+	}
+	h.recent = append(h.recent, e)
+
+	for s := range h.subs {
+		if !s.filter.Matches(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			h.drop(s)
+		}
+	}
+}
+
+// Subscription gets the events of a hub matching its filter on C, until it is closed. Backlog
+// are the events it missed since the one it resumed from, oldest first. Missed is set when some
+// of them are not kept anymore, so that the subscriber knows to reload what it shows. LastID is
+// the id of the latest event published when it started.
+type Subscription struct {
+	C       <-chan Event
+	Backlog []Event
+	Missed  bool
+	LastID  int64
+	c       chan Event
+	filter  Filter
+	hub     *Hub
+}
+
+// Subscribe starts a subscription to the events matching filter. A lastID other than 0 resumes
+// from the event with that id: the events after it come in the backlog.
+func (h *Hub) Subscribe(lastID int64, filter Filter) *Subscription {
+	c := make(chan Event, subscriberBuffer)
+	s := &Subscription{C: c, Backlog: make([]Event, 0), c: c, filter: filter, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.LastID = h.lastID
+	if lastID > 0 {
+		// ids are given in order, so the events after lastID are all kept unless the oldest kept
+		// one is past it. An id outside of the ones of the hub comes from before a restart.
+		s.Missed = lastID < h.first || lastID > h.lastID || (len(h.recent) > 0 && h.recent[0].ID > lastID+1)
+		for _, e := range h.recent {
+			if e.ID > lastID && filter.Matches(e) {
+				s.Backlog = append(s.Backlog, e)
+			}
+		}
+	}
+	h.subs[s] = true
+	return s
+}
+
+// Close ends the subscription. It can be called more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
+
+// drop removes a subscription and closes its channel. The lock is held.
+func (h *Hub) drop(s *Subscription) {
+	if h.subs[s] {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+// Batch holds back events, until Flush publishes them. The events of a transaction are batched,
+// so that they are only published once it is committed.
+type Batch struct {
+	events []Event
+}
+
+// Publish holds back the event
+func (b *Batch) Publish(e Event) {
+	b.events = append(b.events, e)
+}
+
+// Flush publishes the events held back to p, in order, and forgets them
+func (b *Batch) Flush(p Publisher) {
+	for _, e := range b.events {
+		p.Publish(e)
+	}
+	b.events = nil
+}
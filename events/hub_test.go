@@ -0,0 +1,103 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"server/domain"
+)
+
+func ids(events []Event) []int64 {
+	ids := make([]int64, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestHub(t *testing.T) {
+	h := newHub(3, 0)
+	live := h.Subscribe(0, Filter{Types: []domain.TaskEvent{domain.EventTaskCreated}})
+	for i := int64(1); i <= 5; i++ {
+		h.Publish(Event{Type: domain.EventTaskCreated, TaskID: i})
+	}
+	h.Publish(Event{Type: domain.EventTaskDeleted, TaskID: 1})
+	if got := len(live.C); got != 5 {
+		t.Errorf("got %d live events, want the 5 created ones", got)
+	}
+	live.Close()
+	live.Close()
+	if _, ok := <-drain(live.C); ok {
+		t.Errorf("a closed subscription should have a closed channel")
+	}
+
+	// events 4 to 6 are kept
+	resumed := h.Subscribe(3, Filter{})
+	if got := ids(resumed.Backlog); resumed.Missed || len(got) != 3 || got[0] != 4 {
+		t.Errorf("got backlog %v (missed %t), want events 4 to 6", got, resumed.Missed)
+	}
+	late := h.Subscribe(1, Filter{TaskID: 1})
+	if got := ids(late.Backlog); !late.Missed || len(got) != 1 || got[0] != 6 {
+		t.Errorf("got backlog %v (missed %t), want event 6 and missed events", got, late.Missed)
+	}
+	restarted := h.Subscribe(100, Filter{})
+	if !restarted.Missed || restarted.LastID != 6 {
+		t.Errorf("got %+v, want missed events from before a restart", restarted)
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	h := NewHub(10)
+	slow := h.Subscribe(0, Filter{})
+	for i := 0; i <= subscriberBuffer; i++ {
+		h.Publish(Event{Type: domain.EventTaskUpdated})
+	}
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("got %d events, want %d before the subscriber is dropped", n, subscriberBuffer)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	before := NewHub(10)
+	before.Publish(Event{Type: domain.EventTaskCreated})
+	before.Publish(Event{Type: domain.EventTaskCreated})
+	seen := before.Subscribe(0, Filter{}).LastID
+	time.Sleep(time.Millisecond)
+
+	// the hub after a restart has not given any event yet
+	after := NewHub(10)
+	if s := after.Subscribe(seen, Filter{}); !s.Missed || s.LastID <= seen {
+		t.Errorf("got %+v, want missed events and ids after %d", s, seen)
+	}
+	after.Publish(Event{Type: domain.EventTaskUpdated})
+	if s := after.Subscribe(seen, Filter{}); !s.Missed || len(s.Backlog) != 1 || s.Backlog[0].ID <= seen {
+		t.Errorf("got %+v, want missed events and ids after %d", s, seen)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	h := newHub(10, 0)
+	var b Batch
+	b.Publish(Event{Type: domain.EventTaskCreated})
+	b.Publish(Event{Type: domain.EventTaskUpdated})
+	if s := h.Subscribe(0, Filter{}); len(s.C) != 0 || s.LastID != 0 {
+		t.Fatalf("batched events should not be published before a flush")
+	}
+	b.Flush(h)
+	b.Flush(h)
+	if s := h.Subscribe(1, Filter{}); len(s.Backlog) != 1 || s.Backlog[0].Type != domain.EventTaskUpdated {
+		t.Errorf("got %v, want the batched events published once, in order", s.Backlog)
+	}
+}
+
+// drain reads what is left on c, so that it can be checked for being closed
+func drain(c <-chan Event) <-chan Event {
+	for len(c) > 0 {
+		<-c
+	}
+	return c
+}
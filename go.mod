@@ -6,6 +6,7 @@ require (
 	github.com/gorilla/mux v1.7.3
 	github.com/gorilla/sessions v1.2.0
 	github.com/jmoiron/sqlx v1.2.0
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v2.0.2+incompatible
 	google.golang.org/appengine v1.6.5 // indirect
 )
@@ -0,0 +1,205 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/domain"
+)
+
+// Todo is a to-do read from a calendar. Due is zero when the to-do has no due date nor start,
+// and Priority is the iCalendar priority, 0 when it has none.
+type Todo struct {
+	UID         string
+	Summary     string
+	Description string
+	Due         time.Time
+	Priority    int
+	Status      string
+	Categories  []string
+}
+
+// ParseTodos reads the to-dos of a calendar, in their order. The other components, like events,
+// are skipped. Times without an offset nor a known timezone are in the timezone of the user.
+func ParseTodos(r io.Reader) ([]Todo, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	todos := make([]Todo, 0)
+	var components []string
+	var todo *Todo
+	var start time.Time
+	for i, line := range lines {
+		name, params, value, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %s", i+1, err.Error())
+		}
+		switch name {
+		case "BEGIN":
+			components = append(components, strings.ToUpper(value))
+			if len(components) == 2 && components[1] == "VTODO" {
+				todo, start = &Todo{}, time.Time{}
+			}
+			continue
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(value) {
+				return nil, fmt.Errorf("Line %d: END:%s does not close a component", i+1, value)
+			}
+			if len(components) == 2 && todo != nil {
+				if todo.Due.IsZero() {
+					todo.Due = start
+				}
+				todos = append(todos, *todo)
+				todo = nil
+			}
+			components = components[:len(components)-1]
+			continue
+		}
+		// only the properties of the to-do itself are read, not the ones of its alarms
+		if todo == nil || len(components) != 2 {
+			continue
+		}
+		switch name {
+		case "UID":
+			todo.UID = value
+		case "SUMMARY":
+			todo.Summary = unescape(value)
+		case "DESCRIPTION":
+			todo.Description = unescape(value)
+		case "DUE", "DTSTART":
+			t, err := parseTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("Line %d: %s", i+1, err.Error())
+			}
+			if name == "DUE" {
+				todo.Due = t
+			} else {
+				start = t
+			}
+		case "PRIORITY":
+			if todo.Priority, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("Line %d: invalid priority %q", i+1, value)
+			}
+		case "STATUS":
+			todo.Status = strings.ToUpper(value)
+		case "CATEGORIES":
+			for _, c := range splitList(value) {
+				if c = strings.TrimSpace(unescape(c)); c != "" {
+					todo.Categories = append(todo.Categories, c)
+				}
+			}
+		}
+	}
+	if len(components) > 0 {
+		return nil, fmt.Errorf("%s is not closed", components[len(components)-1])
+	}
+	return todos, nil
+}
+
+// unfold reads the content lines of a calendar, joining the folded ones. Empty lines are dropped.
+func unfold(r io.Reader) ([]string, error) {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line, like DUE;TZID=Europe/Paris:20300102T150000, into its upper
+// cased name, its parameters and its value. Parameter values can be quoted.
+func parseLine(line string) (name string, params map[string]string, value string, err error) {
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", fmt.Errorf("no value in %q", line)
+	}
+	parts := strings.Split(line[:colon], ";")
+	params = make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:], nil
+}
+
+// parseTime reads a date, a time in UTC, or a local time in the timezone of its TZID parameter.
+// Dates and local times without a known timezone are in the timezone of the user.
+func parseTime(value string, params map[string]string) (time.Time, error) {
+	loc := domain.Location()
+	if tzid, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	var t time.Time
+	var err error
+	switch {
+	case params["VALUE"] == "DATE" || len(value) == len(dateLayout):
+		t, err = time.ParseInLocation(dateLayout, value, loc)
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse(utcLayout, value)
+	default:
+		t, err = time.ParseInLocation(strings.TrimSuffix(utcLayout, "Z"), value, loc)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+	return t.UTC(), nil
+}
+
+// splitList splits a list value on the commas which are not escaped
+func splitList(value string) []string {
+	items := make([]string, 0)
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
+
+// unescape reads a text value
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' || s[i] == 'N' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+// Package ical writes tasks as the to-dos of an iCalendar (RFC 5545) feed, which calendar apps can
+// subscribe to, and reads the to-dos of an iCalendar file back.
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"server/domain"
+)
+
+const (
+	// prodID names the program which made a calendar
+	prodID = "-//task server//tasks//EN"
+
+	// utcLayout is the form of times in UTC, and dateLayout the one of dates
+	utcLayout  = "20060102T150405Z"
+	dateLayout = "20060102"
+
+	// maxLineLength is the most octets of a line, longer ones are folded
+	maxLineLength = 75
+)
+
+// Status values of to-dos
+const (
+	StatusNeedsAction = "NEEDS-ACTION"
+	StatusInProcess   = "IN-PROCESS"
+	StatusCompleted   = "COMPLETED"
+	StatusCancelled   = "CANCELLED"
+)
+
+// priorities maps the priorities of tasks, 5 the highest, to the ones of iCalendar, where 1 is the
+// highest and 9 the lowest. 0 is no priority in both.
+var priorities = map[domain.Priority]int{0: 0, 5: 1, 4: 3, 3: 5, 2: 7, 1: 9}
+
+// Priority is the iCalendar priority of a task
+func Priority(p domain.Priority) int {
+	return priorities[p]
+}
+
+// TaskPriority is the task priority of an iCalendar priority, the closest one for the iCalendar
+// priorities the tasks do not have
+func TaskPriority(p int) domain.Priority {
+	switch {
+	case p <= 0 || p > 9:
+		return 0
+	case p <= 2:
+		return 5
+	case p <= 4:
+		return 4
+	case p == 5:
+		return 3
+	case p <= 7:
+		return 2
+	}
+	return 1
+}
+
+// Status is the iCalendar status of a task
+func Status(s domain.Status) string {
+	switch s {
+	case domain.InProgress:
+		return StatusInProcess
+	case domain.Done:
+		return StatusCompleted
+	}
+	return StatusNeedsAction
+}
+
+// WriteCalendar writes tasks as the to-dos of a calendar called name. The uids of the to-dos are
+// made from the ids of the tasks and host, so that they stay the same from one read to the next.
+func WriteCalendar(w io.Writer, name, host string, tasks []domain.Task, now time.Time) error {
+	cw := calendarWriter{w: w}
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", prodID)
+	if name != "" {
+		cw.line("X-WR-CALNAME", escape(name))
+	}
+	for _, t := range tasks {
+		cw.line("BEGIN", "VTODO")
+		cw.line("UID", fmt.Sprintf("task-%d@%s", t.Rowid, host))
+		cw.line("DTSTAMP", now.UTC().Format(utcLayout))
+		if !time.Time(t.Created).IsZero() {
+			cw.line("CREATED", time.Time(t.Created).UTC().Format(utcLayout))
+		}
+		cw.line("SUMMARY", escape(t.Title))
+		if t.Description != "" {
+			cw.line("DESCRIPTION", escape(t.Description))
+		}
+		if !time.Time(t.DueDate).IsZero() {
+			cw.line("DUE", time.Time(t.DueDate).UTC().Format(utcLayout))
+		}
+		cw.line("PRIORITY", fmt.Sprint(Priority(t.Priority)))
+		cw.line("STATUS", Status(t.Status))
+		if len(t.Tags) > 0 {
+			tags := make([]string, 0, len(t.Tags))
+			for _, tag := range t.Tags {
+				tags = append(tags, escape(tag))
+			}
+			cw.line("CATEGORIES", strings.Join(tags, ","))
+		}
+		cw.line("END", "VTODO")
+	}
+	cw.line("END", "VCALENDAR")
+	return cw.err
+}
+
+// calendarWriter writes content lines, and keeps the first error
+type calendarWriter struct {
+	w   io.Writer
+	err error
+}
+
+// line writes a content line, folded so that no line is longer than maxLineLength octets. Lines
+// are only folded between characters.
+func (cw *calendarWriter) line(name, value string) {
+	if cw.err != nil {
+		return
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range name + ":" + value {
+		size := len(string(r))
+		if n+size > maxLineLength {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+	_, cw.err = io.WriteString(cw.w, b.String())
+}
+
+// textEscaper escapes the characters which have a meaning in text values
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return textEscaper.Replace(s)
+}
@@ -0,0 +1,99 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"server/domain"
+)
+
+func TestRoundTrip(t *testing.T) {
+	due := time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)
+	tasks := []domain.Task{
+		{Rowid: 1, Title: "write, review; ship", Description: "first line\nsecond " + strings.Repeat("é", 60), DueDate: domain.Time(due), Priority: 5, Status: domain.InProgress, Tags: []string{"work", "a,b"}},
+		{Rowid: 2, Title: "call", DueDate: domain.Time(due.Add(time.Hour)), Priority: 1, Status: domain.Pending},
+	}
+	var b bytes.Buffer
+	if err := WriteCalendar(&b, "tasks", "task.example.com", tasks, due); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(b.String(), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("line %q is longer than %d octets", line, maxLineLength)
+		}
+	}
+	if !strings.Contains(b.String(), "UID:task-1@task.example.com\r\n") || !strings.Contains(b.String(), "DUE:20300102T150000Z\r\n") {
+		t.Errorf("got %s, want uids and due dates in UTC", b.String())
+	}
+
+	todos, err := ParseTodos(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 2 {
+		t.Fatalf("got %d to-dos, want 2", len(todos))
+	}
+	first := todos[0]
+	if first.Summary != tasks[0].Title || first.Description != tasks[0].Description || !first.Due.Equal(due) {
+		t.Errorf("got %+v, want the fields of the first task", first)
+	}
+	if first.Priority != 1 || first.Status != StatusInProcess || strings.Join(first.Categories, "|") != "work|a,b" {
+		t.Errorf("got %+v, want priority 1, in process and both tags", first)
+	}
+	if TaskPriority(todos[1].Priority) != 1 || todos[1].Status != StatusNeedsAction {
+		t.Errorf("got %+v, want the lowest priority", todos[1])
+	}
+}
+
+func TestParseTodos(t *testing.T) {
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"SUMMARY:not a to-do",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"SUMMARY:dated",
+		"DUE;VALUE=DATE:20300102",
+		"BEGIN:VALARM",
+		"DESCRIPTION:not the description",
+		"END:VALARM",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"SUMMARY:in paris",
+		"DTSTART;TZID=\"Europe/Paris\":20300102T150000",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"SUMMARY:floating",
+		"DUE:20300102T150000",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\n")
+	todos, err := ParseTodos(strings.NewReader(calendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{
+		time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2030, 1, 2, 14, 0, 0, 0, time.UTC),
+		time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC),
+	}
+	if len(todos) != len(want) {
+		t.Fatalf("got %+v, want %d to-dos", todos, len(want))
+	}
+	for i, w := range want {
+		if !todos[i].Due.Equal(w) {
+			t.Errorf("got %s for %q, want %s", todos[i].Due, todos[i].Summary, w)
+		}
+	}
+	if todos[0].Description != "" {
+		t.Errorf("got description %q, want the one of the alarm skipped", todos[0].Description)
+	}
+
+	for _, broken := range []string{"BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VCALENDAR", "BEGIN:VCALENDAR\nBEGIN:VTODO\nDUE:tomorrow\nEND:VTODO\nEND:VCALENDAR", "BEGIN:VCALENDAR\nno value"} {
+		if _, err := ParseTodos(strings.NewReader(broken)); err == nil {
+			t.Errorf("got no error for %q", broken)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -11,7 +13,10 @@ import (
 	"server/api"
 	"server/config"
 	"server/db"
+	"server/domain"
+	"server/events"
 	"server/middleware"
+	"server/notify"
 
 	"server/controller"
 	taskRepository "server/repository/task"
@@ -24,9 +29,26 @@ const (
 
 	// WIKI is a placeholder, to be used for "wiki.orakem.ste"
 	WIKI = "wiki"
+
+	// trashPurgeInterval is how often deleted tasks past their retention are purged
+	trashPurgeInterval = time.Hour
+
+	// webhookDeliveryInterval is how often the deliveries of task events which are due are sent
+	webhookDeliveryInterval = 5 * time.Second
+
+	// snapshotInterval is how often the tasks kept in memory are saved, if they changed
+	snapshotInterval = time.Minute
+
+	// eventBufferSize is how many of the latest task events are kept for event streams to resume
+	eventBufferSize = 1000
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+
 	log.Println("Starting the server")
 
 	r := mux.NewRouter()
@@ -46,20 +68,27 @@ func main() {
 		},
 	}
 
+	done := make(chan struct{})
+	go shutdownOnSignal(srv, done)
+
+	var err error
 	if config.HTTPSMode() {
 		srv.Addr = ":443"
 		certFile := config.CertFile()
 		keyFile := config.KeyFile()
-		log.Fatal(srv.ListenAndServeTLS(certFile, keyFile))
+		err = srv.ListenAndServeTLS(certFile, keyFile)
 	} else {
 		if config.HTTPPort() != "" {
 			srv.Addr = config.HTTPPort()
 		} else {
 			srv.Addr = ":80"
 		}
-		log.Fatal(srv.ListenAndServe())
+		err = srv.ListenAndServe()
 	}
-
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
 }
 
 func mapStaticFiles(r *mux.Router) {
@@ -132,7 +161,8 @@ func createSubRouters(r *mux.Router) {
 
 }
 
-func mapTaskServer(r *mux.Router, taskConfig config.TaskConfig) {
+// openTaskDB opens the database of the task server
+func openTaskDB(taskConfig config.TaskConfig) db.Handler {
 	var dbHandler db.Handler
 
 	dbFile := taskConfig.DbURL
@@ -141,22 +171,150 @@ func mapTaskServer(r *mux.Router, taskConfig config.TaskConfig) {
 
 	switch taskConfig.DbType {
 	case "SQLITE":
-		dbHandler = db.NewSqliteHandler(dbFile)
+		dbHandler = db.NewSqliteHandler(dbFile, taskConfig.QueryTimeout())
+		log.Printf("%v", dbHandler.Type())
+	case "POSTGRES":
+		handler, err := db.NewPostgresHandler(dbFile, taskConfig.DbUser, taskConfig.DbPassword, taskConfig.QueryTimeout())
+		if err != nil {
+			log.Fatalf("Could not open postgres db: %s", err.Error())
+		}
+		dbHandler = handler
 		log.Printf("%v", dbHandler.Type())
 	default:
 		log.Fatalf("No handler registered for %s", taskConfig.DbType)
 	}
+	return dbHandler
+}
 
+// initTaskRepo sets up the task repository. A db is migrated first. Tasks kept in memory are
+// saved every snapshotInterval, and once the background jobs have stopped when the server shuts
+// down, if a snapshot file is configured.
+func initTaskRepo(taskConfig config.TaskConfig) {
+	if db.Type(taskConfig.DbType) == db.MEMORY {
+		snapshotFile := taskConfig.DbURL
+		if err := taskRepository.InitializeMemoryTaskRepo(snapshotFile); err != nil {
+			log.Fatalf("Could not load tasks from %s: %s", snapshotFile, err.Error())
+		}
+		if snapshotFile != "" {
+			runInBackground(func(ctx context.Context) {
+				taskRepository.SaveMemorySnapshotEvery(ctx, snapshotFile, snapshotInterval)
+			})
+			onShutdown(func() {
+				if err := taskRepository.SaveMemorySnapshot(snapshotFile); err != nil {
+					log.Printf("Could not save tasks to %s: %s", snapshotFile, err.Error())
+				}
+			})
+		}
+		return
+	}
+
+	dbHandler := openTaskDB(taskConfig)
+	if err := migrateUp(dbHandler); err != nil {
+		log.Fatalf("Could not migrate task db: %s", err.Error())
+	}
 	taskRepository.InitTaskRepo(dbHandler)
-	err := service.InitializeTaskService(taskRepository.Repository())
+}
+
+// startReminders sends the reminders of tasks in the background, if any notifier is configured
+func startReminders(reminderConfig config.ReminderConfig) {
+	notifiers := make([]notify.Notifier, 0)
+	if smtp := reminderConfig.SMTP; smtp.Addr != "" {
+		notifiers = append(notifiers, notify.SMTP{Addr: smtp.Addr, Username: smtp.Username, Password: smtp.Password, From: smtp.From, To: smtp.To})
+	}
+	for _, url := range reminderConfig.Webhooks {
+		notifiers = append(notifiers, notify.NewWebhook(url))
+	}
+	if reminderConfig.LogFile != "" {
+		logFile, err := notify.OpenLogFile(reminderConfig.LogFile)
+		if err != nil {
+			log.Fatalf("Could not open reminder log: %s", err.Error())
+		}
+		notifiers = append(notifiers, logFile)
+	}
+	if len(notifiers) == 0 {
+		return
+	}
+
+	leadTimes := make([]domain.Duration, 0, len(reminderConfig.LeadTimes))
+	for _, l := range reminderConfig.LeadTimes {
+		d, err := time.ParseDuration(l)
+		if err != nil || d < 0 || d > domain.MaxLeadTime {
+			log.Fatalf("Invalid reminder lead time %q, use a duration like 1h of at most %s", l, domain.MaxLeadTime)
+		}
+		leadTimes = append(leadTimes, domain.Duration(d))
+	}
+	runInBackground(func(ctx context.Context) {
+		service.RemindEvery(ctx, service.TaskService, reminderConfig.Interval(), notifiers, leadTimes)
+	})
+}
+
+func mapTaskServer(r *mux.Router, taskConfig config.TaskConfig) {
+	if err := domain.SetTimezone(taskConfig.Timezone); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	workflow := domain.DefaultWorkflow
+	if len(taskConfig.Transitions) > 0 {
+		var err error
+		if workflow, err = domain.NewWorkflow(taskConfig.Transitions, taskConfig.Reopens); err != nil {
+			log.Fatalf("Invalid task workflow: %s", err.Error())
+		}
+	}
+
+	initTaskRepo(taskConfig)
+	hub := events.NewHub(eventBufferSize)
+	err := service.InitializeTaskService(taskRepository.Repository(), workflow, hub)
 	if err != nil {
 		log.Fatalf("Could not start task service: %s", err.Error())
 	}
 
-	taskController := controller.TaskController{TaskService: service.TaskService}
+	runInBackground(func(ctx context.Context) {
+		service.PurgeTrashEvery(ctx, service.TaskService, trashPurgeInterval, taskConfig.TrashRetention())
+	})
+	startReminders(taskConfig.Reminders)
+	runInBackground(func(ctx context.Context) {
+		service.DeliverWebhooksEvery(ctx, service.TaskService, webhookDeliveryInterval)
+	})
+
+	taskController := controller.TaskController{TaskService: service.TaskService, Events: hub}
+	r.Use(middleware.WithUsername)
 	r.HandleFunc("/", HelloTask).Methods("GET")
 
 	r.HandleFunc("/api/tasks", taskController.GetAllTasks).Methods("GET")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}", taskController.GetTaskByID).Methods("GET")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}", taskController.UpdateTaskByID).Methods("PUT")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}", taskController.PatchTaskByID).Methods("PATCH")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}", taskController.DeleteTaskByID).Methods("DELETE")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/children", taskController.GetChildTasks).Methods("GET")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/subtree", taskController.GetSubtree).Methods("GET")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/dependencies", taskController.GetBlockers).Methods("GET")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/dependencies", taskController.AddDependency).Methods("POST")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/dependencies/{blockedBy:[0-9]+}", taskController.RemoveDependency).Methods("DELETE")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/tags", taskController.AddTags).Methods("POST")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/tags/{tag}", taskController.RemoveTag).Methods("DELETE")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/completions", taskController.GetCompletions).Methods("GET")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/reopen", taskController.ReopenTask).Methods("POST")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/history", taskController.GetHistory).Methods("GET")
+	r.HandleFunc("/api/tasks/{id:[0-9]+}/restore", taskController.RestoreTask).Methods("POST")
+	r.HandleFunc("/api/tasks/bulk", taskController.BulkTasks).Methods("POST")
+	r.HandleFunc("/api/tasks/ordered", taskController.GetTasksInDependencyOrder).Methods("GET")
+	r.HandleFunc("/api/tags", taskController.GetTagCounts).Methods("GET")
+	r.HandleFunc("/api/audit", taskController.GetAuditLog).Methods("GET")
+	r.HandleFunc("/api/trash", taskController.GetTrash).Methods("GET")
+	r.HandleFunc("/api/search", taskController.SearchTasks).Methods("GET")
+	r.HandleFunc("/api/webhooks", taskController.GetWebhooks).Methods("GET")
+	r.HandleFunc("/api/webhooks", taskController.CreateWebhook).Methods("POST")
+	r.HandleFunc("/api/webhooks/{id:[0-9]+}", taskController.DeleteWebhook).Methods("DELETE")
+	r.HandleFunc("/api/webhooks/{id:[0-9]+}/deliveries", taskController.GetWebhookDeliveries).Methods("GET")
+	r.HandleFunc("/api/webhooks/deliveries/{id:[0-9]+}/replay", taskController.ReplayDelivery).Methods("POST")
+	r.HandleFunc("/api/events", taskController.StreamEvents).Methods("GET")
+	r.HandleFunc("/api/calendar.ics", taskController.GetCalendar).Methods("GET")
+	r.HandleFunc("/api/calendar/feeds", taskController.GetCalendarFeeds).Methods("GET")
+	r.HandleFunc("/api/calendar/feeds", taskController.CreateCalendarFeed).Methods("POST")
+	r.HandleFunc("/api/calendar/feeds/{id:[0-9]+}", taskController.DeleteCalendarFeed).Methods("DELETE")
+	r.HandleFunc("/api/calendar/import", taskController.ImportCalendar).Methods("POST")
+
+	// title based routes, kept for compatibility
 	r.HandleFunc("/api/task/{name}", taskController.GetTask).Methods("GET")
 	r.HandleFunc("/api/task", taskController.CreateTask).Methods("POST")
 	r.HandleFunc("/api/task", taskController.UpdateTask).Methods("PUT")
@@ -40,6 +40,14 @@ func RequiresLogin(handler http.Handler) http.Handler {
 	})
 }
 
+// WithUsername puts the username of the session in the request context, see api.Username
+func WithUsername(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := api.WithUsername(r.Context(), api.SessionUsername(r))
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // Logger provides a wrapper to log all requests
 func Logger(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"server/config"
+	"server/db"
+	taskRepository "server/repository/task"
+)
+
+const migrateUsage = "usage: server migrate up|down|status"
+
+// migrate is the migrate subcommand. up applies the pending migrations of the task db, down
+// rolls back the last applied one, and status lists all of them.
+func migrate(args []string) {
+	if len(args) != 1 {
+		log.Fatal(migrateUsage)
+	}
+	taskConfig := config.TaskConfiguration()
+	if taskConfig.IsEmpty() {
+		log.Fatal("No task db is configured")
+	}
+	if db.Type(taskConfig.DbType) == db.MEMORY {
+		log.Fatal("Tasks kept in memory have no migrations")
+	}
+	dbHandler := openTaskDB(taskConfig)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		if err := migrateUp(dbHandler); err != nil {
+			log.Fatal(err)
+		}
+	case "down":
+		migrator := taskMigrator(dbHandler)
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Rolled back migration %d %s", migration.Version, migration.Name)
+	case "status":
+		statuses, err := taskMigrator(dbHandler).Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			switch {
+			case s.Name == "":
+				fmt.Printf("%4d  %-20s  applied %s, unknown to this build\n", s.Version, "?", s.AppliedAt)
+			case s.Applied:
+				fmt.Printf("%4d  %-20s  applied %s\n", s.Version, s.Name, s.AppliedAt)
+			default:
+				fmt.Printf("%4d  %-20s  pending\n", s.Version, s.Name)
+			}
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
+
+// migrateUp applies the pending migrations of the task db
+func migrateUp(dbHandler db.Handler) error {
+	applied, err := taskMigrator(dbHandler).Up(context.Background())
+	for _, migration := range applied {
+		log.Printf("Applied migration %d %s", migration.Version, migration.Name)
+	}
+	return err
+}
+
+func taskMigrator(dbHandler db.Handler) *db.Migrator {
+	migrator, err := db.NewMigrator(dbHandler, taskRepository.Migrations(dbHandler))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return migrator
+}
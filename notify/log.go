@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"server/domain"
+)
+
+// Log writes reminders as lines of json to a writer, like a log file
+type Log struct {
+	name string
+	mu   *sync.Mutex
+	w    io.Writer
+}
+
+// NewLog returns a Log writing to w, named name
+func NewLog(name string, w io.Writer) Log {
+	return Log{name: name, mu: &sync.Mutex{}, w: w}
+}
+
+// OpenLogFile returns a Log appending to file, which is created if needed. A file of - is the
+// standard error.
+func OpenLogFile(file string) (Log, error) {
+	if file == "-" {
+		return NewLog("log -", os.Stderr), nil
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return Log{}, err
+	}
+	return NewLog("log "+file, f), nil
+}
+
+// Name is the name given to NewLog
+func (l Log) Name() string {
+	return l.name
+}
+
+// Notify writes the reminder on a line of its own
+func (l Log) Notify(ctx context.Context, reminder domain.Reminder) error {
+	b, err := json.Marshal(newPayload(reminder))
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(b, '\n'))
+	return err
+}
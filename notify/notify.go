@@ -0,0 +1,44 @@
+// Package notify sends the reminders of tasks which are coming due, by mail, to webhooks or to a
+// log file, and the signed deliveries of events of tasks to webhooks. The service decides when
+// to send them, this package only delivers them.
+package notify
+
+import (
+	"context"
+	"time"
+
+	"server/domain"
+)
+
+// Notifier delivers reminders. Name tells notifiers apart in the record of sent reminders, so it
+// has to stay the same from one run to the next: a reminder which a notifier could not deliver is
+// tried again by the same notifier only.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, reminder domain.Reminder) error
+}
+
+// defaultTimeout bounds a delivery whose context has no deadline
+const defaultTimeout = 10 * time.Second
+
+// payload is the json form of a reminder, for webhooks and log files
+type payload struct {
+	Message  string          `json:"message"`
+	TaskID   int64           `json:"taskId"`
+	Title    string          `json:"title"`
+	DueDate  domain.Time     `json:"dueDate"`
+	LeadTime domain.Duration `json:"leadTime"`
+	Task     domain.Task     `json:"task"`
+}
+
+// newPayload describes a reminder
+func newPayload(r domain.Reminder) payload {
+	return payload{
+		Message:  r.Message(),
+		TaskID:   r.Task.Rowid,
+		Title:    r.Task.Title,
+		DueDate:  r.Task.DueDate,
+		LeadTime: r.LeadTime,
+		Task:     r.Task,
+	}
+}
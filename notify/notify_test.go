@@ -0,0 +1,186 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"server/domain"
+)
+
+func reminder() domain.Reminder {
+	due := domain.Time(time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC))
+	return domain.Reminder{Task: domain.Task{Rowid: 7, Title: "Pay rent", Description: "Landlord", DueDate: due}, LeadTime: domain.Duration(time.Hour)}
+}
+
+// fakeSMTP accepts one mail on a local port, and sends what it received on the returned channel
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var session strings.Builder
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			session.WriteString(line)
+			switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					session.WriteString(line)
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				received <- session.String()
+				return
+			default:
+				reply("502 unknown")
+			}
+		}
+	}()
+	return l.Addr().String(), received
+}
+
+func TestSMTP(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	s := SMTP{Addr: addr, From: "tasks@example.com", To: []string{"me@example.com", "you@example.com"}}
+	if err := s.Notify(context.Background(), reminder()); err != nil {
+		t.Fatal(err)
+	}
+	session := <-received
+	for _, want := range []string{"MAIL FROM:<tasks@example.com>", "RCPT TO:<me@example.com>", "RCPT TO:<you@example.com>", "Subject: Reminder: Pay rent", `Task "Pay rent" is due in 1h0m0s`, "Landlord"} {
+		if !strings.Contains(session, want) {
+			t.Errorf("the mail has no %q:\n%s", want, session)
+		}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if err := (SMTP{Addr: l.Addr().String(), From: "a@b.c"}).Notify(context.Background(), reminder()); err == nil {
+		t.Errorf("got no error for a server which is down")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got payload
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(srv.URL)
+	if err := w.Notify(context.Background(), reminder()); err != nil {
+		t.Fatal(err)
+	}
+	if got.TaskID != 7 || got.Title != "Pay rent" || got.LeadTime != domain.Duration(time.Hour) || got.Message == "" {
+		t.Errorf("got %+v", got)
+	}
+	status = http.StatusInternalServerError
+	if err := w.Notify(context.Background(), reminder()); err == nil {
+		t.Errorf("got no error for a 500")
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	body := `{"event":"task.created"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != body || r.Header.Get(EventHeader) != "task.created" || r.Header.Get(DeliveryHeader) != "12" {
+			t.Errorf("got %s with headers %v", b, r.Header)
+		}
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(b)
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get(SignatureHeader) != want {
+			t.Errorf("got signature %q, want %q", r.Header.Get(SignatureHeader), want)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	delivery := domain.WebhookDelivery{Rowid: 12, Event: domain.EventTaskCreated, Payload: body}
+	if status, err := NewWebhook(srv.URL).Deliver(context.Background(), "secret", delivery); err != nil || status != http.StatusAccepted {
+		t.Errorf("got %d, %v", status, err)
+	}
+	srv.Close()
+	if status, err := NewWebhook(srv.URL).Deliver(context.Background(), "secret", delivery); err == nil || status != 0 {
+		t.Errorf("got %d, %v for a server which is down", status, err)
+	}
+}
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reminders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "reminders.log")
+	l, err := OpenLogFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Name() != "log "+file {
+		t.Errorf("got name %q", l.Name())
+	}
+	for i := 0; i < 2; i++ {
+		if err := l.Notify(context.Background(), reminder()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(b), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), b)
+	}
+	var p payload
+	if err := json.Unmarshal(lines[1], &p); err != nil || p.TaskID != 7 {
+		t.Errorf("got %+v, %v", p, err)
+	}
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"server/domain"
+)
+
+// Headers of the deliveries of task events. SignatureHeader has the HMAC-SHA256 of the body,
+// keyed with the secret of the webhook, so that the receiver can check where it came from.
+const (
+	SignatureHeader = "X-Task-Signature"
+	EventHeader     = "X-Task-Event"
+	DeliveryHeader  = "X-Task-Delivery"
+)
+
+// Webhook posts json to a URL: reminders as a Notifier, and events of tasks with Deliver. Any
+// answer other than a 2xx status is a failure.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook returns a Webhook posting to url, with a client which gives up after ten seconds
+func NewWebhook(url string) Webhook {
+	return Webhook{URL: url, Client: &http.Client{Timeout: defaultTimeout}}
+}
+
+// Name is webhook followed by the URL
+func (w Webhook) Name() string {
+	return "webhook " + w.URL
+}
+
+// Notify posts the reminder
+func (w Webhook) Notify(ctx context.Context, reminder domain.Reminder) error {
+	b, err := json.Marshal(newPayload(reminder))
+	if err != nil {
+		return err
+	}
+	_, err = w.post(ctx, b, nil)
+	return err
+}
+
+// Deliver posts a delivery of an event, signed with secret. status is the http status of the
+// answer, or 0 if there was none.
+func (w Webhook) Deliver(ctx context.Context, secret string, delivery domain.WebhookDelivery) (status int, err error) {
+	body := []byte(delivery.Payload)
+	return w.post(ctx, body, map[string]string{
+		SignatureHeader: Sign(secret, body),
+		EventHeader:     string(delivery.Event),
+		DeliveryHeader:  strconv.FormatInt(delivery.Rowid, 10),
+	})
+}
+
+// Sign returns the HMAC-SHA256 of body keyed with secret, as sha256= followed by it in hex
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// post sends body with headers, and returns the status of the answer
+func (w Webhook) post(ctx context.Context, body []byte, headers map[string]string) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("Webhook %s answered %s", w.URL, resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,445 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"server/domain"
+	"server/errors"
+)
+
+// testConformance checks the behaviour every ITaskRepo has to share. newRepo returns an empty
+// repository. The mock repository is left out, it only answers what a test tells it to.
+func testConformance(t *testing.T, newRepo func(t *testing.T) ITaskRepo) {
+	ctx := context.Background()
+	due := domain.Time(time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC))
+
+	t.Run("crud", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.AddTask(ctx, domain.Task{Title: "first", Description: "one", Status: domain.Pending, Priority: 2, Effort: domain.Duration(2 * time.Hour), DueDate: due, Tags: []string{"b", "a"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := repo.AddTask(ctx, domain.Task{Title: "second", Status: domain.Pending, Effort: domain.Duration(time.Hour), DueDate: due})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == 0 || second <= first {
+			t.Fatalf("got ids %d and %d, want increasing ids", first, second)
+		}
+
+		task, err := repo.GetTaskByID(ctx, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.Rowid != first || task.Title != "first" || task.Description != "one" || task.Priority != 2 || task.Effort != domain.Duration(2*time.Hour) || task.DueDate.String() != due.String() || !equal(task.Tags, []string{"a", "b"}) {
+			t.Errorf("got %+v, want the task as added", task)
+		}
+		if task, err := repo.GetTaskByTitle(ctx, "second"); err != nil || task.Rowid != second {
+			t.Errorf("got %+v, %v for the second task by title", task, err)
+		}
+		all, err := repo.GetAllTasks(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equal(titles(all), []string{"first", "second"}) {
+			t.Errorf("got %v, want both tasks by id", titles(all))
+		}
+
+		task.Title = "renamed"
+		task.Status = domain.Done
+		if err := repo.UpdateTask(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+		if task, err := repo.GetTaskByID(ctx, first); err != nil || task.Title != "renamed" || task.Status != domain.Done || !equal(task.Tags, []string{"a", "b"}) {
+			t.Errorf("got %+v, %v after the update", task, err)
+		}
+		if task, err := repo.GetTaskByID(ctx, second); err != nil || task.Title != "second" || task.Status != domain.Pending {
+			t.Errorf("updating one task changed another: %+v, %v", task, err)
+		}
+		if _, err := repo.GetTaskByTitle(ctx, "first"); err == nil {
+			t.Errorf("the old title should be gone")
+		}
+
+		if err := repo.DeleteTask(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetTaskByID(ctx, first); err == nil {
+			t.Errorf("a deleted task should not be found")
+		}
+		if err := repo.DeleteTaskByTitle(ctx, "second"); err != nil {
+			t.Fatal(err)
+		}
+		if all, err := repo.GetAllTasks(ctx); err != nil || len(all) != 0 {
+			t.Errorf("got %v, %v, want no tasks", titles(all), err)
+		}
+	})
+
+	t.Run("uniqueness", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.AddTask(ctx, domain.Task{Title: "first", DueDate: due})
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := repo.AddTask(ctx, domain.Task{Title: "second", DueDate: due})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.AddTask(ctx, domain.Task{Title: "first", DueDate: due}); err != errors.ErrorObjectAlreadyExists {
+			t.Errorf("got %v for a title used twice", err)
+		}
+		if _, err := repo.AddTask(ctx, domain.Task{Rowid: first, Title: "third", DueDate: due}); err != errors.ErrorObjectAlreadyExists {
+			t.Errorf("got %v for an id used twice", err)
+		}
+		if err := repo.UpdateTask(ctx, domain.Task{Rowid: second, Title: "first", DueDate: due}); err != errors.ErrorObjectAlreadyExists {
+			t.Errorf("got %v for renaming a task to a taken title", err)
+		}
+		dependency := domain.Dependency{TaskID: second, BlockedBy: first}
+		if err := repo.AddDependency(ctx, dependency); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.AddDependency(ctx, dependency); err != errors.ErrorObjectAlreadyExists {
+			t.Errorf("got %v for a dependency added twice", err)
+		}
+		if err := repo.TrashTask(ctx, first, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		again, err := repo.AddTask(ctx, domain.Task{Title: "first", DueDate: due})
+		if err != nil {
+			t.Errorf("got %v for the title of a task in the trash", err)
+		}
+		if err := repo.RestoreFromTrash(ctx, first); err != errors.ErrorObjectAlreadyExists {
+			t.Errorf("got %v for restoring a task whose title was taken", err)
+		}
+		if err := repo.UpdateTask(ctx, domain.Task{Rowid: again, Title: "again", DueDate: due}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.RestoreFromTrash(ctx, first); err != nil {
+			t.Errorf("got %v for restoring a task whose title is free again", err)
+		}
+		if err := repo.DeleteTask(ctx, second); err != nil {
+			t.Fatal(err)
+		}
+		if id, err := repo.AddTask(ctx, domain.Task{Title: "third", DueDate: due}); err != nil || id <= second {
+			t.Errorf("got %d, %v, want an id above %d", id, err, second)
+		}
+	})
+
+	t.Run("reminders", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.AddTask(ctx, domain.Task{Title: "remind", DueDate: due, Reminders: []domain.Duration{domain.Duration(time.Hour), domain.Duration(time.Minute), domain.Duration(time.Hour)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		task, err := repo.GetTaskByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(task.Reminders) != "[1m0s 1h0m0s]" {
+			t.Errorf("got reminders %v, want 1m and 1h", task.Reminders)
+		}
+		task.Reminders = []domain.Duration{domain.Duration(24 * time.Hour)}
+		if err := repo.UpdateTask(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+		if task, err := repo.GetTaskByTitle(ctx, "remind"); err != nil || fmt.Sprint(task.Reminders) != "[24h0m0s]" {
+			t.Errorf("got %v, %v after the update, want 24h", task.Reminders, err)
+		}
+
+		sent := domain.SentReminder{TaskID: id, DueDate: due, LeadTime: domain.Duration(time.Hour), Notifier: "log", Sent: domain.Time(time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC))}
+		if err := repo.AddSentReminder(ctx, sent); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.AddSentReminder(ctx, sent); err != errors.ErrorObjectAlreadyExists {
+			t.Errorf("got %v for a reminder sent twice", err)
+		}
+		other := sent
+		other.Notifier = "smtp"
+		if err := repo.AddSentReminder(ctx, other); err != nil {
+			t.Errorf("got %v for a reminder sent through another notifier", err)
+		}
+		got, err := repo.GetSentReminders(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0].Notifier != "log" || got[0].LeadTime != sent.LeadTime || !time.Time(got[0].DueDate).Equal(time.Time(due)) {
+			t.Errorf("got %+v, want the two sent reminders", got)
+		}
+		if err := repo.DeleteTask(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := repo.GetSentReminders(ctx, id); err != nil || len(got) != 0 {
+			t.Errorf("got %+v, %v, want no sent reminders for a deleted task", got, err)
+		}
+	})
+
+	t.Run("audit log", func(t *testing.T) {
+		repo := newRepo(t)
+		changed := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+		for i, username := range []string{"ann", "bob", "ann", "ann"} {
+			entry := domain.AuditEntry{TaskID: 1, Action: domain.AuditUpdate, Changes: domain.FieldChanges{}, Changed: domain.Time(changed.Add(time.Duration(i) * time.Hour)), Username: username}
+			if err := repo.AddAuditEntry(ctx, entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+		revisions := func(entries []domain.AuditEntry) []int64 {
+			ids := make([]int64, 0, len(entries))
+			for _, e := range entries {
+				ids = append(ids, e.Rowid)
+			}
+			return ids
+		}
+		from, to := changed.Add(time.Hour), changed.Add(3*time.Hour)
+		if got, err := repo.GetAuditLog(ctx, AuditQuery{From: &from, To: &to}); err != nil || fmt.Sprint(revisions(got)) != "[2 3]" {
+			t.Errorf("got %v, %v, want revisions 2 and 3", revisions(got), err)
+		}
+		if got, err := repo.GetAuditLog(ctx, AuditQuery{Username: "ann", From: &from, Limit: 1}); err != nil || fmt.Sprint(revisions(got)) != "[3]" {
+			t.Errorf("got %v, %v, want the first revision of ann from the second hour", revisions(got), err)
+		}
+		if got, err := repo.GetAuditLog(ctx, AuditQuery{TaskID: 1}); err != nil || len(got) != 4 {
+			t.Errorf("got %v, %v, want every revision", revisions(got), err)
+		}
+	})
+
+	t.Run("webhooks", func(t *testing.T) {
+		repo := newRepo(t)
+		created := domain.Time(time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC))
+		first, err := repo.AddWebhook(ctx, domain.Webhook{URL: "http://a", Secret: "s1", Created: created})
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := repo.AddWebhook(ctx, domain.Webhook{URL: "http://b", Secret: "s2", Events: domain.EventList{domain.EventTaskDeleted}, Created: created})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w, err := repo.GetWebhook(ctx, second); err != nil || w.URL != "http://b" || w.Secret != "s2" || len(w.Events) != 1 || w.Events[0] != domain.EventTaskDeleted {
+			t.Errorf("got %+v, %v for the second webhook", w, err)
+		}
+		if all, err := repo.GetWebhooks(ctx); err != nil || len(all) != 2 || all[0].Rowid != first || len(all[0].Events) != 0 {
+			t.Errorf("got %+v, %v, want both webhooks by id", all, err)
+		}
+
+		ids := make([]int64, 0)
+		for i, webhookID := range []int64{first, first, second} {
+			delivery := domain.WebhookDelivery{WebhookID: webhookID, Event: domain.EventTaskCreated, Payload: fmt.Sprintf(`{"n":%d}`, i), Status: domain.DeliveryPending, NextAttempt: domain.Time(time.Time(created).Add(time.Duration(i) * time.Hour)), Created: created}
+			id, err := repo.AddWebhookDelivery(ctx, delivery)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		dueBefore := time.Time(created).Add(30 * time.Minute)
+		if due, err := repo.GetWebhookDeliveries(ctx, DeliveryQuery{Status: domain.DeliveryPending, DueBefore: &dueBefore}); err != nil || len(due) != 1 || due[0].Rowid != ids[0] {
+			t.Errorf("got %+v, %v, want the first delivery only", due, err)
+		}
+		if log, err := repo.GetWebhookDeliveries(ctx, DeliveryQuery{WebhookID: first, Newest: true, Limit: 1}); err != nil || len(log) != 1 || log[0].Rowid != ids[1] || log[0].Payload != `{"n":1}` {
+			t.Errorf("got %+v, %v, want the newest delivery of the first webhook", log, err)
+		}
+
+		delivery, err := repo.GetWebhookDelivery(ctx, ids[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		delivery.Failed(time.Time(created), 500, fmt.Errorf("down"))
+		if err := repo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+			t.Fatal(err)
+		}
+		if d, err := repo.GetWebhookDelivery(ctx, ids[0]); err != nil || d.Attempts != 1 || d.LastStatus != 500 || d.LastError != "down" || !time.Time(d.NextAttempt).After(time.Time(created)) {
+			t.Errorf("got %+v, %v after a failed attempt", d, err)
+		}
+
+		if err := repo.DeleteWebhook(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetWebhook(ctx, first); err != errors.ErrorObjectNotFound {
+			t.Errorf("got %v for a deleted webhook", err)
+		}
+		if _, err := repo.GetWebhookDelivery(ctx, ids[0]); err != errors.ErrorObjectNotFound {
+			t.Errorf("got %v for a delivery of a deleted webhook", err)
+		}
+		if err := repo.DeleteWebhook(ctx, first); err != errors.ErrorObjectNotFound {
+			t.Errorf("got %v for deleting a webhook twice", err)
+		}
+	})
+
+	t.Run("calendar feeds", func(t *testing.T) {
+		repo := newRepo(t)
+		created := domain.Time(time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC))
+		id, err := repo.AddCalendarFeed(ctx, domain.CalendarFeed{Name: "phone", TokenHash: domain.HashToken("t1"), Created: created})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.AddCalendarFeed(ctx, domain.CalendarFeed{Name: "again", TokenHash: domain.HashToken("t1"), Created: created}); err != errors.ErrorObjectAlreadyExists {
+			t.Errorf("got %v for a feed with the token of another", err)
+		}
+		if f, err := repo.GetCalendarFeedByToken(ctx, domain.HashToken("t1")); err != nil || f.Rowid != id || f.Name != "phone" || !time.Time(f.Created).Equal(time.Time(created)) {
+			t.Errorf("got %+v, %v for the feed of the token", f, err)
+		}
+		if _, err := repo.GetCalendarFeedByToken(ctx, domain.HashToken("t2")); err != errors.ErrorObjectNotFound {
+			t.Errorf("got %v for an unknown token", err)
+		}
+		if all, err := repo.GetCalendarFeeds(ctx); err != nil || len(all) != 1 || all[0].TokenHash != domain.HashToken("t1") {
+			t.Errorf("got %+v, %v, want the feed", all, err)
+		}
+		if err := repo.DeleteCalendarFeed(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.DeleteCalendarFeed(ctx, id); err != errors.ErrorObjectNotFound {
+			t.Errorf("got %v for deleting a feed twice", err)
+		}
+	})
+
+	t.Run("imported todos", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.AddTask(ctx, domain.Task{Title: "imported", Status: domain.Pending, DueDate: due})
+		if err != nil {
+			t.Fatal(err)
+		}
+		todo := domain.ImportedTodo{UID: "todo-1@phone", TaskID: id}
+		if err := repo.AddImportedTodo(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.AddImportedTodo(ctx, todo); err != errors.ErrorObjectAlreadyExists {
+			t.Errorf("got %v for importing a uid twice", err)
+		}
+		if got, err := repo.GetImportedTodo(ctx, todo.UID); err != nil || got != todo {
+			t.Errorf("got %+v, %v, want %+v", got, err, todo)
+		}
+		if _, err := repo.GetImportedTodo(ctx, "todo-2@phone"); err != errors.ErrorObjectNotFound {
+			t.Errorf("got %v for an unknown uid", err)
+		}
+		// the uid is forgotten along with its task
+		if err := repo.DeleteTask(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetImportedTodo(ctx, todo.UID); err != errors.ErrorObjectNotFound {
+			t.Errorf("got %v for the uid of a deleted task", err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.AddTask(ctx, domain.Task{Title: "trashed", DueDate: due})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.TrashTask(ctx, id, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		const missing = 999
+		if _, err := repo.GetTaskByID(ctx, missing); err != errors.ErrorObjectNotFound {
+			t.Errorf("GetTaskByID: got %v, want %v", err, errors.ErrorObjectNotFound)
+		}
+		if _, err := repo.GetTaskByID(ctx, id); err != errors.ErrorObjectNotFound {
+			t.Errorf("GetTaskByID: got %v for a task in the trash", err)
+		}
+		if trashed, err := repo.GetTrashedTask(ctx, id); err != nil || trashed.Title != "trashed" || trashed.DeletedAt == nil {
+			t.Errorf("GetTrashedTask: got %v, %v", trashed, err)
+		}
+		if _, err := repo.GetTrashedTask(ctx, missing); err != errors.ErrorObjectNotFound {
+			t.Errorf("GetTrashedTask: got %v, want %v", err, errors.ErrorObjectNotFound)
+		}
+		if _, err := repo.GetTaskByTitle(ctx, "trashed"); err != errors.ErrorObjectNotFound {
+			t.Errorf("GetTaskByTitle: got %v, want %v", err, errors.ErrorObjectNotFound)
+		}
+		if _, err := repo.GetAuditEntry(ctx, missing); err != errors.ErrorObjectNotFound {
+			t.Errorf("GetAuditEntry: got %v, want %v", err, errors.ErrorObjectNotFound)
+		}
+		if err := repo.AddTags(ctx, missing, []string{"tag"}); err != errors.ErrorObjectNotFound {
+			t.Errorf("AddTags: got %v, want %v", err, errors.ErrorObjectNotFound)
+		}
+		for _, rowid := range []int64{missing, id} {
+			if err := repo.UpdateTask(ctx, domain.Task{Rowid: rowid, Title: "updated", DueDate: due}); err != errors.ErrorObjectNotFound {
+				t.Errorf("UpdateTask(%d): got %v, want %v", rowid, err, errors.ErrorObjectNotFound)
+			}
+		}
+		if err := repo.DeleteTask(ctx, missing); err != errors.ErrorObjectNotFound {
+			t.Errorf("DeleteTask: got %v, want %v", err, errors.ErrorObjectNotFound)
+		}
+		if err := repo.DeleteTaskByTitle(ctx, "missing"); err != errors.ErrorObjectNotFound {
+			t.Errorf("DeleteTaskByTitle: got %v, want %v", err, errors.ErrorObjectNotFound)
+		}
+		if err := repo.TrashTask(ctx, missing, time.Now()); err == nil {
+			t.Errorf("TrashTask: expected an error")
+		}
+		if err := repo.RestoreFromTrash(ctx, missing); err == nil {
+			t.Errorf("RestoreFromTrash: expected an error")
+		}
+		if err := repo.RemoveTag(ctx, missing, "tag"); err == nil {
+			t.Errorf("RemoveTag: expected an error")
+		}
+		if _, err := repo.GetSubtree(ctx, missing); err == nil {
+			t.Errorf("GetSubtree: expected an error")
+		}
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		repo := newRepo(t)
+		const workers = 20
+		var wg sync.WaitGroup
+		errs := make(chan error, 2*workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				title := fmt.Sprintf("task %d", i)
+				addAndUpdate := func(repo ITaskRepo) error {
+					id, err := repo.AddTask(ctx, domain.Task{Title: title, DueDate: due})
+					if err != nil {
+						return err
+					}
+					return repo.UpdateTask(ctx, domain.Task{Rowid: id, Title: title, Status: domain.Done, DueDate: due})
+				}
+				// half of the workers write in a transaction
+				if i%2 == 0 {
+					errs <- repo.WithTransaction(ctx, addAndUpdate)
+				} else {
+					errs <- addAndUpdate(repo)
+				}
+				if _, err := repo.GetAllTasks(ctx); err != nil {
+					errs <- err
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Error(err)
+			}
+		}
+
+		all, err := repo.GetAllTasks(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[int64]bool)
+		for _, task := range all {
+			if task.Status != domain.Done {
+				t.Errorf("task %d lost its update", task.Rowid)
+			}
+			ids[task.Rowid] = true
+		}
+		if len(all) != workers || len(ids) != workers {
+			t.Errorf("got %d tasks with %d distinct ids, want %d", len(all), len(ids), workers)
+		}
+	})
+}
+
+func TestConformance(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		testConformance(t, newTestSqliteRepo)
+	})
+	t.Run("inmemory", func(t *testing.T) {
+		testConformance(t, func(t *testing.T) ITaskRepo { return newTestInMemoryRepo() })
+	})
+	if url := os.Getenv(postgresURLEnv); url != "" {
+		t.Run("postgres", func(t *testing.T) {
+			testConformance(t, func(t *testing.T) ITaskRepo { return newTestPostgresRepo(t, url) })
+		})
+	}
+}
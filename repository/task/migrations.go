@@ -0,0 +1,319 @@
+package task
+
+import (
+	"context"
+	"fmt"
+
+	"server/db"
+)
+
+// SqliteMigrations is the schema of the sqlite task repository. New migrations go at the end
+// of the list, with the next version. Search needs FTS5, so its migration is only part of builds
+// with the sqlite_fts5 tag, and is appended after the others.
+var SqliteMigrations = append([]db.Migration{
+	{
+		// Tables are only created if they don't exist, so that a db which was set up by hand
+		// with the old build.sql is taken over. Its task table gets the columns it lacks first.
+		Version: 1,
+		Name:    "tasks",
+		Before:  addBaselineColumns,
+		Up: []string{
+			`create table if not exists task (
+				rowid INTEGER primary key AUTOINCREMENT,
+				title TEXT not null,
+				description TEXT default "",
+				dueDate integer not null default CURRENT_TIMESTAMP,
+				status TEXT not null default "pending",
+				priority TINYINT not null default 0,
+				effort TEXT not null default "24h",
+				created integer default CURRENT_TIMESTAMP,
+				parentId INTEGER default null references task(rowid),
+				recurrence TEXT default null,
+				deletedAt integer default null,
+				constraint unique_title_name unique (title)
+			)`,
+			`create index if not exists task_parent_id on task (parentId)`,
+			`create table if not exists task_dependency (
+				taskId INTEGER not null references task(rowid),
+				blockedBy INTEGER not null references task(rowid),
+				constraint unique_task_dependency unique (taskId, blockedBy)
+			)`,
+			`create index if not exists task_dependency_blocked_by on task_dependency (blockedBy)`,
+			`create table if not exists task_tag (
+				taskId INTEGER not null references task(rowid),
+				tag TEXT not null,
+				constraint unique_task_tag unique (taskId, tag)
+			)`,
+			`create index if not exists task_tag_tag on task_tag (tag)`,
+			`create table if not exists task_completion (
+				rowid INTEGER primary key AUTOINCREMENT,
+				taskId INTEGER not null references task(rowid),
+				dueDate integer not null,
+				completed integer not null default CURRENT_TIMESTAMP
+			)`,
+			`create index if not exists task_completion_task_id on task_completion (taskId)`,
+			`create table if not exists task_history (
+				rowid INTEGER primary key AUTOINCREMENT,
+				taskId INTEGER not null references task(rowid),
+				oldStatus TEXT not null,
+				newStatus TEXT not null,
+				changed integer not null default CURRENT_TIMESTAMP,
+				username TEXT not null default ''
+			)`,
+			`create index if not exists task_history_task_id on task_history (taskId)`,
+			`create table if not exists task_audit (
+				rowid INTEGER primary key AUTOINCREMENT,
+				taskId INTEGER not null,
+				action TEXT not null,
+				changes TEXT not null,
+				snapshot TEXT not null,
+				changed integer not null default CURRENT_TIMESTAMP,
+				username TEXT not null default ''
+			)`,
+			`create index if not exists task_audit_task_id on task_audit (taskId)`,
+		},
+		Down: []string{
+			`drop table task_audit`,
+			`drop table task_history`,
+			`drop table task_completion`,
+			`drop table task_tag`,
+			`drop table task_dependency`,
+			`drop table task`,
+		},
+	},
+	{
+		// Times used to be stored without an offset, on a 12 hour clock, and read as UTC. They
+		// keep the instant they were read as, in RFC 3339 so that they still sort as strings.
+		Version: 3,
+		Name:    "utc_times",
+		Up:      rewriteTimes("%Y-%m-%dT%H:%M:%SZ"),
+		Down:    rewriteTimes("%Y-%m-%d %H:%M:%S"),
+	},
+	{
+		// task_reminder has the lead times of the reminders of tasks, and task_reminder_sent
+		// the reminders which went out, by notifier
+		Version: 4,
+		Name:    "reminders",
+		Up: []string{
+			`create table if not exists task_reminder (
+				taskId INTEGER not null references task(rowid),
+				leadTime TEXT not null,
+				constraint unique_task_reminder unique (taskId, leadTime)
+			)`,
+			`create table if not exists task_reminder_sent (
+				taskId INTEGER not null references task(rowid),
+				dueDate TEXT not null,
+				leadTime TEXT not null,
+				notifier TEXT not null,
+				sent TEXT not null,
+				constraint unique_task_reminder_sent unique (taskId, dueDate, leadTime, notifier)
+			)`,
+		},
+		Down: []string{
+			`drop table task_reminder_sent`,
+			`drop table task_reminder`,
+		},
+	},
+	{
+		// task_webhook has the subscriptions to events of tasks, and task_webhook_delivery the
+		// events on their way to them, which are kept as a delivery log
+		Version: 5,
+		Name:    "webhooks",
+		Up: []string{
+			`create table if not exists task_webhook (
+				rowid INTEGER primary key AUTOINCREMENT,
+				url TEXT not null,
+				secret TEXT not null,
+				events TEXT not null default '[]',
+				created TEXT not null
+			)`,
+			`create table if not exists task_webhook_delivery (
+				rowid INTEGER primary key AUTOINCREMENT,
+				webhookId INTEGER not null references task_webhook(rowid),
+				event TEXT not null,
+				payload TEXT not null,
+				status TEXT not null,
+				attempts INTEGER not null default 0,
+				nextAttempt TEXT not null,
+				lastStatus INTEGER not null default 0,
+				lastError TEXT not null default '',
+				created TEXT not null
+			)`,
+			`create index if not exists task_webhook_delivery_webhook_id on task_webhook_delivery (webhookId)`,
+			`create index if not exists task_webhook_delivery_next_attempt on task_webhook_delivery (status, nextAttempt)`,
+		},
+		Down: []string{
+			`drop table task_webhook_delivery`,
+			`drop table task_webhook`,
+		},
+	},
+	{
+		// task_calendar_feed has the feeds calendar apps subscribe to, with the hash of their token
+		Version: 6,
+		Name:    "calendar feeds",
+		Up: []string{
+			`create table if not exists task_calendar_feed (
+				rowid INTEGER primary key AUTOINCREMENT,
+				name TEXT not null,
+				tokenHash TEXT not null,
+				created TEXT not null,
+				constraint unique_task_calendar_feed_token unique (tokenHash)
+			)`,
+		},
+		Down: []string{
+			`drop table task_calendar_feed`,
+		},
+	},
+	{
+		// task_calendar_import has the uids of the to-dos which were imported, with their task
+		Version: 7,
+		Name:    "calendar imports",
+		Up: []string{
+			`create table if not exists task_calendar_import (
+				uid TEXT primary key,
+				taskId INTEGER not null references task(rowid)
+			)`,
+			`create index if not exists task_calendar_import_task_id on task_calendar_import (taskId)`,
+		},
+		Down: []string{
+			`drop table task_calendar_import`,
+		},
+	},
+	{
+		// Titles are only unique among the tasks which are not in the trash, so that a task can
+		// be created with the title of one in the trash. Sqlite cannot drop the constraint of
+		// the task table, which is rebuilt without it first.
+		Version: 8,
+		Name:    "trashed titles",
+		Before:  dropTitleConstraint,
+		Up: []string{
+			`create unique index if not exists task_title_unique on task (title) where deletedAt is null`,
+		},
+		Down: []string{
+			`drop index task_title_unique`,
+			`create unique index unique_title_name on task (title)`,
+		},
+	},
+}, searchMigrations...)
+
+// baselineColumns are the columns of the task table which the old build.sql added after creating
+// it. A db can have stopped at any of its steps.
+var baselineColumns = [][2]string{
+	{"parentId", "INTEGER default null references task(rowid)"},
+	{"recurrence", "TEXT default null"},
+	{"deletedAt", "integer default null"},
+}
+
+// addBaselineColumns adds the baselineColumns which an existing task table lacks
+func addBaselineColumns(ctx context.Context, tx db.Tx) error {
+	var tables int
+	if err := tx.QueryRow(ctx, "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'task'").Scan(&tables); err != nil {
+		return err
+	}
+	if tables == 0 {
+		return nil
+	}
+	for _, c := range baselineColumns {
+		var n int
+		if err := tx.QueryRow(ctx, "SELECT count(*) FROM pragma_table_info('task') WHERE name = ?", c[0]).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := tx.Execute(ctx, fmt.Sprintf("alter table task add column %s %s", c[0], c[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// taskColumns are the columns of the task table, once it has its baselineColumns
+const taskColumns = "rowid, title, description, dueDate, status, priority, effort, created, parentId, recurrence, deletedAt"
+
+// dropTitleConstraint rebuilds the task table without the unique_title_name constraint, along
+// with its indexes and triggers. Its rows are set aside in a temporary table and put back with
+// their rowids, and foreign keys are only checked once they are.
+func dropTitleConstraint(ctx context.Context, tx db.Tx) error {
+	var tables int
+	if err := tx.QueryRow(ctx, "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'task' AND sql LIKE '%unique_title_name%'").Scan(&tables); err != nil {
+		return err
+	}
+	if tables == 0 {
+		return nil
+	}
+	var seq int64
+	if err := tx.QueryRow(ctx, "SELECT coalesce(max(seq), 0) FROM sqlite_sequence WHERE name = 'task'").Scan(&seq); err != nil {
+		return err
+	}
+	rows, err := tx.Query(ctx, "SELECT sql FROM sqlite_master WHERE tbl_name = 'task' AND type IN ('index', 'trigger') AND sql IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	var schema []string
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			rows.Close()
+			return err
+		}
+		schema = append(schema, statement)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	statements := []string{
+		`PRAGMA defer_foreign_keys = ON`,
+		fmt.Sprintf("create temp table task_rebuilt as select %s from task", taskColumns),
+		`drop table task`,
+		`create table task (
+			rowid INTEGER primary key AUTOINCREMENT,
+			title TEXT not null,
+			description TEXT default "",
+			dueDate integer not null default CURRENT_TIMESTAMP,
+			status TEXT not null default "pending",
+			priority TINYINT not null default 0,
+			effort TEXT not null default "24h",
+			created integer default CURRENT_TIMESTAMP,
+			parentId INTEGER default null references task(rowid),
+			recurrence TEXT default null,
+			deletedAt integer default null
+		)`,
+		fmt.Sprintf("insert into task (%s) select %s from temp.task_rebuilt", taskColumns, taskColumns),
+		`drop table temp.task_rebuilt`,
+	}
+	statements = append(statements, schema...)
+	for _, statement := range statements {
+		if _, err := tx.Execute(ctx, statement); err != nil {
+			return err
+		}
+	}
+	// rowids of tasks which were deleted are not handed out again
+	_, err = tx.Execute(ctx, "UPDATE sqlite_sequence SET seq = max(seq, ?) WHERE name = 'task'", seq)
+	return err
+}
+
+// timeColumns are the columns holding times, by table
+var timeColumns = [][2]string{
+	{"task", "dueDate"},
+	{"task", "created"},
+	{"task", "deletedAt"},
+	{"task_completion", "dueDate"},
+	{"task_completion", "completed"},
+	{"task_history", "changed"},
+	{"task_audit", "changed"},
+}
+
+// rewriteTimes returns statements rewriting every time stored as text in the strftime format
+func rewriteTimes(format string) []string {
+	statements := make([]string, 0, len(timeColumns))
+	for _, c := range timeColumns {
+		table, column := c[0], c[1]
+		statements = append(statements, fmt.Sprintf(
+			"update %s set %s = coalesce(strftime('%s', %s), %s) where typeof(%s) = 'text'",
+			table, column, format, column, column, column))
+	}
+	return statements
+}
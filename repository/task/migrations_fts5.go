@@ -0,0 +1,43 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package task
+
+import (
+	"server/db"
+)
+
+// searchMigrations set up the full text search index of tasks, and the triggers keeping it in
+// sync with the task table
+var searchMigrations = []db.Migration{
+	{
+		Version: 2,
+		Name:    "search",
+		Up: []string{
+			`create virtual table if not exists task_fts using fts5 (
+				title,
+				description,
+				content='task',
+				content_rowid='rowid'
+			)`,
+			`create trigger if not exists task_fts_insert after insert on task begin
+				insert into task_fts (rowid, title, description) values (new.rowid, new.title, new.description);
+			end`,
+			`create trigger if not exists task_fts_delete after delete on task begin
+				insert into task_fts (task_fts, rowid, title, description) values ('delete', old.rowid, old.title, old.description);
+			end`,
+			`create trigger if not exists task_fts_update after update of title, description on task begin
+				insert into task_fts (task_fts, rowid, title, description) values ('delete', old.rowid, old.title, old.description);
+				insert into task_fts (rowid, title, description) values (new.rowid, new.title, new.description);
+			end`,
+			// index the tasks which already exist
+			`insert into task_fts (task_fts) values ('rebuild')`,
+		},
+		Down: []string{
+			`drop trigger task_fts_update`,
+			`drop trigger task_fts_delete`,
+			`drop trigger task_fts_insert`,
+			`drop table task_fts`,
+		},
+	},
+}
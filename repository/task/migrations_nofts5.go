@@ -0,0 +1,11 @@
+//go:build !sqlite_fts5
+// +build !sqlite_fts5
+
+package task
+
+import (
+	"server/db"
+)
+
+// searchMigrations is empty without FTS5, search then fails with a hint to rebuild the server
+var searchMigrations []db.Migration
@@ -0,0 +1,207 @@
+package task
+
+import (
+	"fmt"
+
+	"server/db"
+)
+
+// PostgresMigrations is the schema of the postgres task repository, kept in step with
+// SqliteMigrations. Rowids are filled in by sequences. Migration 1 creates the times as
+// timestamps without time zone, and migration 3 turns them into timestamps with time zone.
+var PostgresMigrations = []db.Migration{
+	{
+		Version: 1,
+		Name:    "tasks",
+		Up: []string{
+			`create table task (
+				rowid BIGSERIAL primary key,
+				title TEXT not null,
+				description TEXT default '',
+				dueDate TIMESTAMP not null default CURRENT_TIMESTAMP,
+				status TEXT not null default 'pending',
+				priority SMALLINT not null default 0,
+				effort TEXT not null default '24h',
+				created TIMESTAMP default CURRENT_TIMESTAMP,
+				parentId BIGINT default null references task(rowid),
+				recurrence TEXT default null,
+				deletedAt TIMESTAMP default null,
+				constraint unique_title_name unique (title)
+			)`,
+			`create index task_parent_id on task (parentId)`,
+			`create table task_dependency (
+				taskId BIGINT not null references task(rowid),
+				blockedBy BIGINT not null references task(rowid),
+				constraint unique_task_dependency unique (taskId, blockedBy)
+			)`,
+			`create index task_dependency_blocked_by on task_dependency (blockedBy)`,
+			`create table task_tag (
+				taskId BIGINT not null references task(rowid),
+				tag TEXT not null,
+				constraint unique_task_tag unique (taskId, tag)
+			)`,
+			`create index task_tag_tag on task_tag (tag)`,
+			`create table task_completion (
+				rowid BIGSERIAL primary key,
+				taskId BIGINT not null references task(rowid),
+				dueDate TIMESTAMP not null,
+				completed TIMESTAMP not null default CURRENT_TIMESTAMP
+			)`,
+			`create index task_completion_task_id on task_completion (taskId)`,
+			`create table task_history (
+				rowid BIGSERIAL primary key,
+				taskId BIGINT not null references task(rowid),
+				oldStatus TEXT not null,
+				newStatus TEXT not null,
+				changed TIMESTAMP not null default CURRENT_TIMESTAMP,
+				username TEXT not null default ''
+			)`,
+			`create index task_history_task_id on task_history (taskId)`,
+			`create table task_audit (
+				rowid BIGSERIAL primary key,
+				taskId BIGINT not null,
+				action TEXT not null,
+				changes TEXT not null,
+				snapshot TEXT not null,
+				changed TIMESTAMP not null default CURRENT_TIMESTAMP,
+				username TEXT not null default ''
+			)`,
+			`create index task_audit_task_id on task_audit (taskId)`,
+		},
+		Down: []string{
+			`drop table task_audit`,
+			`drop table task_history`,
+			`drop table task_completion`,
+			`drop table task_tag`,
+			`drop table task_dependency`,
+			`drop table task`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "search",
+		Up: []string{
+			`create index task_search on task using gin (` + searchVector + `)`,
+		},
+		Down: []string{
+			`drop index task_search`,
+		},
+	},
+	{
+		// Times used to be timestamps without time zone, which were read as UTC
+		Version: 3,
+		Name:    "utc_times",
+		Up:      alterTimes("TIMESTAMPTZ"),
+		Down:    alterTimes("TIMESTAMP"),
+	},
+	{
+		Version: 4,
+		Name:    "reminders",
+		Up: []string{
+			`create table task_reminder (
+				taskId BIGINT not null references task(rowid),
+				leadTime TEXT not null,
+				constraint unique_task_reminder unique (taskId, leadTime)
+			)`,
+			`create table task_reminder_sent (
+				taskId BIGINT not null references task(rowid),
+				dueDate TIMESTAMPTZ not null,
+				leadTime TEXT not null,
+				notifier TEXT not null,
+				sent TIMESTAMPTZ not null,
+				constraint unique_task_reminder_sent unique (taskId, dueDate, leadTime, notifier)
+			)`,
+		},
+		Down: []string{
+			`drop table task_reminder_sent`,
+			`drop table task_reminder`,
+		},
+	},
+	{
+		Version: 5,
+		Name:    "webhooks",
+		Up: []string{
+			`create table task_webhook (
+				rowid BIGSERIAL primary key,
+				url TEXT not null,
+				secret TEXT not null,
+				events TEXT not null default '[]',
+				created TIMESTAMPTZ not null
+			)`,
+			`create table task_webhook_delivery (
+				rowid BIGSERIAL primary key,
+				webhookId BIGINT not null references task_webhook(rowid),
+				event TEXT not null,
+				payload TEXT not null,
+				status TEXT not null,
+				attempts INTEGER not null default 0,
+				nextAttempt TIMESTAMPTZ not null,
+				lastStatus INTEGER not null default 0,
+				lastError TEXT not null default '',
+				created TIMESTAMPTZ not null
+			)`,
+			`create index task_webhook_delivery_webhook_id on task_webhook_delivery (webhookId)`,
+			`create index task_webhook_delivery_next_attempt on task_webhook_delivery (status, nextAttempt)`,
+		},
+		Down: []string{
+			`drop table task_webhook_delivery`,
+			`drop table task_webhook`,
+		},
+	},
+	{
+		Version: 6,
+		Name:    "calendar feeds",
+		Up: []string{
+			`create table task_calendar_feed (
+				rowid BIGSERIAL primary key,
+				name TEXT not null,
+				tokenHash TEXT not null,
+				created TIMESTAMPTZ not null,
+				constraint unique_task_calendar_feed_token unique (tokenHash)
+			)`,
+		},
+		Down: []string{
+			`drop table task_calendar_feed`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "calendar imports",
+		Up: []string{
+			`create table task_calendar_import (
+				uid TEXT primary key,
+				taskId BIGINT not null references task(rowid)
+			)`,
+			`create index task_calendar_import_task_id on task_calendar_import (taskId)`,
+		},
+		Down: []string{
+			`drop table task_calendar_import`,
+		},
+	},
+	{
+		// Titles are only unique among the tasks which are not in the trash
+		Version: 8,
+		Name:    "trashed titles",
+		Up: []string{
+			`alter table task drop constraint unique_title_name`,
+			`create unique index task_title_unique on task (title) where deletedAt is null`,
+		},
+		Down: []string{
+			`drop index task_title_unique`,
+			`alter table task add constraint unique_title_name unique (title)`,
+		},
+	},
+}
+
+// alterTimes returns statements changing the type of every column holding times. Times without
+// time zone are in UTC.
+func alterTimes(columnType string) []string {
+	statements := make([]string, 0, len(timeColumns))
+	for _, c := range timeColumns {
+		table, column := c[0], c[1]
+		statements = append(statements, fmt.Sprintf(
+			"ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s AT TIME ZONE 'UTC'",
+			table, column, columnType, column))
+	}
+	return statements
+}
@@ -0,0 +1,71 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"server/db"
+	"server/domain"
+)
+
+// baselineSchema is the old build.sql, up to the first column it added
+var baselineSchema = []string{
+	`create table task (
+		rowid INTEGER primary key AUTOINCREMENT,
+		title TEXT not null,
+		description TEXT default "",
+		dueDate integer not null default CURRENT_TIMESTAMP,
+		status TEXT not null default "pending",
+		priority TINYINT not null default 0,
+		effort TEXT not null default "24h",
+		created integer default CURRENT_TIMESTAMP,
+		constraint unique_title_name unique (title)
+	)`,
+	`insert into task (title, description) values ("Hello world", "My first task")`,
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	ctx := context.Background()
+	cases := map[string][]string{
+		"baseline": baselineSchema,
+		// a db which stopped half way through build.sql
+		"subtasks": append(append([]string{}, baselineSchema...),
+			`alter table task add column parentId INTEGER default null references task(rowid)`,
+			`create index task_parent_id on task (parentId)`),
+	}
+	for name, schema := range cases {
+		handler := db.NewSqliteHandler(":memory:", 0)
+		handler.Conn.SetMaxOpenConns(1)
+		for _, statement := range schema {
+			if _, err := handler.Execute(ctx, statement); err != nil {
+				t.Fatal(err)
+			}
+		}
+		migrator, err := db.NewMigrator(handler, SqliteMigrations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrator.Up(ctx); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		repo := newTaskRepoSqlite(handler)
+		hello, err := repo.GetTaskByTitle(ctx, "Hello world")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		due := domain.Time(time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC))
+		recurrence := domain.Recurrence{Freq: domain.Daily, Interval: 1}
+		id, err := repo.AddTask(ctx, domain.Task{Title: "subtask", DueDate: due, ParentID: &hello.Rowid, Recurrence: &recurrence})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if children, err := repo.GetChildTasks(ctx, hello.Rowid); err != nil || len(children) != 1 || children[0].Rowid != id || children[0].Recurrence == nil {
+			t.Errorf("%s: got %v, %v", name, children, err)
+		}
+		if err := repo.TrashTask(ctx, id, time.Now()); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
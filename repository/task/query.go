@@ -0,0 +1,299 @@
+package task
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/domain"
+	"server/errors"
+)
+
+// SortField is a column on which tasks can be ordered
+type SortField string
+
+const (
+	// SortByID orders tasks by their rowid. It is the default.
+	SortByID SortField = "id"
+	// SortByDueDate orders tasks by due date
+	SortByDueDate SortField = "dueDate"
+	// SortByPriority orders tasks by priority
+	SortByPriority SortField = "priority"
+	// SortByCreated orders tasks by creation time
+	SortByCreated SortField = "created"
+	// SortByTitle orders tasks alphabetically by title
+	SortByTitle SortField = "title"
+)
+
+// sortColumns maps a sort field to the name of the column in the task table
+var sortColumns = map[SortField]string{
+	SortByID:       "rowid",
+	SortByDueDate:  "dueDate",
+	SortByPriority: "priority",
+	SortByCreated:  "created",
+	SortByTitle:    "title",
+}
+
+// IsValid checks if tasks can be sorted on this field
+func (s SortField) IsValid() bool {
+	_, ok := sortColumns[s]
+	return ok
+}
+
+// Query describes a filtered, sorted and paginated listing of tasks.
+// Zero values mean "no filter". A Limit of 0 returns every matching task.
+// Tasks match Tags if they have any of them, or all of them when AllTags is set.
+type Query struct {
+	Statuses    []domain.Status
+	MinPriority domain.Priority
+	MaxPriority domain.Priority
+	DueBefore   *time.Time
+	DueAfter    *time.Time
+	Title       string
+	Tags        []string
+	AllTags     bool
+	SortBy      SortField
+	Descending  bool
+	Cursor      string
+	Limit       int
+}
+
+// Page is one page of a Query result. NextCursor is empty on the last page.
+// Total is the number of tasks matching the filters, irrespective of pagination.
+type Page struct {
+	Tasks      []domain.Task
+	NextCursor string
+	Total      int64
+}
+
+// cursor marks the position of the last task returned in a page. Value is the
+// sort column of that task in its string form, and ID breaks ties.
+type cursor struct {
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func (c cursor) encode() string {
+	j, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(j)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errors.AggError{Code: errors.ErrorInvalidArgument.Code, Message: "invalid cursor"}
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, errors.AggError{Code: errors.ErrorInvalidArgument.Code, Message: "invalid cursor"}
+	}
+	return c, nil
+}
+
+// normalize fills in defaults for a query
+func (q Query) normalize() Query {
+	if q.SortBy == "" {
+		q.SortBy = SortByID
+	}
+	return q
+}
+
+// sortValue returns the value of the sort field of a task, as it is put in a cursor. Times are
+// in UTC, in RFC 3339 to the second, the form the db keeps them in.
+func sortValue(t domain.Task, field SortField) string {
+	switch field {
+	case SortByDueDate:
+		return t.DueDate.String()
+	case SortByPriority:
+		return strconv.Itoa(int(t.Priority))
+	case SortByCreated:
+		return t.Created.String()
+	case SortByTitle:
+		return t.Title
+	default:
+		return strconv.FormatInt(t.Rowid, 10)
+	}
+}
+
+// compareSortValues compares two sort values of a field, returning -1, 0 or 1. Numbers are
+// compared as numbers. Times in UTC, in RFC 3339 to the second, all have the same length, so they
+// compare as strings in time order.
+func compareSortValues(field SortField, a, b string) int {
+	if field == SortByPriority || field == SortByID {
+		x, _ := strconv.ParseInt(a, 10, 64)
+		y, _ := strconv.ParseInt(b, 10, 64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// less reports whether task a comes before task b in the ordering of the query
+func (q Query) less(a, b domain.Task) bool {
+	c := compareSortValues(q.SortBy, sortValue(a, q.SortBy), sortValue(b, q.SortBy))
+	if c == 0 {
+		c = compareSortValues(SortByID, sortValue(a, SortByID), sortValue(b, SortByID))
+	}
+	if q.Descending {
+		return c > 0
+	}
+	return c < 0
+}
+
+// after reports whether task t comes after the position marked by cursor c
+func (q Query) after(t domain.Task, c cursor) bool {
+	cmp := compareSortValues(q.SortBy, sortValue(t, q.SortBy), c.Value)
+	if cmp == 0 {
+		cmp = compareSortValues(SortByID, strconv.FormatInt(t.Rowid, 10), strconv.FormatInt(c.ID, 10))
+	}
+	if q.Descending {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+// matches checks whether a task satisfies all the filters of the query
+func (q Query) matches(t domain.Task) bool {
+	if len(q.Statuses) > 0 {
+		found := false
+		for _, s := range q.Statuses {
+			if t.Status == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.MinPriority != 0 && t.Priority < q.MinPriority {
+		return false
+	}
+	if q.MaxPriority != 0 && t.Priority > q.MaxPriority {
+		return false
+	}
+	if q.DueBefore != nil && !time.Time(t.DueDate).Before(*q.DueBefore) {
+		return false
+	}
+	if q.DueAfter != nil && !time.Time(t.DueDate).After(*q.DueAfter) {
+		return false
+	}
+	if q.Title != "" && !strings.Contains(strings.ToLower(t.Title), strings.ToLower(q.Title)) {
+		return false
+	}
+	if len(q.Tags) > 0 {
+		found := 0
+		for _, tag := range q.Tags {
+			if hasTag(t, tag) {
+				found++
+			}
+		}
+		if found == 0 || (q.AllTags && found < len(q.Tags)) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTag(t domain.Task, tag string) bool {
+	for _, tt := range t.Tags {
+		if tt == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate applies a query on a list of tasks in memory. Repositories which
+// can't push the query down to their storage use it.
+func paginate(tasks []domain.Task, q Query) (Page, error) {
+	q = q.normalize()
+	var c *cursor
+	if q.Cursor != "" {
+		decoded, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		c = &decoded
+	}
+
+	matched := make([]domain.Task, 0)
+	for _, t := range tasks {
+		if q.matches(t) {
+			matched = append(matched, t)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return q.less(matched[i], matched[j]) })
+
+	page := Page{Tasks: make([]domain.Task, 0), Total: int64(len(matched))}
+	for _, t := range matched {
+		if c != nil && !q.after(t, *c) {
+			continue
+		}
+		if q.Limit > 0 && len(page.Tasks) == q.Limit {
+			last := page.Tasks[len(page.Tasks)-1]
+			page.NextCursor = cursor{sortValue(last, q.SortBy), last.Rowid}.encode()
+			break
+		}
+		page.Tasks = append(page.Tasks, t)
+	}
+	return page, nil
+}
+
+// AuditQuery filters the audit log. Zero values mean "no filter". From is inclusive and
+// To is exclusive. A Limit of 0 returns all of the revisions.
+type AuditQuery struct {
+	TaskID   int64
+	Username string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+}
+
+func (q AuditQuery) matches(e domain.AuditEntry) bool {
+	if q.TaskID != 0 && e.TaskID != q.TaskID {
+		return false
+	}
+	if q.Username != "" && e.Username != q.Username {
+		return false
+	}
+	changed := time.Time(e.Changed)
+	if q.From != nil && changed.Before(*q.From) {
+		return false
+	}
+	if q.To != nil && !changed.Before(*q.To) {
+		return false
+	}
+	return true
+}
+
+// DeliveryQuery filters webhook deliveries. Zero values mean "no filter". Deliveries come oldest
+// first, or newest first with Newest, and a Limit of 0 returns all of them.
+type DeliveryQuery struct {
+	WebhookID int64
+	Status    domain.DeliveryStatus
+	DueBefore *time.Time
+	Newest    bool
+	Limit     int
+}
+
+func (q DeliveryQuery) matches(d domain.WebhookDelivery) bool {
+	if q.WebhookID != 0 && d.WebhookID != q.WebhookID {
+		return false
+	}
+	if q.Status != "" && d.Status != q.Status {
+		return false
+	}
+	if q.DueBefore != nil && time.Time(d.NextAttempt).After(*q.DueBefore) {
+		return false
+	}
+	return true
+}
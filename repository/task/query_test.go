@@ -0,0 +1,74 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"server/domain"
+)
+
+func TestGetPaginatedTasks(t *testing.T) {
+	dueBefore := time.Date(2030, 1, 4, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name  string
+		query Query
+		want  []string
+		total int64
+	}{
+		{"no filters", Query{}, []string{"write report", "review report", "buy milk", "call 100%", "fix bike"}, 5},
+		{"status", Query{Statuses: []domain.Status{domain.Pending}}, []string{"write report", "call 100%", "fix bike"}, 3},
+		{"priority range", Query{MinPriority: 3, MaxPriority: 4}, []string{"write report", "call 100%", "fix bike"}, 3},
+		{"due before", Query{DueBefore: &dueBefore}, []string{"write report", "review report", "buy milk"}, 3},
+		{"title", Query{Title: "REPORT"}, []string{"write report", "review report"}, 2},
+		{"title with wildcard", Query{Title: "0%"}, []string{"call 100%"}, 1},
+		{"sort by priority desc", Query{SortBy: SortByPriority, Descending: true}, []string{"review report", "fix bike", "call 100%", "write report", "buy milk"}, 5},
+		{"sort by due date", Query{SortBy: SortByDueDate, Limit: 2}, []string{"review report", "buy milk"}, 5},
+	}
+
+	for repoName, repo := range testRepos(t) {
+		seedTasks(t, repo)
+		for _, c := range cases {
+			page, err := repo.GetPaginatedTasks(context.Background(), c.query)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", repoName, c.name, err)
+			}
+			if got := titles(page.Tasks); !equal(got, c.want) {
+				t.Errorf("%s/%s: got %v, want %v", repoName, c.name, got, c.want)
+			}
+			if page.Total != c.total {
+				t.Errorf("%s/%s: got total %d, want %d", repoName, c.name, page.Total, c.total)
+			}
+		}
+	}
+}
+
+func TestGetPaginatedTasksCursor(t *testing.T) {
+	for repoName, repo := range testRepos(t) {
+		seedTasks(t, repo)
+		q := Query{SortBy: SortByPriority, Descending: true, Limit: 2}
+		var got []string
+		for i := 0; i < 5; i++ {
+			page, err := repo.GetPaginatedTasks(context.Background(), q)
+			if err != nil {
+				t.Fatalf("%s: %v", repoName, err)
+			}
+			got = append(got, titles(page.Tasks)...)
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		want := []string{"review report", "fix bike", "call 100%", "write report", "buy milk"}
+		if !equal(got, want) {
+			t.Errorf("%s: got %v, want %v", repoName, got, want)
+		}
+	}
+}
+
+func TestGetPaginatedTasksInvalidCursor(t *testing.T) {
+	repo := newTestInMemoryRepo()
+	if _, err := repo.GetPaginatedTasks(context.Background(), Query{Cursor: "not a cursor"}); err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+}
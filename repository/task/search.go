@@ -0,0 +1,228 @@
+package task
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"server/domain"
+	"server/errors"
+)
+
+// SearchQuery is a full text search over the title and description of tasks. Text is a list of
+// words, all of which have to match. A word ending in * matches as a prefix, and words in double
+// quotes match as a phrase. A Limit of 0 returns every match.
+type SearchQuery struct {
+	Text  string
+	Limit int
+}
+
+// searchTerm is one word, prefix or phrase of a search
+type searchTerm struct {
+	words  []string
+	prefix bool
+}
+
+// Markers around matching words in snippets, and around cut text. Snippets are HTML, the text
+// of tasks is escaped in them.
+const (
+	highlightStart = "<b>"
+	highlightEnd   = "</b>"
+	ellipsis       = "…"
+	snippetWords   = 10
+)
+
+// Markers around matching words in the snippets made by the db. They are private use characters,
+// which are turned into highlightStart and highlightEnd once the snippet is escaped.
+const (
+	matchStart = "\uE000"
+	matchEnd   = "\uE001"
+)
+
+var matchReplacer = strings.NewReplacer(matchStart, highlightStart, matchEnd, highlightEnd)
+
+// escapeSnippet escapes a snippet made by the db, and highlights its matches
+func escapeSnippet(s string) string {
+	return matchReplacer.Replace(html.EscapeString(s))
+}
+
+// tokenPattern splits text into words, the same way the unicode61 tokenizer of FTS5 does
+var tokenPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// parseSearch splits the text of a search into terms
+func parseSearch(text string) ([]searchTerm, error) {
+	if strings.Count(text, `"`)%2 != 0 {
+		return nil, errors.AggError{Code: errors.ErrorInvalidArgument.Code, Message: "unterminated phrase in search"}
+	}
+
+	var terms []searchTerm
+	for i, part := range strings.Split(text, `"`) {
+		if i%2 == 1 {
+			// inside quotes
+			if words := tokenize(part); len(words) > 0 {
+				terms = append(terms, searchTerm{words: words})
+			}
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			words := tokenize(field)
+			for _, word := range words {
+				terms = append(terms, searchTerm{words: []string{word}})
+			}
+			if len(words) > 0 && strings.HasSuffix(field, "*") {
+				terms[len(terms)-1].prefix = true
+			}
+		}
+	}
+	if len(terms) == 0 {
+		return nil, errors.AggError{Code: errors.ErrorInvalidArgument.Code, Message: "nothing to search for"}
+	}
+	return terms, nil
+}
+
+func tokenize(s string) []string {
+	words := tokenPattern.FindAllString(s, -1)
+	for i := range words {
+		words[i] = strings.ToLower(words[i])
+	}
+	return words
+}
+
+// matchExpression builds a FTS5 MATCH expression from search terms. Every word is quoted,
+// so nothing in the search text is taken as FTS5 syntax.
+func matchExpression(terms []searchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		part := `"` + strings.Join(t.words, " ") + `"`
+		if t.prefix {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// textMatch is where a term matched in a text, as indexes of its words
+type textMatch struct {
+	start, end int
+}
+
+// find returns every place where a term matches a list of words
+func (t searchTerm) find(words []string) []textMatch {
+	var matches []textMatch
+	for i := 0; i+len(t.words) <= len(words); i++ {
+		matched := true
+		for j, w := range t.words {
+			last := j == len(t.words)-1
+			if words[i+j] != w && !(last && t.prefix && strings.HasPrefix(words[i+j], w)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, textMatch{i, i + len(t.words)})
+		}
+	}
+	return matches
+}
+
+// searchTasks emulates the FTS5 search in memory. A task matches if every term is found in its
+// title or description. Matches in the title count ten times as much as those in the description,
+// like the weights given to bm25 in sqlite.
+func searchTasks(tasks []domain.Task, q SearchQuery) ([]domain.SearchResult, error) {
+	terms, err := parseSearch(q.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]domain.SearchResult, 0)
+	for _, t := range tasks {
+		titleWords, descriptionWords := tokenize(t.Title), tokenize(t.Description)
+		var titleMatches, descriptionMatches []textMatch
+		found := true
+		for _, term := range terms {
+			inTitle, inDescription := term.find(titleWords), term.find(descriptionWords)
+			if len(inTitle) == 0 && len(inDescription) == 0 {
+				found = false
+				break
+			}
+			titleMatches = append(titleMatches, inTitle...)
+			descriptionMatches = append(descriptionMatches, inDescription...)
+		}
+		if !found {
+			continue
+		}
+
+		s := snippet(t.Title, titleMatches)
+		if len(descriptionMatches) > len(titleMatches) {
+			s = snippet(t.Description, descriptionMatches)
+		}
+		score := float64(10*len(titleMatches) + len(descriptionMatches))
+		results = append(results, domain.SearchResult{Task: t, Snippet: s, Score: score})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Task.Rowid < results[j].Task.Rowid
+	})
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+// snippet cuts text down to snippetWords words around the first match, escapes it and
+// highlights the matching words
+func snippet(text string, matches []textMatch) string {
+	spans := tokenPattern.FindAllStringIndex(text, -1)
+	if len(spans) == 0 {
+		return html.EscapeString(text)
+	}
+
+	highlighted := make(map[int]bool)
+	first := len(spans)
+	for _, m := range matches {
+		for i := m.start; i < m.end; i++ {
+			highlighted[i] = true
+		}
+		if m.start < first {
+			first = m.start
+		}
+	}
+
+	from := first - snippetWords/2
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+	if to > len(spans) {
+		to = len(spans)
+		if from = to - snippetWords; from < 0 {
+			from = 0
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString(ellipsis)
+	}
+	pos := spans[from][0]
+	for i := from; i < to; i++ {
+		b.WriteString(html.EscapeString(text[pos:spans[i][0]]))
+		word := text[spans[i][0]:spans[i][1]]
+		if highlighted[i] {
+			word = highlightStart + word + highlightEnd
+		}
+		b.WriteString(word)
+		pos = spans[i][1]
+	}
+	if to < len(spans) {
+		b.WriteString(ellipsis)
+	} else {
+		b.WriteString(html.EscapeString(text[pos:]))
+	}
+	return b.String()
+}
@@ -0,0 +1,13 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package task
+
+import (
+	"testing"
+)
+
+// TestSearchTasksSqlite needs sqlite with FTS5: go test -tags sqlite_fts5 ./...
+func TestSearchTasksSqlite(t *testing.T) {
+	testSearch(t, "sqlite", newTestSqliteRepo(t))
+}
@@ -0,0 +1,109 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"server/domain"
+)
+
+func seedSearchTasks(t *testing.T, repo ITaskRepo) {
+	due := domain.Time(time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC))
+	tasks := []domain.Task{
+		{Rowid: 1, Title: "write report", Description: "quarterly numbers for the board", DueDate: due},
+		{Rowid: 2, Title: "review pull request", Description: "the report generator needs a second look", DueDate: due},
+		{Rowid: 3, Title: "buy milk", Description: "and eggs, from the shop near the station", DueDate: due},
+		{Rowid: 4, Title: "board meeting", Description: "present the quarterly report to the board", DueDate: due},
+		{Rowid: 5, Title: "fix <script>alert(1)</script> & co", DueDate: due},
+	}
+	for _, task := range tasks {
+		if _, err := repo.AddTask(context.Background(), task); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testSearch is run on every repository which supports SearchTasks
+func testSearch(t *testing.T, repoName string, repo ITaskRepo) {
+	ctx := context.Background()
+	seedSearchTasks(t, repo)
+
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"milk", []string{"buy milk"}},
+		{"EGGS shop", []string{"buy milk"}},
+		{"board", []string{"board meeting", "write report"}},
+		{`"quarterly report"`, []string{"board meeting"}},
+		{"quart*", []string{"write report", "board meeting"}},
+		{"gen*", []string{"review pull request"}},
+		{"milk report", []string{}},
+		{`board OR "`, nil},
+	}
+	for _, c := range cases {
+		results, err := repo.SearchTasks(ctx, SearchQuery{Text: c.text})
+		if c.want == nil {
+			if err == nil {
+				t.Errorf("%s/%s: expected an error", repoName, c.text)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s/%s: %v", repoName, c.text, err)
+		}
+		got := make([]string, 0, len(results))
+		for _, r := range results {
+			got = append(got, r.Task.Title)
+		}
+		if !equal(got, c.want) {
+			t.Errorf("%s/%s: got %v, want %v", repoName, c.text, got, c.want)
+		}
+	}
+
+	results, err := repo.SearchTasks(ctx, SearchQuery{Text: "eggs", Limit: 1})
+	if err != nil {
+		t.Fatalf("%s: %v", repoName, err)
+	}
+	if want := "and <b>eggs</b>, from the shop near the station"; len(results) != 1 || results[0].Snippet != want {
+		t.Errorf("%s: got %v, want snippet %q", repoName, results, want)
+	}
+
+	// snippets are HTML, only their highlights are tags
+	results, err = repo.SearchTasks(ctx, SearchQuery{Text: "alert"})
+	if err != nil {
+		t.Fatalf("%s: %v", repoName, err)
+	}
+	if want := "fix &lt;script&gt;<b>alert</b>(1)&lt;/script&gt; &amp; co"; len(results) != 1 || results[0].Snippet != want {
+		t.Errorf("%s: got %v, want snippet %q", repoName, results, want)
+	}
+
+	// the index follows updates, and leaves out tasks in the trash
+	task, _ := repo.GetTaskByID(ctx, 3)
+	task.Title = "buy oat milk"
+	if err := repo.UpdateTask(ctx, task); err != nil {
+		t.Fatalf("%s: %v", repoName, err)
+	}
+	if results, _ := repo.SearchTasks(ctx, SearchQuery{Text: "oat"}); len(results) != 1 {
+		t.Errorf("%s: got %v after renaming", repoName, results)
+	}
+	if err := repo.TrashTask(ctx, 3, time.Now()); err != nil {
+		t.Fatalf("%s: %v", repoName, err)
+	}
+	if results, _ := repo.SearchTasks(ctx, SearchQuery{Text: "oat"}); len(results) != 0 {
+		t.Errorf("%s: got %v after trashing", repoName, results)
+	}
+}
+
+func TestSearchTasks(t *testing.T) {
+	testSearch(t, "inmemory", newTestInMemoryRepo())
+}
+
+func TestSnippet(t *testing.T) {
+	text := "one two three four five six seven eight nine ten eleven twelve thirteen"
+	terms, _ := parseSearch("nine")
+	if got, want := snippet(text, terms[0].find(tokenize(text))), "…four five six seven eight <b>nine</b> ten eleven twelve thirteen"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"server/db"
 	"server/domain"
 	"sync"
+	"time"
 )
 
 var (
@@ -23,11 +24,62 @@ func Repository() ITaskRepo {
 // ITaskRepo implements CRUD operation for Task
 type ITaskRepo interface {
 	GetTaskByTitle(ctx context.Context, title string) (domain.Task, error)
+	GetTaskByID(ctx context.Context, id int64) (domain.Task, error)
 	GetAllTasks(ctx context.Context) ([]domain.Task, error)
+	GetPaginatedTasks(ctx context.Context, q Query) (Page, error)
+	GetChildTasks(ctx context.Context, parentID int64) ([]domain.Task, error)
+	GetSubtree(ctx context.Context, rootID int64) ([]domain.Task, error)
 	AddTask(ctx context.Context, task domain.Task) (int64, error)
 	DeleteTask(ctx context.Context, id int64) error
 	DeleteTaskByTitle(ctx context.Context, title string) error
 	UpdateTask(ctx context.Context, task domain.Task) error
+
+	AddDependency(ctx context.Context, dependency domain.Dependency) error
+	RemoveDependency(ctx context.Context, dependency domain.Dependency) error
+	GetBlockers(ctx context.Context, taskID int64) ([]domain.Task, error)
+	GetAllDependencies(ctx context.Context) ([]domain.Dependency, error)
+
+	AddTags(ctx context.Context, taskID int64, tags []string) error
+	RemoveTag(ctx context.Context, taskID int64, tag string) error
+	GetTagCounts(ctx context.Context) ([]domain.TagCount, error)
+
+	TrashTask(ctx context.Context, id int64, deletedAt time.Time) error
+	RestoreFromTrash(ctx context.Context, id int64) error
+	GetTrash(ctx context.Context) ([]domain.Task, error)
+	GetTrashedTask(ctx context.Context, id int64) (domain.Task, error)
+
+	SearchTasks(ctx context.Context, q SearchQuery) ([]domain.SearchResult, error)
+
+	AddCompletion(ctx context.Context, completion domain.Completion) error
+	GetCompletions(ctx context.Context, taskID int64) ([]domain.Completion, error)
+
+	AddHistory(ctx context.Context, entry domain.HistoryEntry) error
+	GetHistory(ctx context.Context, taskID int64) ([]domain.HistoryEntry, error)
+
+	AddAuditEntry(ctx context.Context, entry domain.AuditEntry) error
+	GetAuditEntry(ctx context.Context, revision int64) (domain.AuditEntry, error)
+	GetAuditLog(ctx context.Context, q AuditQuery) ([]domain.AuditEntry, error)
+
+	AddSentReminder(ctx context.Context, reminder domain.SentReminder) error
+	GetSentReminders(ctx context.Context, taskID int64) ([]domain.SentReminder, error)
+
+	AddWebhook(ctx context.Context, webhook domain.Webhook) (int64, error)
+	GetWebhook(ctx context.Context, id int64) (domain.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	AddWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) (int64, error)
+	GetWebhookDelivery(ctx context.Context, id int64) (domain.WebhookDelivery, error)
+	GetWebhookDeliveries(ctx context.Context, q DeliveryQuery) ([]domain.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error
+
+	AddCalendarFeed(ctx context.Context, feed domain.CalendarFeed) (int64, error)
+	GetCalendarFeeds(ctx context.Context) ([]domain.CalendarFeed, error)
+	GetCalendarFeedByToken(ctx context.Context, tokenHash string) (domain.CalendarFeed, error)
+	DeleteCalendarFeed(ctx context.Context, id int64) error
+	AddImportedTodo(ctx context.Context, todo domain.ImportedTodo) error
+	GetImportedTodo(ctx context.Context, uid string) (domain.ImportedTodo, error)
+
+	WithTransaction(ctx context.Context, fn func(repo ITaskRepo) error) error
 }
 
 // InitializeTaskRepo ensures that a task repository is created only once
@@ -49,6 +101,20 @@ func InitTaskRepo(handler db.Handler) {
 	switch handler.Type() {
 	case db.SQLITE:
 		InitializeSqlite3TaskRepo(handler)
+	case db.POSTGRES:
+		InitializePostgresTaskRepo(handler)
+	default:
+		panic("No handler for this type exists")
+	}
+}
+
+// Migrations returns the schema migrations of the task repository for the type of handler
+func Migrations(handler db.Handler) []db.Migration {
+	switch handler.Type() {
+	case db.SQLITE:
+		return SqliteMigrations
+	case db.POSTGRES:
+		return PostgresMigrations
 	default:
 		panic("No handler for this type exists")
 	}
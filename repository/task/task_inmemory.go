@@ -5,23 +5,87 @@ import (
 	"server/errors"
 
 	"context"
+	"sort"
+	"sync"
+	"time"
 )
 
 // InitializeInMemoryTaskRepo can be used for testing.
 func InitializeInMemoryTaskRepo() {
+	InitializeTaskRepo(newInMemoryTaskRepo())
+}
+
+// NewInMemoryTaskRepo returns an empty in memory task repository, apart from the one of
+// Repository. It can be used for testing.
+func NewInMemoryTaskRepo() ITaskRepo {
+	return newInMemoryTaskRepo()
+}
+
+// newInMemoryTaskRepo returns an empty in memory task repository
+func newInMemoryTaskRepo() *inMemoryTaskRepository {
 	m := make(map[string]domain.Task, 0)
 	im := make(map[int64]domain.Task, 0)
-	repo := inMemoryTaskRepository{m, im}
-	InitializeTaskRepo(&repo)
+	deps := make(map[domain.Dependency]bool, 0)
+	completions := make(map[int64][]domain.Completion, 0)
+	history := make(map[int64][]domain.HistoryEntry, 0)
+	audit := make([]domain.AuditEntry, 0)
+	trash := make(map[int64]domain.Task, 0)
+	sent := make(map[int64][]domain.SentReminder, 0)
+	webhooks := make(map[int64]domain.Webhook, 0)
+	deliveries := make(map[int64]domain.WebhookDelivery, 0)
+	feeds := make(map[int64]domain.CalendarFeed, 0)
+	imports := make(map[string]int64, 0)
+	return &inMemoryTaskRepository{
+		mu:          &sync.RWMutex{},
+		m:           m,
+		im:          im,
+		deps:        deps,
+		completions: completions,
+		history:     history,
+		audit:       audit,
+		trash:       trash,
+		sent:        sent,
+		webhooks:    webhooks,
+		deliveries:  deliveries,
+		feeds:       feeds,
+		imports:     imports,
+	}
 }
 
+// inMemoryTaskRepository is safe for concurrent use. Every method takes the lock, except inside
+// WithTransaction, which holds it for the whole transaction.
 type inMemoryTaskRepository struct {
-	m  map[string]domain.Task
-	im map[int64]domain.Task
+	// mu is nil for the repository passed to the fn of WithTransaction
+	mu          *sync.RWMutex
+	m           map[string]domain.Task
+	im          map[int64]domain.Task
+	deps        map[domain.Dependency]bool
+	completions map[int64][]domain.Completion
+	history     map[int64][]domain.HistoryEntry
+	audit       []domain.AuditEntry
+	trash       map[int64]domain.Task
+	sent        map[int64][]domain.SentReminder
+	webhooks    map[int64]domain.Webhook
+	deliveries  map[int64]domain.WebhookDelivery
+	feeds       map[int64]domain.CalendarFeed
+	// imports has the tasks of the imported to-dos, by uid
+	imports map[string]int64
+	// lastID is the highest rowid given so far. Like AUTOINCREMENT in sqlite, rowids are never reused.
+	lastID int64
+	// lastWebhookID, lastDeliveryID and lastFeedID are the same for webhooks, their deliveries
+	// and calendar feeds
+	lastWebhookID  int64
+	lastDeliveryID int64
+	lastFeedID     int64
+	// writes counts the times the repository was locked for writing, and saved is the count at
+	// the last save of a snapshot
+	writes int64
+	saved  int64
 }
 
 // GetTaskByTitle is default
 func (pr *inMemoryTaskRepository) GetTaskByTitle(ctx context.Context, title string) (domain.Task, error) {
+	defer pr.rlock()()
 	p, ok := pr.m[title]
 	if !ok {
 		return domain.Task{}, errors.ErrorObjectNotFound
@@ -29,51 +93,702 @@ func (pr *inMemoryTaskRepository) GetTaskByTitle(ctx context.Context, title stri
 	return p, nil
 }
 
-// GetAllTasks is default
+// GetTaskByID is default
+func (pr *inMemoryTaskRepository) GetTaskByID(ctx context.Context, id int64) (domain.Task, error) {
+	defer pr.rlock()()
+	p, ok := pr.im[id]
+	if !ok {
+		return domain.Task{}, errors.ErrorObjectNotFound
+	}
+	return p, nil
+}
+
+// GetAllTasks returns every task which is not in the trash, by rowid
 func (pr *inMemoryTaskRepository) GetAllTasks(ctx context.Context) ([]domain.Task, error) {
-	tasksList := make([]domain.Task, len(pr.m))
-	for _, v := range pr.m {
+	defer pr.rlock()()
+	tasksList := make([]domain.Task, 0, len(pr.im))
+	for _, v := range pr.im {
 		tasksList = append(tasksList, v)
 	}
+	sort.Slice(tasksList, func(i, j int) bool { return tasksList[i].Rowid < tasksList[j].Rowid })
 	return tasksList, nil
 }
 
-// AddTask is default
+// GetPaginatedTasks filters, sorts and paginates tasks in memory
+func (pr *inMemoryTaskRepository) GetPaginatedTasks(ctx context.Context, q Query) (Page, error) {
+	defer pr.rlock()()
+	tasksList := make([]domain.Task, 0, len(pr.m))
+	for _, v := range pr.m {
+		tasksList = append(tasksList, v)
+	}
+	return paginate(tasksList, q)
+}
+
+// GetChildTasks is default
+func (pr *inMemoryTaskRepository) GetChildTasks(ctx context.Context, parentID int64) ([]domain.Task, error) {
+	defer pr.rlock()()
+	return pr.childTasks(parentID), nil
+}
+
+// childTasks are the children of a task, by rowid
+func (pr *inMemoryTaskRepository) childTasks(parentID int64) []domain.Task {
+	children := make([]domain.Task, 0)
+	for _, v := range pr.im {
+		if v.ParentID != nil && *v.ParentID == parentID {
+			children = append(children, v)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Rowid < children[j].Rowid })
+	return children
+}
+
+// GetSubtree walks the children of a task breadth first, so the root comes first
+func (pr *inMemoryTaskRepository) GetSubtree(ctx context.Context, rootID int64) ([]domain.Task, error) {
+	defer pr.rlock()()
+	root, ok := pr.im[rootID]
+	if !ok {
+		return make([]domain.Task, 0), errors.ErrorObjectNotFound
+	}
+	subtree := []domain.Task{root}
+	for i := 0; i < len(subtree); i++ {
+		subtree = append(subtree, pr.childTasks(subtree[i].Rowid)...)
+	}
+	return subtree, nil
+}
+
+// AddTask gives the task the next rowid, unless it has one already, and sets its creation time.
+// Titles and rowids of tasks in the trash are still taken.
 func (pr *inMemoryTaskRepository) AddTask(ctx context.Context, task domain.Task) (id int64, err error) {
-	_, ok := pr.m[task.Title]
-	if ok {
+	defer pr.lock()()
+	if pr.titleTaken(task.Title, task.Rowid) {
 		return 0, errors.ErrorObjectAlreadyExists
 	}
+	if task.Rowid == 0 {
+		task.Rowid = pr.lastID + 1
+	} else if _, ok := pr.im[task.Rowid]; ok {
+		return 0, errors.ErrorObjectAlreadyExists
+	} else if _, ok := pr.trash[task.Rowid]; ok {
+		return 0, errors.ErrorObjectAlreadyExists
+	}
+	if task.Rowid > pr.lastID {
+		pr.lastID = task.Rowid
+	}
+	if time.Time(task.Created).IsZero() {
+		// like CURRENT_TIMESTAMP in sqlite
+		task.Created = domain.Time(time.Now().UTC().Truncate(time.Second))
+	}
+	task.Tags = mergeTags(nil, task.Tags)
+	task.Reminders = domain.SortLeadTimes(task.Reminders)
 	pr.m[task.Title] = task
 	pr.im[task.Rowid] = task
 	return task.Rowid, nil
 }
 
-// DeleteTask is default
+// DeleteTask is default. Tasks in the trash can be deleted too.
 func (pr *inMemoryTaskRepository) DeleteTask(ctx context.Context, id int64) error {
-	if _, ok := pr.im[id]; !ok {
-		return errors.ErrorObjectNotFound
+	defer pr.lock()()
+	task, ok := pr.im[id]
+	if !ok {
+		if task, ok = pr.trash[id]; !ok {
+			return errors.ErrorObjectNotFound
+		}
 	}
-	task := pr.im[id]
 	delete(pr.im, id)
+	delete(pr.trash, id)
 	delete(pr.m, task.Title)
+	delete(pr.completions, id)
+	delete(pr.history, id)
+	delete(pr.sent, id)
+	pr.removeImportsOf(id)
+	pr.removeDependenciesOf(id)
+	pr.orphanChildrenOf(id)
 	return nil
 }
 
 // DeleteTaskByTitle is default
 func (pr *inMemoryTaskRepository) DeleteTaskByTitle(ctx context.Context, title string) error {
+	defer pr.lock()()
 	if _, ok := pr.m[title]; !ok {
 		return errors.ErrorObjectNotFound
 	}
 	task := pr.m[title]
 	delete(pr.im, task.Rowid)
 	delete(pr.m, task.Title)
+	delete(pr.completions, task.Rowid)
+	delete(pr.history, task.Rowid)
+	delete(pr.sent, task.Rowid)
+	pr.removeImportsOf(task.Rowid)
+	pr.removeDependenciesOf(task.Rowid)
+	pr.orphanChildrenOf(task.Rowid)
 	return nil
 }
 
-// UpdateTask adds task image
+// UpdateTask replaces a task which is not in the trash. The title of the task can be changed, as
+// long as it stays unique. Tags are not touched, use AddTags and RemoveTag for them.
+// Reminders are replaced.
 func (pr *inMemoryTaskRepository) UpdateTask(ctx context.Context, task domain.Task) (err error) {
+	defer pr.lock()()
+	old, ok := pr.im[task.Rowid]
+	if !ok {
+		return errors.ErrorObjectNotFound
+	}
+	if pr.titleTaken(task.Title, task.Rowid) {
+		return errors.ErrorObjectAlreadyExists
+	}
+	delete(pr.m, old.Title)
+	task.Tags = old.Tags
+	task.Reminders = domain.SortLeadTimes(task.Reminders)
+	pr.m[task.Title] = task
+	pr.im[task.Rowid] = task
+	return nil
+}
+
+// titleTaken checks if a task other than id has title. Tasks in the trash don't count.
+func (pr *inMemoryTaskRepository) titleTaken(title string, id int64) bool {
+	existing, ok := pr.m[title]
+	return ok && existing.Rowid != id
+}
+
+// SearchTasks emulates full text search with a simple tokenisation of titles and descriptions
+func (pr *inMemoryTaskRepository) SearchTasks(ctx context.Context, q SearchQuery) ([]domain.SearchResult, error) {
+	defer pr.rlock()()
+	tasks := make([]domain.Task, 0, len(pr.im))
+	for _, t := range pr.im {
+		tasks = append(tasks, t)
+	}
+	return searchTasks(tasks, q)
+}
+
+// TrashTask is default
+func (pr *inMemoryTaskRepository) TrashTask(ctx context.Context, id int64, deletedAt time.Time) error {
+	defer pr.lock()()
+	task, ok := pr.im[id]
+	if !ok {
+		return errors.ErrorObjectNotFound
+	}
+	delete(pr.im, id)
+	delete(pr.m, task.Title)
+	at := domain.Time(deletedAt)
+	task.DeletedAt = &at
+	pr.trash[id] = task
+	return nil
+}
+
+// RestoreFromTrash is default
+func (pr *inMemoryTaskRepository) RestoreFromTrash(ctx context.Context, id int64) error {
+	defer pr.lock()()
+	task, ok := pr.trash[id]
+	if !ok {
+		return errors.ErrorObjectNotFound
+	}
+	if pr.titleTaken(task.Title, id) {
+		return errors.ErrorObjectAlreadyExists
+	}
+	delete(pr.trash, id)
+	task.DeletedAt = nil
+	pr.m[task.Title] = task
+	pr.im[id] = task
+	return nil
+}
+
+// GetTrash is default
+func (pr *inMemoryTaskRepository) GetTrash(ctx context.Context) ([]domain.Task, error) {
+	defer pr.rlock()()
+	trash := make([]domain.Task, 0, len(pr.trash))
+	for _, t := range pr.trash {
+		trash = append(trash, t)
+	}
+	sort.Slice(trash, func(i, j int) bool { return trash[i].Rowid < trash[j].Rowid })
+	return trash, nil
+}
+
+// GetTrashedTask is default
+func (pr *inMemoryTaskRepository) GetTrashedTask(ctx context.Context, id int64) (domain.Task, error) {
+	defer pr.rlock()()
+	task, ok := pr.trash[id]
+	if !ok {
+		return domain.Task{}, errors.ErrorObjectNotFound
+	}
+	return task, nil
+}
+
+// AddDependency is default
+func (pr *inMemoryTaskRepository) AddDependency(ctx context.Context, dependency domain.Dependency) error {
+	defer pr.lock()()
+	if pr.deps[dependency] {
+		return errors.ErrorObjectAlreadyExists
+	}
+	pr.deps[dependency] = true
+	return nil
+}
+
+// RemoveDependency is default
+func (pr *inMemoryTaskRepository) RemoveDependency(ctx context.Context, dependency domain.Dependency) error {
+	defer pr.lock()()
+	if !pr.deps[dependency] {
+		return errors.ErrorObjectNotFound
+	}
+	delete(pr.deps, dependency)
+	return nil
+}
+
+// GetBlockers is default
+func (pr *inMemoryTaskRepository) GetBlockers(ctx context.Context, taskID int64) ([]domain.Task, error) {
+	defer pr.rlock()()
+	blockers := make([]domain.Task, 0)
+	for d := range pr.deps {
+		if d.TaskID == taskID {
+			blockers = append(blockers, pr.im[d.BlockedBy])
+		}
+	}
+	sort.Slice(blockers, func(i, j int) bool { return blockers[i].Rowid < blockers[j].Rowid })
+	return blockers, nil
+}
+
+// GetAllDependencies is default
+func (pr *inMemoryTaskRepository) GetAllDependencies(ctx context.Context) ([]domain.Dependency, error) {
+	defer pr.rlock()()
+	dependencies := make([]domain.Dependency, 0, len(pr.deps))
+	for d := range pr.deps {
+		dependencies = append(dependencies, d)
+	}
+	sort.Slice(dependencies, func(i, j int) bool {
+		if dependencies[i].TaskID != dependencies[j].TaskID {
+			return dependencies[i].TaskID < dependencies[j].TaskID
+		}
+		return dependencies[i].BlockedBy < dependencies[j].BlockedBy
+	})
+	return dependencies, nil
+}
+
+// removeDependenciesOf drops every dependency a task is part of, on either side
+func (pr *inMemoryTaskRepository) removeDependenciesOf(id int64) {
+	for d := range pr.deps {
+		if d.TaskID == id || d.BlockedBy == id {
+			delete(pr.deps, d)
+		}
+	}
+}
+
+// AddTags is default
+func (pr *inMemoryTaskRepository) AddTags(ctx context.Context, taskID int64, tags []string) error {
+	defer pr.lock()()
+	task, ok := pr.im[taskID]
+	if !ok {
+		return errors.ErrorObjectNotFound
+	}
+	task.Tags = mergeTags(task.Tags, tags)
 	pr.m[task.Title] = task
 	pr.im[task.Rowid] = task
 	return nil
 }
+
+// RemoveTag is default
+func (pr *inMemoryTaskRepository) RemoveTag(ctx context.Context, taskID int64, tag string) error {
+	defer pr.lock()()
+	task, ok := pr.im[taskID]
+	if !ok || !hasTag(task, tag) {
+		return errors.ErrorObjectNotFound
+	}
+	tags := make([]string, 0, len(task.Tags))
+	for _, t := range task.Tags {
+		if t != tag {
+			tags = append(tags, t)
+		}
+	}
+	task.Tags = tags
+	pr.m[task.Title] = task
+	pr.im[task.Rowid] = task
+	return nil
+}
+
+// GetTagCounts is default
+func (pr *inMemoryTaskRepository) GetTagCounts(ctx context.Context) ([]domain.TagCount, error) {
+	defer pr.rlock()()
+	countByTag := make(map[string]int64)
+	for _, task := range pr.m {
+		for _, tag := range task.Tags {
+			countByTag[tag]++
+		}
+	}
+	counts := make([]domain.TagCount, 0, len(countByTag))
+	for tag, count := range countByTag {
+		counts = append(counts, domain.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Tag < counts[j].Tag
+	})
+	return counts, nil
+}
+
+// mergeTags returns the sorted union of two lists of tags
+func mergeTags(tags, more []string) []string {
+	set := make(map[string]bool, len(tags)+len(more))
+	merged := make([]string, 0, len(tags)+len(more))
+	for _, tag := range append(append([]string{}, tags...), more...) {
+		if !set[tag] {
+			set[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// AddCompletion is default
+func (pr *inMemoryTaskRepository) AddCompletion(ctx context.Context, completion domain.Completion) error {
+	defer pr.lock()()
+	completion.Rowid = int64(len(pr.completions[completion.TaskID]) + 1)
+	pr.completions[completion.TaskID] = append(pr.completions[completion.TaskID], completion)
+	return nil
+}
+
+// GetCompletions is default
+func (pr *inMemoryTaskRepository) GetCompletions(ctx context.Context, taskID int64) ([]domain.Completion, error) {
+	defer pr.rlock()()
+	completions := make([]domain.Completion, len(pr.completions[taskID]))
+	copy(completions, pr.completions[taskID])
+	return completions, nil
+}
+
+// AddHistory is default
+func (pr *inMemoryTaskRepository) AddHistory(ctx context.Context, entry domain.HistoryEntry) error {
+	defer pr.lock()()
+	entry.Rowid = int64(len(pr.history[entry.TaskID]) + 1)
+	pr.history[entry.TaskID] = append(pr.history[entry.TaskID], entry)
+	return nil
+}
+
+// GetHistory is default
+func (pr *inMemoryTaskRepository) GetHistory(ctx context.Context, taskID int64) ([]domain.HistoryEntry, error) {
+	defer pr.rlock()()
+	history := make([]domain.HistoryEntry, len(pr.history[taskID]))
+	copy(history, pr.history[taskID])
+	return history, nil
+}
+
+// AddSentReminder is default
+func (pr *inMemoryTaskRepository) AddSentReminder(ctx context.Context, reminder domain.SentReminder) error {
+	defer pr.lock()()
+	for _, s := range pr.sent[reminder.TaskID] {
+		if s.LeadTime == reminder.LeadTime && s.Notifier == reminder.Notifier && time.Time(s.DueDate).Equal(time.Time(reminder.DueDate)) {
+			return errors.ErrorObjectAlreadyExists
+		}
+	}
+	pr.sent[reminder.TaskID] = append(pr.sent[reminder.TaskID], reminder)
+	return nil
+}
+
+// GetSentReminders is default
+func (pr *inMemoryTaskRepository) GetSentReminders(ctx context.Context, taskID int64) ([]domain.SentReminder, error) {
+	defer pr.rlock()()
+	sent := make([]domain.SentReminder, len(pr.sent[taskID]))
+	copy(sent, pr.sent[taskID])
+	return sent, nil
+}
+
+// AddWebhook is default
+func (pr *inMemoryTaskRepository) AddWebhook(ctx context.Context, webhook domain.Webhook) (int64, error) {
+	defer pr.lock()()
+	pr.lastWebhookID++
+	webhook.Rowid = pr.lastWebhookID
+	pr.webhooks[webhook.Rowid] = webhook
+	return webhook.Rowid, nil
+}
+
+// GetWebhook is default
+func (pr *inMemoryTaskRepository) GetWebhook(ctx context.Context, id int64) (domain.Webhook, error) {
+	defer pr.rlock()()
+	webhook, ok := pr.webhooks[id]
+	if !ok {
+		return domain.Webhook{}, errors.ErrorObjectNotFound
+	}
+	return webhook, nil
+}
+
+// GetWebhooks is default
+func (pr *inMemoryTaskRepository) GetWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	defer pr.rlock()()
+	webhooks := make([]domain.Webhook, 0, len(pr.webhooks))
+	for _, w := range pr.webhooks {
+		webhooks = append(webhooks, w)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].Rowid < webhooks[j].Rowid })
+	return webhooks, nil
+}
+
+// DeleteWebhook is default
+func (pr *inMemoryTaskRepository) DeleteWebhook(ctx context.Context, id int64) error {
+	defer pr.lock()()
+	if _, ok := pr.webhooks[id]; !ok {
+		return errors.ErrorObjectNotFound
+	}
+	delete(pr.webhooks, id)
+	for k, d := range pr.deliveries {
+		if d.WebhookID == id {
+			delete(pr.deliveries, k)
+		}
+	}
+	return nil
+}
+
+// AddWebhookDelivery is default
+func (pr *inMemoryTaskRepository) AddWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) (int64, error) {
+	defer pr.lock()()
+	pr.lastDeliveryID++
+	delivery.Rowid = pr.lastDeliveryID
+	pr.deliveries[delivery.Rowid] = delivery
+	return delivery.Rowid, nil
+}
+
+// GetWebhookDelivery is default
+func (pr *inMemoryTaskRepository) GetWebhookDelivery(ctx context.Context, id int64) (domain.WebhookDelivery, error) {
+	defer pr.rlock()()
+	delivery, ok := pr.deliveries[id]
+	if !ok {
+		return domain.WebhookDelivery{}, errors.ErrorObjectNotFound
+	}
+	return delivery, nil
+}
+
+// GetWebhookDeliveries is default
+func (pr *inMemoryTaskRepository) GetWebhookDeliveries(ctx context.Context, q DeliveryQuery) ([]domain.WebhookDelivery, error) {
+	defer pr.rlock()()
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for _, d := range pr.deliveries {
+		if q.matches(d) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if q.Newest {
+			return deliveries[i].Rowid > deliveries[j].Rowid
+		}
+		return deliveries[i].Rowid < deliveries[j].Rowid
+	})
+	if q.Limit > 0 && len(deliveries) > q.Limit {
+		deliveries = deliveries[:q.Limit]
+	}
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery is default
+func (pr *inMemoryTaskRepository) UpdateWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	defer pr.lock()()
+	if _, ok := pr.deliveries[delivery.Rowid]; !ok {
+		return errors.ErrorObjectNotFound
+	}
+	pr.deliveries[delivery.Rowid] = delivery
+	return nil
+}
+
+// AddCalendarFeed is default
+func (pr *inMemoryTaskRepository) AddCalendarFeed(ctx context.Context, feed domain.CalendarFeed) (int64, error) {
+	defer pr.lock()()
+	for _, f := range pr.feeds {
+		if f.TokenHash == feed.TokenHash {
+			return 0, errors.ErrorObjectAlreadyExists
+		}
+	}
+	pr.lastFeedID++
+	feed.Rowid = pr.lastFeedID
+	pr.feeds[feed.Rowid] = feed
+	return feed.Rowid, nil
+}
+
+// GetCalendarFeeds is default
+func (pr *inMemoryTaskRepository) GetCalendarFeeds(ctx context.Context) ([]domain.CalendarFeed, error) {
+	defer pr.rlock()()
+	feeds := make([]domain.CalendarFeed, 0, len(pr.feeds))
+	for _, f := range pr.feeds {
+		feeds = append(feeds, f)
+	}
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].Rowid < feeds[j].Rowid })
+	return feeds, nil
+}
+
+// GetCalendarFeedByToken is default
+func (pr *inMemoryTaskRepository) GetCalendarFeedByToken(ctx context.Context, tokenHash string) (domain.CalendarFeed, error) {
+	defer pr.rlock()()
+	for _, f := range pr.feeds {
+		if f.TokenHash == tokenHash {
+			return f, nil
+		}
+	}
+	return domain.CalendarFeed{}, errors.ErrorObjectNotFound
+}
+
+// DeleteCalendarFeed is default
+func (pr *inMemoryTaskRepository) DeleteCalendarFeed(ctx context.Context, id int64) error {
+	defer pr.lock()()
+	if _, ok := pr.feeds[id]; !ok {
+		return errors.ErrorObjectNotFound
+	}
+	delete(pr.feeds, id)
+	return nil
+}
+
+// AddImportedTodo is default
+func (pr *inMemoryTaskRepository) AddImportedTodo(ctx context.Context, todo domain.ImportedTodo) error {
+	defer pr.lock()()
+	if _, ok := pr.imports[todo.UID]; ok {
+		return errors.ErrorObjectAlreadyExists
+	}
+	pr.imports[todo.UID] = todo.TaskID
+	return nil
+}
+
+// GetImportedTodo is default
+func (pr *inMemoryTaskRepository) GetImportedTodo(ctx context.Context, uid string) (domain.ImportedTodo, error) {
+	defer pr.rlock()()
+	id, ok := pr.imports[uid]
+	if !ok {
+		return domain.ImportedTodo{}, errors.ErrorObjectNotFound
+	}
+	return domain.ImportedTodo{UID: uid, TaskID: id}, nil
+}
+
+// orphanChildrenOf leaves the subtasks of a deleted task, in the trash or not, without a parent.
+// The lock is held.
+func (pr *inMemoryTaskRepository) orphanChildrenOf(id int64) {
+	for _, tasks := range []map[int64]domain.Task{pr.im, pr.trash} {
+		for rowid, t := range tasks {
+			if t.ParentID != nil && *t.ParentID == id {
+				t.ParentID = nil
+				tasks[rowid] = t
+				if t.DeletedAt == nil {
+					pr.m[t.Title] = t
+				}
+			}
+		}
+	}
+}
+
+// removeImportsOf forgets the uids a task was imported with. The lock is held.
+func (pr *inMemoryTaskRepository) removeImportsOf(id int64) {
+	for uid, taskID := range pr.imports {
+		if taskID == id {
+			delete(pr.imports, uid)
+		}
+	}
+}
+
+// AddAuditEntry is default
+func (pr *inMemoryTaskRepository) AddAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	defer pr.lock()()
+	entry.Rowid = int64(len(pr.audit) + 1)
+	pr.audit = append(pr.audit, entry)
+	return nil
+}
+
+// GetAuditEntry is default
+func (pr *inMemoryTaskRepository) GetAuditEntry(ctx context.Context, revision int64) (domain.AuditEntry, error) {
+	defer pr.rlock()()
+	if revision < 1 || revision > int64(len(pr.audit)) {
+		return domain.AuditEntry{}, errors.ErrorObjectNotFound
+	}
+	return pr.audit[revision-1], nil
+}
+
+// GetAuditLog is default
+func (pr *inMemoryTaskRepository) GetAuditLog(ctx context.Context, q AuditQuery) ([]domain.AuditEntry, error) {
+	defer pr.rlock()()
+	entries := make([]domain.AuditEntry, 0)
+	for _, e := range pr.audit {
+		if q.Limit > 0 && len(entries) == q.Limit {
+			break
+		}
+		if q.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// WithTransaction calls fn with an unlocked view of the repository, holding the lock until fn
+// returns. If fn returns an error, the repository is put back to the state it had before.
+func (pr *inMemoryTaskRepository) WithTransaction(ctx context.Context, fn func(repo ITaskRepo) error) error {
+	defer pr.lock()()
+	saved := pr.clone()
+	tx := *pr
+	tx.mu = nil
+	if err := fn(&tx); err != nil {
+		pr.setState(saved)
+		return err
+	}
+	pr.setState(&tx)
+	return nil
+}
+
+// setState makes the repository hold the tasks and entries of another one. The lock is kept.
+func (pr *inMemoryTaskRepository) setState(from *inMemoryTaskRepository) {
+	pr.m, pr.im, pr.deps, pr.trash = from.m, from.im, from.deps, from.trash
+	pr.completions, pr.history, pr.audit, pr.sent = from.completions, from.history, from.audit, from.sent
+	pr.webhooks, pr.deliveries, pr.feeds, pr.imports = from.webhooks, from.deliveries, from.feeds, from.imports
+	pr.lastID, pr.lastWebhookID, pr.lastDeliveryID, pr.lastFeedID = from.lastID, from.lastWebhookID, from.lastDeliveryID, from.lastFeedID
+}
+
+// lock locks the repository for writing, and returns the function unlocking it
+func (pr *inMemoryTaskRepository) lock() func() {
+	if pr.mu == nil {
+		return func() {}
+	}
+	pr.mu.Lock()
+	pr.writes++
+	return pr.mu.Unlock
+}
+
+// rlock locks the repository for reading, and returns the function unlocking it
+func (pr *inMemoryTaskRepository) rlock() func() {
+	if pr.mu == nil {
+		return func() {}
+	}
+	pr.mu.RLock()
+	return pr.mu.RUnlock
+}
+
+// clone copies the maps of the repository. Tasks and entries are never modified in place, so
+// they can be shared.
+func (pr *inMemoryTaskRepository) clone() *inMemoryTaskRepository {
+	c := newInMemoryTaskRepo()
+	for k, v := range pr.m {
+		c.m[k] = v
+	}
+	for k, v := range pr.im {
+		c.im[k] = v
+	}
+	for k, v := range pr.deps {
+		c.deps[k] = v
+	}
+	for k, v := range pr.completions {
+		c.completions[k] = v
+	}
+	for k, v := range pr.history {
+		c.history[k] = v
+	}
+	for k, v := range pr.sent {
+		c.sent[k] = v
+	}
+	for k, v := range pr.webhooks {
+		c.webhooks[k] = v
+	}
+	for k, v := range pr.deliveries {
+		c.deliveries[k] = v
+	}
+	for k, v := range pr.feeds {
+		c.feeds[k] = v
+	}
+	for k, v := range pr.imports {
+		c.imports[k] = v
+	}
+	c.lastWebhookID, c.lastDeliveryID, c.lastFeedID = pr.lastWebhookID, pr.lastDeliveryID, pr.lastFeedID
+	c.audit = append(c.audit, pr.audit...)
+	c.lastID = pr.lastID
+	for k, v := range pr.trash {
+		c.trash[k] = v
+	}
+	return c
+}
@@ -0,0 +1,258 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"server/domain"
+)
+
+// memorySnapshot is the json form of an in memory repository. Tasks in the trash are saved with
+// the rest, their DeletedAt tells them apart.
+type memorySnapshot struct {
+	LastID       int64                    `json:"lastId"`
+	Tasks        []domain.Task            `json:"tasks"`
+	Dependencies []domain.Dependency      `json:"dependencies"`
+	Completions  []domain.Completion      `json:"completions"`
+	History      []domain.HistoryEntry    `json:"history"`
+	Audit        []domain.AuditEntry      `json:"audit"`
+	Reminders    []domain.SentReminder    `json:"reminders"`
+	Webhooks     []webhookSnapshot        `json:"webhooks"`
+	Deliveries   []domain.WebhookDelivery `json:"deliveries"`
+	Feeds        []feedSnapshot           `json:"calendarFeeds"`
+	Imports      []domain.ImportedTodo    `json:"calendarImports"`
+}
+
+// webhookSnapshot keeps the secret of a webhook, which its json form leaves out
+type webhookSnapshot struct {
+	domain.Webhook
+	Secret string `json:"secret"`
+}
+
+// feedSnapshot keeps the token hash of a calendar feed, which its json form leaves out
+type feedSnapshot struct {
+	domain.CalendarFeed
+	TokenHash string `json:"tokenHash"`
+}
+
+// InitializeMemoryTaskRepo makes an in memory repository the task repository. If snapshotFile
+// exists, the repository starts with the tasks saved in it. An empty snapshotFile is never read.
+func InitializeMemoryTaskRepo(snapshotFile string) error {
+	repo := newInMemoryTaskRepo()
+	if snapshotFile != "" {
+		if err := repo.load(snapshotFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return InitializeTaskRepo(repo)
+}
+
+// SaveMemorySnapshot saves the in memory task repository to snapshotFile, for
+// InitializeMemoryTaskRepo to load on the next start.
+func SaveMemorySnapshot(snapshotFile string) error {
+	repo, ok := Repository().(*inMemoryTaskRepository)
+	if !ok {
+		return errors.New("Task repository is not in memory")
+	}
+	return repo.save(snapshotFile)
+}
+
+// SaveMemorySnapshotEvery saves the in memory task repository to snapshotFile once every
+// interval, if it changed, until ctx is done. It is meant to be run in its own goroutine, along
+// with a last SaveMemorySnapshot once it has returned.
+func SaveMemorySnapshotEvery(ctx context.Context, snapshotFile string, interval time.Duration) {
+	repo, ok := Repository().(*inMemoryTaskRepository)
+	if !ok {
+		log.Printf("Task repository is not in memory, it is not saved to %s", snapshotFile)
+		return
+	}
+	repo.saveEvery(ctx, snapshotFile, interval)
+}
+
+// saveEvery saves the repository to file once every interval, if it changed, until ctx is done
+func (pr *inMemoryTaskRepository) saveEvery(ctx context.Context, file string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := pr.saveIfChanged(file); err != nil {
+			log.Printf("Could not save tasks to %s: %s", file, err.Error())
+		}
+	}
+}
+
+// save writes the repository to file. It is written to a temporary file first and renamed, so
+// that a crash never leaves half a snapshot behind.
+func (pr *inMemoryTaskRepository) save(file string) error {
+	s, writes, _ := pr.snapshot()
+	return pr.write(file, s, writes)
+}
+
+// saveIfChanged saves the repository to file, unless it was not written since the last save
+func (pr *inMemoryTaskRepository) saveIfChanged(file string) error {
+	s, writes, saved := pr.snapshot()
+	if writes == saved {
+		return nil
+	}
+	return pr.write(file, s, writes)
+}
+
+// write writes a snapshot, taken after writes writes, to file
+func (pr *inMemoryTaskRepository) write(file string, s memorySnapshot, writes int64) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file+".tmp", b, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		return err
+	}
+	pr.markSaved(writes)
+	return nil
+}
+
+// markSaved records that the repository was saved after writes writes. The lock is taken
+// without counting as a write.
+func (pr *inMemoryTaskRepository) markSaved(writes int64) {
+	if pr.mu != nil {
+		pr.mu.Lock()
+		defer pr.mu.Unlock()
+	}
+	if writes > pr.saved {
+		pr.saved = writes
+	}
+}
+
+// snapshot copies the repository, with everything sorted by rowid, along with the count of its
+// writes and the count at its last save
+func (pr *inMemoryTaskRepository) snapshot() (memorySnapshot, int64, int64) {
+	defer pr.rlock()()
+	s := memorySnapshot{
+		LastID:       pr.lastID,
+		Tasks:        make([]domain.Task, 0, len(pr.im)+len(pr.trash)),
+		Dependencies: make([]domain.Dependency, 0, len(pr.deps)),
+		Completions:  make([]domain.Completion, 0),
+		History:      make([]domain.HistoryEntry, 0),
+		Audit:        append(make([]domain.AuditEntry, 0, len(pr.audit)), pr.audit...),
+		Reminders:    make([]domain.SentReminder, 0),
+		Webhooks:     make([]webhookSnapshot, 0, len(pr.webhooks)),
+		Deliveries:   make([]domain.WebhookDelivery, 0, len(pr.deliveries)),
+		Feeds:        make([]feedSnapshot, 0, len(pr.feeds)),
+		Imports:      make([]domain.ImportedTodo, 0, len(pr.imports)),
+	}
+	for _, t := range pr.im {
+		s.Tasks = append(s.Tasks, t)
+	}
+	for _, t := range pr.trash {
+		s.Tasks = append(s.Tasks, t)
+	}
+	sort.Slice(s.Tasks, func(i, j int) bool { return s.Tasks[i].Rowid < s.Tasks[j].Rowid })
+	for d := range pr.deps {
+		s.Dependencies = append(s.Dependencies, d)
+	}
+	sort.Slice(s.Dependencies, func(i, j int) bool {
+		if s.Dependencies[i].TaskID != s.Dependencies[j].TaskID {
+			return s.Dependencies[i].TaskID < s.Dependencies[j].TaskID
+		}
+		return s.Dependencies[i].BlockedBy < s.Dependencies[j].BlockedBy
+	})
+	for _, t := range s.Tasks {
+		s.Completions = append(s.Completions, pr.completions[t.Rowid]...)
+		s.History = append(s.History, pr.history[t.Rowid]...)
+		s.Reminders = append(s.Reminders, pr.sent[t.Rowid]...)
+	}
+	for _, w := range pr.webhooks {
+		s.Webhooks = append(s.Webhooks, webhookSnapshot{w, w.Secret})
+	}
+	sort.Slice(s.Webhooks, func(i, j int) bool { return s.Webhooks[i].Rowid < s.Webhooks[j].Rowid })
+	for _, d := range pr.deliveries {
+		s.Deliveries = append(s.Deliveries, d)
+	}
+	sort.Slice(s.Deliveries, func(i, j int) bool { return s.Deliveries[i].Rowid < s.Deliveries[j].Rowid })
+	for _, f := range pr.feeds {
+		s.Feeds = append(s.Feeds, feedSnapshot{f, f.TokenHash})
+	}
+	sort.Slice(s.Feeds, func(i, j int) bool { return s.Feeds[i].Rowid < s.Feeds[j].Rowid })
+	for uid, id := range pr.imports {
+		s.Imports = append(s.Imports, domain.ImportedTodo{UID: uid, TaskID: id})
+	}
+	sort.Slice(s.Imports, func(i, j int) bool { return s.Imports[i].UID < s.Imports[j].UID })
+	return s, pr.writes, pr.saved
+}
+
+// load reads the repository from a file written by save
+func (pr *inMemoryTaskRepository) load(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var s memorySnapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	defer pr.lock()()
+	pr.lastID = s.LastID
+	for _, t := range s.Tasks {
+		if t.DeletedAt != nil {
+			pr.trash[t.Rowid] = t
+		} else {
+			pr.m[t.Title] = t
+			pr.im[t.Rowid] = t
+		}
+		if t.Rowid > pr.lastID {
+			pr.lastID = t.Rowid
+		}
+	}
+	for _, d := range s.Dependencies {
+		pr.deps[d] = true
+	}
+	for _, c := range s.Completions {
+		pr.completions[c.TaskID] = append(pr.completions[c.TaskID], c)
+	}
+	for _, h := range s.History {
+		pr.history[h.TaskID] = append(pr.history[h.TaskID], h)
+	}
+	for _, r := range s.Reminders {
+		pr.sent[r.TaskID] = append(pr.sent[r.TaskID], r)
+	}
+	for _, w := range s.Webhooks {
+		w.Webhook.Secret = w.Secret
+		pr.webhooks[w.Rowid] = w.Webhook
+		if w.Rowid > pr.lastWebhookID {
+			pr.lastWebhookID = w.Rowid
+		}
+	}
+	for _, d := range s.Deliveries {
+		pr.deliveries[d.Rowid] = d
+		if d.Rowid > pr.lastDeliveryID {
+			pr.lastDeliveryID = d.Rowid
+		}
+	}
+	for _, f := range s.Feeds {
+		f.CalendarFeed.TokenHash = f.TokenHash
+		pr.feeds[f.Rowid] = f.CalendarFeed
+		if f.Rowid > pr.lastFeedID {
+			pr.lastFeedID = f.Rowid
+		}
+	}
+	for _, i := range s.Imports {
+		pr.imports[i.UID] = i.TaskID
+	}
+	pr.audit = append(pr.audit, s.Audit...)
+	// what was just loaded is saved already
+	pr.saved = pr.writes
+	return nil
+}
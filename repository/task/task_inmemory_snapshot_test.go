@@ -0,0 +1,146 @@
+package task
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"server/domain"
+)
+
+func TestMemorySnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "tasks.json")
+
+	ctx := context.Background()
+	repo := newInMemoryTaskRepo()
+	seedTasks(t, repo)
+	changed := domain.Time(time.Date(2030, 1, 2, 10, 0, 0, 0, time.UTC))
+	task, _ := repo.GetTaskByID(ctx, 1)
+	webhookID, err := repo.AddWebhook(ctx, domain.Webhook{URL: "http://hook", Secret: "secret", Created: changed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.AddWebhookDelivery(ctx, domain.WebhookDelivery{WebhookID: webhookID, Event: domain.EventTaskCreated, Payload: "{}", Status: domain.DeliveryFailed, NextAttempt: changed, Created: changed}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.AddCalendarFeed(ctx, domain.CalendarFeed{Name: "phone", TokenHash: domain.HashToken("token"), Created: changed}); err != nil {
+		t.Fatal(err)
+	}
+	steps := []error{
+		repo.AddTags(ctx, 1, []string{"work"}),
+		repo.AddDependency(ctx, domain.Dependency{TaskID: 1, BlockedBy: 2}),
+		repo.AddCompletion(ctx, domain.Completion{TaskID: 3, DueDate: changed, Completed: changed}),
+		repo.AddHistory(ctx, domain.HistoryEntry{TaskID: 2, OldStatus: domain.Pending, NewStatus: domain.InProgress, Changed: changed}),
+		repo.AddAuditEntry(ctx, domain.AuditEntry{TaskID: 1, Action: domain.AuditCreate, Changes: domain.Diff(nil, &task), Snapshot: domain.Snapshot(task), Changed: changed}),
+		repo.TrashTask(ctx, 5, time.Time(changed)),
+		repo.DeleteTask(ctx, 4),
+		repo.save(file),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded := newInMemoryTaskRepo()
+	if err := loaded.load(file); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := loaded.GetAllTasks(ctx); !equal(titles(all), []string{"write report", "review report", "buy milk"}) {
+		t.Errorf("got tasks %v", titles(all))
+	}
+	if task, _ := loaded.GetTaskByID(ctx, 1); !equal(task.Tags, []string{"work"}) || task.DueDate.String() != "2030-01-04T10:00:00Z" {
+		t.Errorf("got task %+v", task)
+	}
+	if trash, _ := loaded.GetTrash(ctx); len(trash) != 1 || trash[0].Rowid != 5 {
+		t.Errorf("got trash %v", titles(trash))
+	}
+	if blockers, _ := loaded.GetBlockers(ctx, 1); len(blockers) != 1 || blockers[0].Rowid != 2 {
+		t.Errorf("got blockers %v", titles(blockers))
+	}
+	if completions, _ := loaded.GetCompletions(ctx, 3); len(completions) != 1 {
+		t.Errorf("got completions %v", completions)
+	}
+	if history, _ := loaded.GetHistory(ctx, 2); len(history) != 1 || history[0].NewStatus != domain.InProgress {
+		t.Errorf("got history %v", history)
+	}
+	if entry, err := loaded.GetAuditEntry(ctx, 1); err != nil || entry.Snapshot.Title != "write report" {
+		t.Errorf("got audit entry %v, %v", entry, err)
+	}
+	if webhook, err := loaded.GetWebhook(ctx, webhookID); err != nil || webhook.Secret != "secret" {
+		t.Errorf("got webhook %+v, %v, want its secret kept", webhook, err)
+	}
+	if deliveries, _ := loaded.GetWebhookDeliveries(ctx, DeliveryQuery{Status: domain.DeliveryFailed}); len(deliveries) != 1 {
+		t.Errorf("got deliveries %v", deliveries)
+	}
+	if feed, err := loaded.GetCalendarFeedByToken(ctx, domain.HashToken("token")); err != nil || feed.Name != "phone" {
+		t.Errorf("got calendar feed %+v, %v, want its token kept", feed, err)
+	}
+	// rowid 5 is in the trash and 4 was deleted, neither is given out again
+	if id, err := loaded.AddTask(ctx, domain.Task{Title: "new"}); err != nil || id != 6 {
+		t.Errorf("got id %d, %v, want 6", id, err)
+	}
+
+	if err := (newInMemoryTaskRepo()).load(filepath.Join(dir, "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("got %v for a missing snapshot", err)
+	}
+}
+
+func TestSaveEvery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "tasks.json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := newInMemoryTaskRepo()
+	done := make(chan struct{})
+	go func() {
+		repo.saveEvery(ctx, file, 10*time.Millisecond)
+		close(done)
+	}()
+
+	seedTasks(t, repo)
+	deadline := time.Now().Add(time.Second)
+	for {
+		loaded := newInMemoryTaskRepo()
+		if err := loaded.load(file); err == nil {
+			if all, _ := loaded.GetAllTasks(context.Background()); len(all) == 5 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the tasks were not saved")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(file + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("got %v for the temporary file, want it renamed", err)
+	}
+
+	// nothing changed, so the file is not written again
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("got %v, want no save without a change", err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("saveEvery did not return once ctx was done")
+	}
+}
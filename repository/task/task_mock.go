@@ -2,8 +2,11 @@ package task
 
 import (
 	"server/domain"
+	"server/errors"
 
 	"context"
+	"sort"
+	"time"
 )
 
 // ContextKey ...
@@ -24,6 +27,15 @@ func InitializeMockTaskRepo() {
 type mockTaskRepository struct {
 }
 
+// tasksByID is the "tasksByID" entry of a debugMap. When it is set, GetTaskByID, GetChildTasks
+// and UpdateTask read and write the tasks in it, so that trees of tasks can be tested. DeleteTask
+// then records the ids it deletes in the "deleted" entry, unless the "deleteErrors" entry has an
+// error for them, and RestoreFromTrash moves a task of the "trash" entry back into it.
+func tasksByID(debugMap map[string]interface{}) (map[int64]domain.Task, bool) {
+	tasks, ok := debugMap["tasksByID"].(map[int64]domain.Task)
+	return tasks, ok
+}
+
 // GetTaskByTitle is default
 func (pr *mockTaskRepository) GetTaskByTitle(ctx context.Context, title string) (domain.Task, error) {
 	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
@@ -35,6 +47,24 @@ func (pr *mockTaskRepository) GetTaskByTitle(ctx context.Context, title string)
 	return domain.Task{}, nil
 }
 
+// GetTaskByID is default
+func (pr *mockTaskRepository) GetTaskByID(ctx context.Context, id int64) (domain.Task, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		if tasks, ok := tasksByID(debugMap); ok {
+			task, found := tasks[id]
+			if !found {
+				return domain.Task{}, errors.ErrorObjectNotFound
+			}
+			return task, nil
+		}
+		task, _ := debugMap["task"].(domain.Task)
+		err, _ := debugMap["error"].(error)
+		return task, err
+	}
+
+	return domain.Task{}, nil
+}
+
 // GetAllTasks is default
 func (pr *mockTaskRepository) GetAllTasks(ctx context.Context) ([]domain.Task, error) {
 	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
@@ -46,6 +76,49 @@ func (pr *mockTaskRepository) GetAllTasks(ctx context.Context) ([]domain.Task, e
 	return make([]domain.Task, 0), nil
 }
 
+// GetPaginatedTasks is default
+func (pr *mockTaskRepository) GetPaginatedTasks(ctx context.Context, q Query) (Page, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		page, _ := debugMap["page"].(Page)
+		err, _ := debugMap["error"].(error)
+		return page, err
+	}
+
+	return Page{Tasks: make([]domain.Task, 0)}, nil
+}
+
+// GetChildTasks is default
+func (pr *mockTaskRepository) GetChildTasks(ctx context.Context, parentID int64) ([]domain.Task, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		if tasks, ok := tasksByID(debugMap); ok {
+			children := make([]domain.Task, 0)
+			for _, t := range tasks {
+				if t.ParentID != nil && *t.ParentID == parentID {
+					children = append(children, t)
+				}
+			}
+			sort.Slice(children, func(i, j int) bool { return children[i].Rowid < children[j].Rowid })
+			return children, nil
+		}
+		tasks, _ := debugMap["tasks"].([]domain.Task)
+		err, _ := debugMap["error"].(error)
+		return tasks, err
+	}
+
+	return make([]domain.Task, 0), nil
+}
+
+// GetSubtree is default
+func (pr *mockTaskRepository) GetSubtree(ctx context.Context, rootID int64) ([]domain.Task, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		tasks, _ := debugMap["tasks"].([]domain.Task)
+		err, _ := debugMap["error"].(error)
+		return tasks, err
+	}
+
+	return make([]domain.Task, 0), nil
+}
+
 // AddTask is default
 func (pr *mockTaskRepository) AddTask(ctx context.Context, task domain.Task) (id int64, err error) {
 	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
@@ -60,6 +133,16 @@ func (pr *mockTaskRepository) AddTask(ctx context.Context, task domain.Task) (id
 // DeleteTask is default
 func (pr *mockTaskRepository) DeleteTask(ctx context.Context, id int64) error {
 	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		if tasks, ok := tasksByID(debugMap); ok {
+			deleteErrors, _ := debugMap["deleteErrors"].(map[int64]error)
+			if err, ok := deleteErrors[id]; ok {
+				return err
+			}
+			delete(tasks, id)
+			deleted, _ := debugMap["deleted"].([]int64)
+			debugMap["deleted"] = append(deleted, id)
+			return nil
+		}
 		err, _ := debugMap["error"].(error)
 		return err
 	}
@@ -78,10 +161,409 @@ func (pr *mockTaskRepository) DeleteTaskByTitle(ctx context.Context, title strin
 
 // UpdateTask adds task image
 func (pr *mockTaskRepository) UpdateTask(ctx context.Context, task domain.Task) (err error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		if tasks, ok := tasksByID(debugMap); ok && err == nil {
+			tasks[task.Rowid] = task
+		}
+		return err
+	}
+	return nil
+
+}
+
+// AddDependency is default
+func (pr *mockTaskRepository) AddDependency(ctx context.Context, dependency domain.Dependency) error {
 	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
 		err, _ := debugMap["error"].(error)
 		return err
 	}
 	return nil
+}
+
+// RemoveDependency is default
+func (pr *mockTaskRepository) RemoveDependency(ctx context.Context, dependency domain.Dependency) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// GetBlockers is default
+func (pr *mockTaskRepository) GetBlockers(ctx context.Context, taskID int64) ([]domain.Task, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		tasks, _ := debugMap["tasks"].([]domain.Task)
+		err, _ := debugMap["error"].(error)
+		return tasks, err
+	}
+
+	return make([]domain.Task, 0), nil
+}
+
+// GetAllDependencies is default
+func (pr *mockTaskRepository) GetAllDependencies(ctx context.Context) ([]domain.Dependency, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		dependencies, _ := debugMap["dependencies"].([]domain.Dependency)
+		err, _ := debugMap["error"].(error)
+		return dependencies, err
+	}
+
+	return make([]domain.Dependency, 0), nil
+}
+
+// AddTags is default
+func (pr *mockTaskRepository) AddTags(ctx context.Context, taskID int64, tags []string) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// RemoveTag is default
+func (pr *mockTaskRepository) RemoveTag(ctx context.Context, taskID int64, tag string) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// GetTagCounts is default
+func (pr *mockTaskRepository) GetTagCounts(ctx context.Context) ([]domain.TagCount, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		counts, _ := debugMap["tagCounts"].([]domain.TagCount)
+		err, _ := debugMap["error"].(error)
+		return counts, err
+	}
+
+	return make([]domain.TagCount, 0), nil
+}
+
+// AddCompletion is default
+func (pr *mockTaskRepository) AddCompletion(ctx context.Context, completion domain.Completion) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// GetCompletions is default
+func (pr *mockTaskRepository) GetCompletions(ctx context.Context, taskID int64) ([]domain.Completion, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		completions, _ := debugMap["completions"].([]domain.Completion)
+		err, _ := debugMap["error"].(error)
+		return completions, err
+	}
+
+	return make([]domain.Completion, 0), nil
+}
+
+// AddHistory is default
+func (pr *mockTaskRepository) AddHistory(ctx context.Context, entry domain.HistoryEntry) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// GetHistory is default
+func (pr *mockTaskRepository) GetHistory(ctx context.Context, taskID int64) ([]domain.HistoryEntry, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		history, _ := debugMap["history"].([]domain.HistoryEntry)
+		err, _ := debugMap["error"].(error)
+		return history, err
+	}
+
+	return make([]domain.HistoryEntry, 0), nil
+}
+
+// AddSentReminder is default
+func (pr *mockTaskRepository) AddSentReminder(ctx context.Context, reminder domain.SentReminder) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// GetSentReminders is default
+func (pr *mockTaskRepository) GetSentReminders(ctx context.Context, taskID int64) ([]domain.SentReminder, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		sent, _ := debugMap["sentReminders"].([]domain.SentReminder)
+		err, _ := debugMap["error"].(error)
+		return sent, err
+	}
+
+	return make([]domain.SentReminder, 0), nil
+}
+
+// AddWebhook is default
+func (pr *mockTaskRepository) AddWebhook(ctx context.Context, webhook domain.Webhook) (int64, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		id, _ := debugMap["id"].(int64)
+		err, _ := debugMap["error"].(error)
+		return id, err
+	}
+
+	return 0, nil
+}
+
+// GetWebhook is default
+func (pr *mockTaskRepository) GetWebhook(ctx context.Context, id int64) (domain.Webhook, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		webhook, _ := debugMap["webhook"].(domain.Webhook)
+		err, _ := debugMap["error"].(error)
+		return webhook, err
+	}
+
+	return domain.Webhook{}, nil
+}
+
+// GetWebhooks is default
+func (pr *mockTaskRepository) GetWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		webhooks, _ := debugMap["webhooks"].([]domain.Webhook)
+		err, _ := debugMap["error"].(error)
+		return webhooks, err
+	}
+
+	return make([]domain.Webhook, 0), nil
+}
+
+// DeleteWebhook is default
+func (pr *mockTaskRepository) DeleteWebhook(ctx context.Context, id int64) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// AddWebhookDelivery appends delivery to the "deliveries" entry, with the next id
+func (pr *mockTaskRepository) AddWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) (int64, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		if err != nil {
+			return 0, err
+		}
+		deliveries, _ := debugMap["deliveries"].([]domain.WebhookDelivery)
+		delivery.Rowid = int64(len(deliveries) + 1)
+		debugMap["deliveries"] = append(deliveries, delivery)
+		return delivery.Rowid, nil
+	}
+
+	return 0, nil
+}
+
+// GetWebhookDelivery is default
+func (pr *mockTaskRepository) GetWebhookDelivery(ctx context.Context, id int64) (domain.WebhookDelivery, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		delivery, _ := debugMap["delivery"].(domain.WebhookDelivery)
+		err, _ := debugMap["error"].(error)
+		return delivery, err
+	}
+
+	return domain.WebhookDelivery{}, nil
+}
+
+// GetWebhookDeliveries is default
+func (pr *mockTaskRepository) GetWebhookDeliveries(ctx context.Context, q DeliveryQuery) ([]domain.WebhookDelivery, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		deliveries, _ := debugMap["deliveries"].([]domain.WebhookDelivery)
+		err, _ := debugMap["error"].(error)
+		return deliveries, err
+	}
+
+	return make([]domain.WebhookDelivery, 0), nil
+}
+
+// UpdateWebhookDelivery replaces the delivery with the same id in the "deliveries" entry, and
+// sets the "delivery" entry to it
+func (pr *mockTaskRepository) UpdateWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		if err != nil {
+			return err
+		}
+		deliveries, _ := debugMap["deliveries"].([]domain.WebhookDelivery)
+		for i, d := range deliveries {
+			if d.Rowid == delivery.Rowid {
+				deliveries[i] = delivery
+			}
+		}
+		debugMap["delivery"] = delivery
+		return nil
+	}
+	return nil
+}
+
+// AddImportedTodo is default
+func (pr *mockTaskRepository) AddImportedTodo(ctx context.Context, todo domain.ImportedTodo) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// GetImportedTodo is default
+func (pr *mockTaskRepository) GetImportedTodo(ctx context.Context, uid string) (domain.ImportedTodo, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		todo, ok := debugMap["importedTodo"].(domain.ImportedTodo)
+		err, _ := debugMap["error"].(error)
+		if !ok && err == nil {
+			err = errors.ErrorObjectNotFound
+		}
+		return todo, err
+	}
+	return domain.ImportedTodo{}, errors.ErrorObjectNotFound
+}
+
+// AddCalendarFeed is default
+func (pr *mockTaskRepository) AddCalendarFeed(ctx context.Context, feed domain.CalendarFeed) (int64, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		id, _ := debugMap["id"].(int64)
+		err, _ := debugMap["error"].(error)
+		return id, err
+	}
+
+	return 0, nil
+}
+
+// GetCalendarFeeds is default
+func (pr *mockTaskRepository) GetCalendarFeeds(ctx context.Context) ([]domain.CalendarFeed, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		feeds, _ := debugMap["feeds"].([]domain.CalendarFeed)
+		err, _ := debugMap["error"].(error)
+		return feeds, err
+	}
+
+	return make([]domain.CalendarFeed, 0), nil
+}
+
+// GetCalendarFeedByToken is default
+func (pr *mockTaskRepository) GetCalendarFeedByToken(ctx context.Context, tokenHash string) (domain.CalendarFeed, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		feed, _ := debugMap["feed"].(domain.CalendarFeed)
+		err, _ := debugMap["error"].(error)
+		return feed, err
+	}
+
+	return domain.CalendarFeed{}, nil
+}
+
+// DeleteCalendarFeed is default
+func (pr *mockTaskRepository) DeleteCalendarFeed(ctx context.Context, id int64) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// AddAuditEntry is default
+func (pr *mockTaskRepository) AddAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// GetAuditEntry is default
+func (pr *mockTaskRepository) GetAuditEntry(ctx context.Context, revision int64) (domain.AuditEntry, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		entry, _ := debugMap["auditEntry"].(domain.AuditEntry)
+		err, _ := debugMap["error"].(error)
+		return entry, err
+	}
+
+	return domain.AuditEntry{}, nil
+}
+
+// GetAuditLog is default
+func (pr *mockTaskRepository) GetAuditLog(ctx context.Context, q AuditQuery) ([]domain.AuditEntry, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		entries, _ := debugMap["audit"].([]domain.AuditEntry)
+		err, _ := debugMap["error"].(error)
+		return entries, err
+	}
+
+	return make([]domain.AuditEntry, 0), nil
+}
+
+// TrashTask is default
+func (pr *mockTaskRepository) TrashTask(ctx context.Context, id int64, deletedAt time.Time) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// RestoreFromTrash is default
+func (pr *mockTaskRepository) RestoreFromTrash(ctx context.Context, id int64) error {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		if tasks, ok := tasksByID(debugMap); ok {
+			trash, _ := debugMap["trash"].([]domain.Task)
+			for _, t := range trash {
+				if t.Rowid == id {
+					t.DeletedAt = nil
+					tasks[id] = t
+					return nil
+				}
+			}
+			return errors.ErrorObjectNotFound
+		}
+		err, _ := debugMap["error"].(error)
+		return err
+	}
+	return nil
+}
+
+// GetTrash is default
+func (pr *mockTaskRepository) GetTrash(ctx context.Context) ([]domain.Task, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		tasks, _ := debugMap["trash"].([]domain.Task)
+		err, _ := debugMap["error"].(error)
+		return tasks, err
+	}
+
+	return make([]domain.Task, 0), nil
+}
+
+// GetTrashedTask is default
+func (pr *mockTaskRepository) GetTrashedTask(ctx context.Context, id int64) (domain.Task, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		tasks, _ := debugMap["trash"].([]domain.Task)
+		if err, _ := debugMap["error"].(error); err != nil {
+			return domain.Task{}, err
+		}
+		for _, t := range tasks {
+			if t.Rowid == id {
+				return t, nil
+			}
+		}
+	}
+	return domain.Task{}, errors.ErrorObjectNotFound
+}
+
+// SearchTasks is default
+func (pr *mockTaskRepository) SearchTasks(ctx context.Context, q SearchQuery) ([]domain.SearchResult, error) {
+	if debugMap, ok := ctx.Value(Debug).(map[string]interface{}); ok {
+		results, _ := debugMap["searchResults"].([]domain.SearchResult)
+		err, _ := debugMap["error"].(error)
+		return results, err
+	}
+
+	return make([]domain.SearchResult, 0), nil
+}
 
+// WithTransaction is default
+func (pr *mockTaskRepository) WithTransaction(ctx context.Context, fn func(repo ITaskRepo) error) error {
+	return fn(pr)
 }
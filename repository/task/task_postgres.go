@@ -0,0 +1,155 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"server/db"
+	"server/domain"
+)
+
+// taskRepositoryPostgres implements ITaskRepo interface for postgres db. The queries of the
+// sqlite repository are plain sql, which the postgres handler rebinds to its placeholders, so
+// only the methods needing postgres specific sql are implemented here.
+type taskRepositoryPostgres struct {
+	taskRepositorySqlite
+}
+
+// InitializePostgresTaskRepo is InitializeSqlite3TaskRepo for a postgres db handler
+func InitializePostgresTaskRepo(db db.Handler) error {
+	if err := InitializeTaskRepo(newTaskRepoPostgres(db)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newTaskRepoPostgres returns a postgres task repository
+func newTaskRepoPostgres(db db.Handler) ITaskRepo {
+	return taskRepositoryPostgres{taskRepositorySqlite{db}}
+}
+
+var _ ITaskRepo = taskRepositoryPostgres{}
+
+// searchVector is the text search vector of a task, with the title weighing more than the
+// description. The search migration indexes this very expression.
+const searchVector = `(setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', coalesce(description, '')), 'B'))`
+
+// AddTask saves a task in db, and returns its rowid. A task with a rowid keeps it, which is how
+// a deleted task is restored.
+func (pr taskRepositoryPostgres) AddTask(ctx context.Context, task domain.Task) (id int64, err error) {
+	err = pr.dbHandler.WithTx(ctx, func(tx db.Tx) error {
+		if task.Rowid != 0 {
+			if _, err := tx.Execute(ctx, "INSERT INTO task (rowid, title, description, dueDate, status, priority, effort, parentId, recurrence, created) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)", task.Rowid, task.Title, task.Description, task.DueDate.String(), task.Status, task.Priority, task.Effort, task.ParentID, task.Recurrence, created(task)); err != nil {
+				return err
+			}
+			// the sequence is not moved by explicit rowids, so catch it up
+			if _, err := tx.Execute(ctx, "SELECT setval(pg_get_serial_sequence('task', 'rowid'), (SELECT MAX(rowid) FROM task))"); err != nil {
+				return err
+			}
+			id = task.Rowid
+		} else {
+			row := tx.QueryRow(ctx, "INSERT INTO task (title, description, dueDate, status, priority, effort, parentId, recurrence, created) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING rowid", task.Title, task.Description, task.DueDate.String(), task.Status, task.Priority, task.Effort, task.ParentID, task.Recurrence, created(task))
+			if err := row.Scan(&id); err != nil {
+				return err
+			}
+		}
+		if len(task.Tags) > 0 {
+			if err := (taskRepositoryPostgres{taskRepositorySqlite{tx}}).AddTags(ctx, id, task.Tags); err != nil {
+				return err
+			}
+		}
+		return setReminders(ctx, tx, id, task.Reminders)
+	})
+	if err != nil {
+		return 0, repoError(err)
+	}
+	return id, nil
+}
+
+// AddWebhook subscribes a webhook to events of tasks. Postgres has no last insert id, the rowid
+// is returned by the insert.
+func (pr taskRepositoryPostgres) AddWebhook(ctx context.Context, webhook domain.Webhook) (id int64, err error) {
+	err = pr.dbHandler.QueryRow(ctx, "INSERT INTO task_webhook (url, secret, events, created) VALUES (?, ?, ?, ?) RETURNING rowid", webhook.URL, webhook.Secret, webhook.Events, webhook.Created.String()).Scan(&id)
+	return id, err
+}
+
+// AddWebhookDelivery queues a delivery, see AddWebhook
+func (pr taskRepositoryPostgres) AddWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) (id int64, err error) {
+	err = pr.dbHandler.QueryRow(ctx, "INSERT INTO task_webhook_delivery (webhookId, event, payload, status, attempts, nextAttempt, lastStatus, lastError, created) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING rowid", delivery.WebhookID, delivery.Event, delivery.Payload, delivery.Status, delivery.Attempts, delivery.NextAttempt.String(), delivery.LastStatus, delivery.LastError, delivery.Created.String()).Scan(&id)
+	return id, err
+}
+
+// AddCalendarFeed adds a calendar feed, see AddWebhook
+func (pr taskRepositoryPostgres) AddCalendarFeed(ctx context.Context, feed domain.CalendarFeed) (id int64, err error) {
+	err = pr.dbHandler.QueryRow(ctx, "INSERT INTO task_calendar_feed (name, tokenHash, created) VALUES (?, ?, ?) RETURNING rowid", feed.Name, feed.TokenHash, feed.Created.String()).Scan(&id)
+	return id, repoError(err)
+}
+
+// AddTags tags a task. Tags which the task already has are ignored.
+func (pr taskRepositoryPostgres) AddTags(ctx context.Context, taskID int64, tags []string) error {
+	return pr.dbHandler.WithTx(ctx, func(tx db.Tx) error {
+		if err := checkTaskExists(ctx, tx, taskID); err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if _, err := tx.Execute(ctx, "INSERT INTO task_tag (taskId, tag) VALUES (?, ?) ON CONFLICT DO NOTHING", taskID, tag); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SearchTasks runs a full text search with the text search functions of postgres, best matches
+// first. Title matches weigh ten times as much as description matches.
+func (pr taskRepositoryPostgres) SearchTasks(ctx context.Context, q SearchQuery) ([]domain.SearchResult, error) {
+	terms, err := parseSearch(q.Text)
+	if err != nil {
+		return make([]domain.SearchResult, 0), err
+	}
+
+	options := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=%d, MinWords=%d", matchStart, matchEnd, snippetWords, snippetWords/2)
+	statement := `SELECT task.*, ts_headline('simple', title || ' ' || coalesce(description, ''), query, ?) AS snippet,
+			ts_rank('{0, 0, 0.1, 1.0}', ` + searchVector + `, query) AS score
+		FROM task, to_tsquery('simple', ?) query
+		WHERE ` + searchVector + ` @@ query AND deletedAt IS NULL
+		ORDER BY score DESC, task.rowid`
+	args := []interface{}{options, tsQuery(terms)}
+	if q.Limit > 0 {
+		statement = statement + " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := pr.dbHandler.Query(ctx, statement, args...)
+	if err != nil {
+		return make([]domain.SearchResult, 0), err
+	}
+	defer rows.Close()
+	return pr.searchResults(ctx, rows)
+}
+
+// tsQuery builds a postgres tsquery from search terms. Words only have letters and digits, so
+// they can be quoted as they are.
+func tsQuery(terms []searchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		words := make([]string, 0, len(t.words))
+		for _, w := range t.words {
+			words = append(words, "'"+w+"'")
+		}
+		if t.prefix {
+			words[len(words)-1] += ":*"
+		}
+		parts = append(parts, "("+strings.Join(words, " <-> ")+")")
+	}
+	return strings.Join(parts, " & ")
+}
+
+// WithTransaction calls fn with a repository whose statements all run in one transaction. The
+// transaction is committed if fn returns nil, and rolled back otherwise.
+func (pr taskRepositoryPostgres) WithTransaction(ctx context.Context, fn func(repo ITaskRepo) error) error {
+	return pr.dbHandler.WithTx(ctx, func(tx db.Tx) error {
+		return fn(taskRepositoryPostgres{taskRepositorySqlite{tx}})
+	})
+}
@@ -4,6 +4,9 @@ import (
 	"log"
 	"server/db"
 	"server/domain"
+	"server/errors"
+	"strings"
+	"time"
 
 	"context"
 	"fmt"
@@ -36,6 +39,18 @@ func InitializeSqlite3TaskRepo(db db.Handler) error {
 	return nil
 }
 
+// repoError turns the errors of the db into the errors every repository returns: a row which was
+// not found is errors.ErrorObjectNotFound, and a duplicate is errors.ErrorObjectAlreadyExists.
+func repoError(err error) error {
+	switch {
+	case err == db.ErrNoRows:
+		return errors.ErrorObjectNotFound
+	case db.IsUniqueViolation(err):
+		return errors.ErrorObjectAlreadyExists
+	}
+	return err
+}
+
 // newTaskRepoSqlite returns an sqlite3 task repository
 func newTaskRepoSqlite(db db.Handler) ITaskRepo {
 	dbTaskRepo := new(taskRepositorySqlite)
@@ -45,38 +60,274 @@ func newTaskRepoSqlite(db db.Handler) ITaskRepo {
 
 var _ ITaskRepo = taskRepositorySqlite{}
 
-// GetTaskByTitle gets a task by its title
+// GetTaskByTitle gets a task by its title. Tasks in the trash are not found, by title or by id.
 func (pr taskRepositorySqlite) GetTaskByTitle(ctx context.Context, title string) (domain.Task, error) {
-	row := pr.dbHandler.QueryRow("SELECT * FROM task WHERE title =?", title)
+	row := pr.dbHandler.QueryRow(ctx, "SELECT * FROM task WHERE title =? AND deletedAt IS NULL", title)
 	var task domain.Task
 	if err := row.StructScan(&task); err != nil {
 		log.Printf("Title: %s", title)
+		return task, repoError(err)
+	}
+	return pr.withDetails(ctx, task)
+}
+
+// GetTaskByID gets a task by its rowid
+func (pr taskRepositorySqlite) GetTaskByID(ctx context.Context, id int64) (domain.Task, error) {
+	row := pr.dbHandler.QueryRow(ctx, "SELECT * FROM task WHERE rowid = ? AND deletedAt IS NULL", id)
+	var task domain.Task
+	if err := row.StructScan(&task); err != nil {
+		log.Printf("Id: %d", id)
+		return task, repoError(err)
+	}
+	return pr.withDetails(ctx, task)
+}
+
+// withDetails loads the tags and reminders of a single task
+func (pr taskRepositorySqlite) withDetails(ctx context.Context, task domain.Task) (domain.Task, error) {
+	tasks := []domain.Task{task}
+	if err := pr.loadDetails(ctx, tasks); err != nil {
 		return task, err
 	}
-	return task, nil
+	return tasks[0], nil
 }
 
-// GetAllTasks returns all tasks
+// GetAllTasks returns all tasks, except the ones in the trash
 func (pr taskRepositorySqlite) GetAllTasks(ctx context.Context) ([]domain.Task, error) {
-	var tasks []domain.Task
-	rows, err := pr.dbHandler.Query("SELECT * FROM task")
+	return pr.queryTasks(ctx, "SELECT * FROM task WHERE deletedAt IS NULL ORDER BY rowid")
+}
+
+// GetPaginatedTasks returns a page of tasks matching the query. Filters, sorting and the
+// cursor are all pushed down to sql, with rowid used as a tie-breaker for stable pages.
+func (pr taskRepositorySqlite) GetPaginatedTasks(ctx context.Context, q Query) (Page, error) {
+	q = q.normalize()
+	page := Page{Tasks: make([]domain.Task, 0)}
+
+	where, args := filterClause(q)
+	if err := pr.dbHandler.QueryRow(ctx, "SELECT COUNT(*) FROM task"+where, args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	column := sortColumns[q.SortBy]
+	op, order := ">", "ASC"
+	if q.Descending {
+		op, order = "<", "DESC"
+	}
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return page, err
+		}
+		cursorCond := fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND rowid %[2]s ?))", column, op)
+		where = where + " AND " + cursorCond
+		args = append(args, c.Value, c.Value, c.ID)
+	}
+
+	statement := fmt.Sprintf("SELECT * FROM task%s ORDER BY %s %s, rowid %s", where, column, order, order)
+	if q.Limit > 0 {
+		// fetch one extra row to know if there is a next page
+		statement = statement + " LIMIT ?"
+		args = append(args, q.Limit+1)
+	}
+
+	tasks, err := pr.queryTasks(ctx, statement, args...)
+	if err != nil {
+		return page, err
+	}
+	page.Tasks = tasks
+
+	if q.Limit > 0 && len(page.Tasks) > q.Limit {
+		page.Tasks = page.Tasks[:q.Limit]
+		last := page.Tasks[q.Limit-1]
+		page.NextCursor = cursor{sortValue(last, q.SortBy), last.Rowid}.encode()
+	}
+	return page, nil
+}
+
+// GetChildTasks returns the direct subtasks of a task
+func (pr taskRepositorySqlite) GetChildTasks(ctx context.Context, parentID int64) ([]domain.Task, error) {
+	return pr.queryTasks(ctx, "SELECT * FROM task WHERE parentId = ? AND deletedAt IS NULL ORDER BY rowid", parentID)
+}
+
+// GetSubtree returns a task along with all of its descendants, using a recursive query.
+// The root is always the first task in the list.
+func (pr taskRepositorySqlite) GetSubtree(ctx context.Context, rootID int64) ([]domain.Task, error) {
+	tasks, err := pr.queryTasks(ctx, `WITH RECURSIVE subtree(id, depth) AS (
+			SELECT rowid, 0 FROM task WHERE rowid = ? AND deletedAt IS NULL
+			UNION ALL
+			SELECT task.rowid, subtree.depth + 1 FROM task JOIN subtree ON task.parentId = subtree.id WHERE task.deletedAt IS NULL
+		)
+		SELECT task.* FROM task JOIN subtree ON task.rowid = subtree.id ORDER BY subtree.depth, task.rowid`, rootID)
 	if err != nil {
 		return tasks, err
 	}
+	if len(tasks) == 0 {
+		return tasks, errors.ErrorObjectNotFound
+	}
+	return tasks, nil
+}
 
+// queryTasks runs a select query on the task table, and scans all the rows along with their tags
+func (pr taskRepositorySqlite) queryTasks(ctx context.Context, statement string, args ...interface{}) ([]domain.Task, error) {
+	tasks := make([]domain.Task, 0)
+	rows, err := pr.dbHandler.Query(ctx, statement, args...)
+	if err != nil {
+		return tasks, err
+	}
+	defer rows.Close()
 	for rows.Next() {
-		var p domain.Task
-		err := rows.StructScan(&p)
-		if err != nil {
+		var t domain.Task
+		if err := rows.StructScan(&t); err != nil {
 			return make([]domain.Task, 0), err
 		}
-
-		tasks = append(tasks, p)
+		tasks = append(tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.Task, 0), err
+	}
+	if err := pr.loadDetails(ctx, tasks); err != nil {
+		return make([]domain.Task, 0), err
 	}
 	return tasks, nil
 }
 
-// AddTask saves a task in db. Returns the Row id of the task created, error if no task was created
+// loadDetails fills in the tags and reminders of tasks, with one query for each, for all of them
+func (pr taskRepositorySqlite) loadDetails(ctx context.Context, tasks []domain.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	index := make(map[int64]int, len(tasks))
+	placeholders := make([]string, 0, len(tasks))
+	args := make([]interface{}, 0, len(tasks))
+	for i := range tasks {
+		tasks[i].Tags = make([]string, 0)
+		tasks[i].Reminders = make([]domain.Duration, 0)
+		index[tasks[i].Rowid] = i
+		placeholders = append(placeholders, "?")
+		args = append(args, tasks[i].Rowid)
+	}
+	in := "(" + strings.Join(placeholders, ", ") + ")"
+
+	rows, err := pr.dbHandler.Query(ctx, "SELECT taskId, tag FROM task_tag WHERE taskId IN "+in+" ORDER BY tag", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var taskID int64
+		var tag string
+		if err := rows.Scan(&taskID, &tag); err != nil {
+			return err
+		}
+		i := index[taskID]
+		tasks[i].Tags = append(tasks[i].Tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = pr.dbHandler.Query(ctx, "SELECT taskId, leadTime FROM task_reminder WHERE taskId IN "+in, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var taskID int64
+		var leadTime domain.Duration
+		if err := rows.Scan(&taskID, &leadTime); err != nil {
+			return err
+		}
+		i := index[taskID]
+		tasks[i].Reminders = append(tasks[i].Reminders, leadTime)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range tasks {
+		tasks[i].Reminders = domain.SortLeadTimes(tasks[i].Reminders)
+	}
+	return nil
+}
+
+// setReminders replaces the lead times of the reminders of a task
+func setReminders(ctx context.Context, handler db.Handler, taskID int64, leadTimes []domain.Duration) error {
+	if _, err := handler.Execute(ctx, "DELETE FROM task_reminder WHERE taskId = ?", taskID); err != nil {
+		return err
+	}
+	for _, leadTime := range domain.SortLeadTimes(leadTimes) {
+		if _, err := handler.Execute(ctx, "INSERT INTO task_reminder (taskId, leadTime) VALUES (?, ?)", taskID, leadTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterClause builds the WHERE clause, along with its arguments, for the filters of a query.
+// Tasks in the trash are always left out.
+func filterClause(q Query) (string, []interface{}) {
+	conditions := []string{"deletedAt IS NULL"}
+	var args []interface{}
+
+	if len(q.Statuses) > 0 {
+		placeholders := make([]string, 0, len(q.Statuses))
+		for _, s := range q.Statuses {
+			placeholders = append(placeholders, "?")
+			args = append(args, s)
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if q.MinPriority != 0 {
+		conditions = append(conditions, "priority >= ?")
+		args = append(args, q.MinPriority)
+	}
+	if q.MaxPriority != 0 {
+		conditions = append(conditions, "priority <= ?")
+		args = append(args, q.MaxPriority)
+	}
+	if q.DueBefore != nil {
+		conditions = append(conditions, "dueDate < ?")
+		args = append(args, domain.Time(*q.DueBefore).String())
+	}
+	if q.DueAfter != nil {
+		conditions = append(conditions, "dueDate > ?")
+		args = append(args, domain.Time(*q.DueAfter).String())
+	}
+	if q.Title != "" {
+		// LIKE ignores case in sqlite, but not in postgres
+		conditions = append(conditions, `lower(title) LIKE lower(?) ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(q.Title)+"%")
+	}
+	if len(q.Tags) > 0 {
+		placeholders := make([]string, 0, len(q.Tags))
+		for _, tag := range q.Tags {
+			placeholders = append(placeholders, "?")
+			args = append(args, tag)
+		}
+		tagCondition := "rowid IN (SELECT taskId FROM task_tag WHERE tag IN (" + strings.Join(placeholders, ", ") + ")"
+		if q.AllTags {
+			tagCondition = tagCondition + " GROUP BY taskId HAVING COUNT(DISTINCT tag) = ?"
+			args = append(args, len(q.Tags))
+		}
+		conditions = append(conditions, tagCondition+")")
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// escapeLike escapes the wildcard characters of LIKE, so that user input is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// created is when a task was created, now for a new task. It is given explicitly rather than
+// left to CURRENT_TIMESTAMP, so that it is stored in the same form as every other time.
+func created(task domain.Task) string {
+	if time.Time(task.Created).IsZero() {
+		return domain.Time(time.Now().UTC().Truncate(time.Second)).String()
+	}
+	return task.Created.String()
+}
+
+// AddTask saves a task in db. Returns the Row id of the task created, error if no task was created.
+// A task with a rowid keeps it, which is how a deleted task is restored.
 func (pr taskRepositorySqlite) AddTask(ctx context.Context, task domain.Task) (id int64, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -85,30 +336,679 @@ func (pr taskRepositorySqlite) AddTask(ctx context.Context, task domain.Task) (i
 		}
 	}()
 
-	res, err := pr.dbHandler.Execute("INSERT INTO task (title, description, dueDate, status, priority, effort ) VALUES($1, $2, $3, $4, $5, $6)", task.Title, task.Description, task.DueDate.String(), task.Status, task.Priority, task.Effort)
+	err = pr.dbHandler.WithTx(ctx, func(tx db.Tx) error {
+		var res db.Result
+		var err error
+		if task.Rowid != 0 {
+			res, err = tx.Execute(ctx, "INSERT INTO task (rowid, title, description, dueDate, status, priority, effort, parentId, recurrence, created) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)", task.Rowid, task.Title, task.Description, task.DueDate.String(), task.Status, task.Priority, task.Effort, task.ParentID, task.Recurrence, created(task))
+		} else {
+			res, err = tx.Execute(ctx, "INSERT INTO task (title, description, dueDate, status, priority, effort, parentId, recurrence, created) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)", task.Title, task.Description, task.DueDate.String(), task.Status, task.Priority, task.Effort, task.ParentID, task.Recurrence, created(task))
+		}
+		if err != nil {
+			return err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		if len(task.Tags) > 0 {
+			if err := (taskRepositorySqlite{tx}).AddTags(ctx, id, task.Tags); err != nil {
+				return err
+			}
+		}
+		return setReminders(ctx, tx, id, task.Reminders)
+	})
 	if err != nil {
-		return 0, err
+		return 0, repoError(err)
+	}
+	return id, nil
+}
+
+// DeleteTask permanently deletes a task by its id, whether it is in the trash or not, along with
+// its dependencies, tags, completions, history, reminders and the uid it was imported with. Its
+// subtasks, which can only be in the trash, are left without a parent.
+func (pr taskRepositorySqlite) DeleteTask(ctx context.Context, id int64) error {
+	return pr.dbHandler.WithTx(ctx, func(tx db.Tx) error {
+		if _, err := tx.Execute(ctx, "DELETE FROM task_dependency WHERE taskId = ? OR blockedBy = ?", id, id); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_tag WHERE taskId = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_completion WHERE taskId = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_history WHERE taskId = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_reminder WHERE taskId = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_reminder_sent WHERE taskId = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_calendar_import WHERE taskId = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "UPDATE task SET parentId = NULL WHERE parentId = ?", id); err != nil {
+			return err
+		}
+		res, err := tx.Execute(ctx, "DELETE FROM task WHERE rowid = ?", id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return errors.ErrorObjectNotFound
+		}
+		return nil
+	})
+}
+
+// DeleteTaskByTitle deletes a task which is not in the trash by its title, along with its
+// dependencies, tags, completions, history, reminders and the uid it was imported with. Its
+// subtasks are left without a parent.
+func (pr taskRepositorySqlite) DeleteTaskByTitle(ctx context.Context, title string) error {
+	return pr.dbHandler.WithTx(ctx, func(tx db.Tx) error {
+		if _, err := tx.Execute(ctx, "DELETE FROM task_dependency WHERE taskId IN (SELECT rowid FROM task WHERE title = ? AND deletedAt IS NULL) OR blockedBy IN (SELECT rowid FROM task WHERE title = ? AND deletedAt IS NULL)", title, title); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_tag WHERE taskId IN (SELECT rowid FROM task WHERE title = ? AND deletedAt IS NULL)", title); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_completion WHERE taskId IN (SELECT rowid FROM task WHERE title = ? AND deletedAt IS NULL)", title); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_history WHERE taskId IN (SELECT rowid FROM task WHERE title = ? AND deletedAt IS NULL)", title); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_reminder WHERE taskId IN (SELECT rowid FROM task WHERE title = ? AND deletedAt IS NULL)", title); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_reminder_sent WHERE taskId IN (SELECT rowid FROM task WHERE title = ? AND deletedAt IS NULL)", title); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "DELETE FROM task_calendar_import WHERE taskId IN (SELECT rowid FROM task WHERE title = ? AND deletedAt IS NULL)", title); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(ctx, "UPDATE task SET parentId = NULL WHERE parentId IN (SELECT rowid FROM task WHERE title = ? AND deletedAt IS NULL)", title); err != nil {
+			return err
+		}
+		res, err := tx.Execute(ctx, "DELETE FROM task WHERE title = ? AND deletedAt IS NULL", title)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return errors.ErrorObjectNotFound
+		}
+		return nil
+	})
+}
+
+// UpdateTask updates all the columns of a task, identified by its rowid, and its reminders. Title
+// can be changed too. Tasks in the trash are not found.
+func (pr taskRepositorySqlite) UpdateTask(ctx context.Context, task domain.Task) error {
+	err := pr.dbHandler.WithTx(ctx, func(tx db.Tx) error {
+		res, err := tx.Execute(ctx, "UPDATE task SET title = $1, description = $2, dueDate = $3, status = $4, priority = $5, effort = $6, parentId = $7, recurrence = $8 WHERE rowid = $9 AND deletedAt IS NULL", task.Title, task.Description, task.DueDate.String(), task.Status, task.Priority, task.Effort, task.ParentID, task.Recurrence, task.Rowid)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return errors.ErrorObjectNotFound
+		}
+		return setReminders(ctx, tx, task.Rowid, task.Reminders)
+	})
+	return repoError(err)
+}
+
+// AddDependency records that a task is blocked by another task
+func (pr taskRepositorySqlite) AddDependency(ctx context.Context, dependency domain.Dependency) error {
+	_, err := pr.dbHandler.Execute(ctx, "INSERT INTO task_dependency (taskId, blockedBy) VALUES (?, ?)", dependency.TaskID, dependency.BlockedBy)
+	return repoError(err)
+}
+
+// RemoveDependency removes a dependency. Returns errors.ErrorObjectNotFound if it did not exist.
+func (pr taskRepositorySqlite) RemoveDependency(ctx context.Context, dependency domain.Dependency) error {
+	res, err := pr.dbHandler.Execute(ctx, "DELETE FROM task_dependency WHERE taskId = ? AND blockedBy = ?", dependency.TaskID, dependency.BlockedBy)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrorObjectNotFound
+	}
+	return nil
+}
+
+// GetBlockers returns the tasks which block a task
+func (pr taskRepositorySqlite) GetBlockers(ctx context.Context, taskID int64) ([]domain.Task, error) {
+	return pr.queryTasks(ctx, "SELECT task.* FROM task JOIN task_dependency ON task.rowid = task_dependency.blockedBy WHERE task_dependency.taskId = ? AND task.deletedAt IS NULL ORDER BY task.rowid", taskID)
+}
+
+// GetAllDependencies returns every dependency between tasks
+func (pr taskRepositorySqlite) GetAllDependencies(ctx context.Context) ([]domain.Dependency, error) {
+	dependencies := make([]domain.Dependency, 0)
+	rows, err := pr.dbHandler.Query(ctx, "SELECT taskId, blockedBy FROM task_dependency ORDER BY taskId, blockedBy")
+	if err != nil {
+		return dependencies, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d domain.Dependency
+		if err := rows.StructScan(&d); err != nil {
+			return make([]domain.Dependency, 0), err
+		}
+		dependencies = append(dependencies, d)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.Dependency, 0), err
 	}
-	id, err = res.LastInsertId()
+	return dependencies, nil
+}
+
+// checkTaskExists returns errors.ErrorObjectNotFound if there is no task with the id outside the trash
+func checkTaskExists(ctx context.Context, handler db.Handler, id int64) error {
+	var n int
+	if err := handler.QueryRow(ctx, "SELECT COUNT(*) FROM task WHERE rowid = ? AND deletedAt IS NULL", id).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrorObjectNotFound
+	}
+	return nil
+}
+
+// AddTags tags a task. Tags which the task already has are ignored.
+func (pr taskRepositorySqlite) AddTags(ctx context.Context, taskID int64, tags []string) error {
+	return pr.dbHandler.WithTx(ctx, func(tx db.Tx) error {
+		if err := checkTaskExists(ctx, tx, taskID); err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if _, err := tx.Execute(ctx, "INSERT OR IGNORE INTO task_tag (taskId, tag) VALUES (?, ?)", taskID, tag); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveTag removes a tag from a task. Returns errors.ErrorObjectNotFound if the task didn't have it.
+func (pr taskRepositorySqlite) RemoveTag(ctx context.Context, taskID int64, tag string) error {
+	res, err := pr.dbHandler.Execute(ctx, "DELETE FROM task_tag WHERE taskId = ? AND tag = ?", taskID, tag)
 	if err != nil {
-		return 0, nil
+		return err
 	}
-	return
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrorObjectNotFound
+	}
+	return nil
 }
 
-// DeleteTask deletes a task by its id
-func (pr taskRepositorySqlite) DeleteTask(ctx context.Context, id int64) error {
-	_, err := pr.dbHandler.Execute("DELETE FROM task WHERE rowid = ?", id)
+// GetTagCounts returns every tag in use, with the number of tasks having it
+func (pr taskRepositorySqlite) GetTagCounts(ctx context.Context) ([]domain.TagCount, error) {
+	counts := make([]domain.TagCount, 0)
+	rows, err := pr.dbHandler.Query(ctx, "SELECT tag, COUNT(*) AS count FROM task_tag JOIN task ON task.rowid = task_tag.taskId WHERE task.deletedAt IS NULL GROUP BY tag ORDER BY count DESC, tag")
+	if err != nil {
+		return counts, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c domain.TagCount
+		if err := rows.StructScan(&c); err != nil {
+			return make([]domain.TagCount, 0), err
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.TagCount, 0), err
+	}
+	return counts, nil
+}
+
+// SearchTasks runs a full text search on the task_fts table of the search migration, best matches first.
+// Title matches weigh ten times as much as description matches.
+func (pr taskRepositorySqlite) SearchTasks(ctx context.Context, q SearchQuery) ([]domain.SearchResult, error) {
+	results := make([]domain.SearchResult, 0)
+	terms, err := parseSearch(q.Text)
+	if err != nil {
+		return results, err
+	}
+
+	statement := `SELECT task.*, snippet(task_fts, -1, ?, ?, ?, ?) AS snippet, -bm25(task_fts, 10.0, 1.0) AS score
+		FROM task_fts JOIN task ON task.rowid = task_fts.rowid
+		WHERE task_fts MATCH ? AND task.deletedAt IS NULL
+		ORDER BY score DESC, task.rowid`
+	args := []interface{}{matchStart, matchEnd, ellipsis, snippetWords, matchExpression(terms)}
+	if q.Limit > 0 {
+		statement = statement + " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := pr.dbHandler.Query(ctx, statement, args...)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table: task_fts") {
+			return results, errors.ErrorInternal.WithMessage("Full text search is not set up, build the server with -tags sqlite_fts5 and migrate the db")
+		}
+		return results, err
+	}
+	defer rows.Close()
+	return pr.searchResults(ctx, rows)
+}
+
+// searchResults scans the rows of a search, which are tasks along with a snippet, its matches
+// between matchStart and matchEnd, and a score
+func (pr taskRepositorySqlite) searchResults(ctx context.Context, rows db.Rows) ([]domain.SearchResult, error) {
+	results := make([]domain.SearchResult, 0)
+	for rows.Next() {
+		var r struct {
+			domain.Task
+			Snippet string
+			Score   float64
+		}
+		if err := rows.StructScan(&r); err != nil {
+			return make([]domain.SearchResult, 0), err
+		}
+		results = append(results, domain.SearchResult{Task: r.Task, Snippet: escapeSnippet(r.Snippet), Score: r.Score})
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.SearchResult, 0), err
+	}
+
+	tasks := make([]domain.Task, len(results))
+	for i := range results {
+		tasks[i] = results[i].Task
+	}
+	if err := pr.loadDetails(ctx, tasks); err != nil {
+		return make([]domain.SearchResult, 0), err
+	}
+	for i := range results {
+		results[i].Task = tasks[i]
+	}
+	return results, nil
+}
+
+// TrashTask moves a task to the trash
+func (pr taskRepositorySqlite) TrashTask(ctx context.Context, id int64, deletedAt time.Time) error {
+	res, err := pr.dbHandler.Execute(ctx, "UPDATE task SET deletedAt = ? WHERE rowid = ? AND deletedAt IS NULL", domain.Time(deletedAt).String(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrorObjectNotFound
+	}
+	return nil
+}
+
+// RestoreFromTrash takes a task out of the trash. It is errors.ErrorObjectAlreadyExists if a
+// task which is not in the trash has its title.
+func (pr taskRepositorySqlite) RestoreFromTrash(ctx context.Context, id int64) error {
+	res, err := pr.dbHandler.Execute(ctx, "UPDATE task SET deletedAt = NULL WHERE rowid = ? AND deletedAt IS NOT NULL", id)
+	if err != nil {
+		return repoError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrorObjectNotFound
+	}
+	return nil
+}
+
+// GetTrash returns the tasks in the trash
+func (pr taskRepositorySqlite) GetTrash(ctx context.Context) ([]domain.Task, error) {
+	return pr.queryTasks(ctx, "SELECT * FROM task WHERE deletedAt IS NOT NULL ORDER BY rowid")
+}
+
+// GetTrashedTask gets a task in the trash by its rowid
+func (pr taskRepositorySqlite) GetTrashedTask(ctx context.Context, id int64) (domain.Task, error) {
+	row := pr.dbHandler.QueryRow(ctx, "SELECT * FROM task WHERE rowid = ? AND deletedAt IS NOT NULL", id)
+	var task domain.Task
+	if err := row.StructScan(&task); err != nil {
+		return task, repoError(err)
+	}
+	return pr.withDetails(ctx, task)
+}
+
+// AddCompletion records a completed occurrence of a recurring task
+func (pr taskRepositorySqlite) AddCompletion(ctx context.Context, completion domain.Completion) error {
+	_, err := pr.dbHandler.Execute(ctx, "INSERT INTO task_completion (taskId, dueDate, completed) VALUES (?, ?, ?)", completion.TaskID, completion.DueDate.String(), completion.Completed.String())
 	return err
 }
 
-// DeleteTaskByTitle deletes a task by its title
-func (pr taskRepositorySqlite) DeleteTaskByTitle(ctx context.Context, title string) error {
-	_, err := pr.dbHandler.Execute("DELETE FROM task WHERE title = ?", title)
+// GetCompletions returns the completed occurrences of a task, oldest first
+func (pr taskRepositorySqlite) GetCompletions(ctx context.Context, taskID int64) ([]domain.Completion, error) {
+	completions := make([]domain.Completion, 0)
+	rows, err := pr.dbHandler.Query(ctx, "SELECT * FROM task_completion WHERE taskId = ? ORDER BY rowid", taskID)
+	if err != nil {
+		return completions, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c domain.Completion
+		if err := rows.StructScan(&c); err != nil {
+			return make([]domain.Completion, 0), err
+		}
+		completions = append(completions, c)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.Completion, 0), err
+	}
+	return completions, nil
+}
+
+// AddHistory records a status transition of a task
+func (pr taskRepositorySqlite) AddHistory(ctx context.Context, entry domain.HistoryEntry) error {
+	_, err := pr.dbHandler.Execute(ctx, "INSERT INTO task_history (taskId, oldStatus, newStatus, changed, username) VALUES (?, ?, ?, ?, ?)", entry.TaskID, entry.OldStatus, entry.NewStatus, entry.Changed.String(), entry.Username)
 	return err
 }
 
-func (pr taskRepositorySqlite) UpdateTask(ctx context.Context, task domain.Task) error {
-	_, err := pr.dbHandler.Execute("UPDATE task SET description = $1, dueDate = $2, status = $3, priority = $4, effort = $5", task.Description, task.DueDate.String(), task.Status, task.Priority, task.Effort)
+// GetHistory returns the status transitions of a task, oldest first
+func (pr taskRepositorySqlite) GetHistory(ctx context.Context, taskID int64) ([]domain.HistoryEntry, error) {
+	history := make([]domain.HistoryEntry, 0)
+	rows, err := pr.dbHandler.Query(ctx, "SELECT * FROM task_history WHERE taskId = ? ORDER BY rowid", taskID)
+	if err != nil {
+		return history, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h domain.HistoryEntry
+		if err := rows.StructScan(&h); err != nil {
+			return make([]domain.HistoryEntry, 0), err
+		}
+		history = append(history, h)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.HistoryEntry, 0), err
+	}
+	return history, nil
+}
+
+// AddSentReminder records that a reminder went out. A reminder which was recorded already is an
+// AlreadyExists error.
+func (pr taskRepositorySqlite) AddSentReminder(ctx context.Context, reminder domain.SentReminder) error {
+	_, err := pr.dbHandler.Execute(ctx, "INSERT INTO task_reminder_sent (taskId, dueDate, leadTime, notifier, sent) VALUES (?, ?, ?, ?, ?)", reminder.TaskID, reminder.DueDate.String(), reminder.LeadTime, reminder.Notifier, reminder.Sent.String())
+	return repoError(err)
+}
+
+// GetSentReminders returns the reminders which went out for a task, oldest first
+func (pr taskRepositorySqlite) GetSentReminders(ctx context.Context, taskID int64) ([]domain.SentReminder, error) {
+	sent := make([]domain.SentReminder, 0)
+	rows, err := pr.dbHandler.Query(ctx, "SELECT taskId, dueDate, leadTime, notifier, sent FROM task_reminder_sent WHERE taskId = ? ORDER BY sent, notifier", taskID)
+	if err != nil {
+		return sent, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s domain.SentReminder
+		if err := rows.StructScan(&s); err != nil {
+			return make([]domain.SentReminder, 0), err
+		}
+		sent = append(sent, s)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.SentReminder, 0), err
+	}
+	return sent, nil
+}
+
+// AddAuditEntry records a revision of a task
+func (pr taskRepositorySqlite) AddAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	_, err := pr.dbHandler.Execute(ctx, "INSERT INTO task_audit (taskId, action, changes, snapshot, changed, username) VALUES (?, ?, ?, ?, ?, ?)", entry.TaskID, entry.Action, entry.Changes, entry.Snapshot, entry.Changed.String(), entry.Username)
 	return err
 }
+
+// GetAuditEntry returns a revision by its number
+func (pr taskRepositorySqlite) GetAuditEntry(ctx context.Context, revision int64) (domain.AuditEntry, error) {
+	var entry domain.AuditEntry
+	row := pr.dbHandler.QueryRow(ctx, "SELECT * FROM task_audit WHERE rowid = ?", revision)
+	err := row.StructScan(&entry)
+	return entry, repoError(err)
+}
+
+// GetAuditLog returns the revisions matching q, oldest first. Times are stored in RFC 3339 in
+// UTC, which sorts as text, so the time range is checked by the query.
+func (pr taskRepositorySqlite) GetAuditLog(ctx context.Context, q AuditQuery) ([]domain.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	if q.TaskID != 0 {
+		conditions = append(conditions, "taskId = ?")
+		args = append(args, q.TaskID)
+	}
+	if q.Username != "" {
+		conditions = append(conditions, "username = ?")
+		args = append(args, q.Username)
+	}
+	if q.From != nil {
+		conditions = append(conditions, "changed >= ?")
+		args = append(args, domain.Time(*q.From).String())
+	}
+	if q.To != nil {
+		conditions = append(conditions, "changed < ?")
+		args = append(args, domain.Time(*q.To).String())
+	}
+	query := "SELECT * FROM task_audit"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY rowid"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	entries := make([]domain.AuditEntry, 0)
+	rows, err := pr.dbHandler.Query(ctx, query, args...)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e domain.AuditEntry
+		if err := rows.StructScan(&e); err != nil {
+			return make([]domain.AuditEntry, 0), err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.AuditEntry, 0), err
+	}
+	return entries, nil
+}
+
+// AddWebhook subscribes a webhook to events of tasks
+func (pr taskRepositorySqlite) AddWebhook(ctx context.Context, webhook domain.Webhook) (int64, error) {
+	res, err := pr.dbHandler.Execute(ctx, "INSERT INTO task_webhook (url, secret, events, created) VALUES (?, ?, ?, ?)", webhook.URL, webhook.Secret, webhook.Events, webhook.Created.String())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetWebhook gets a webhook by its rowid
+func (pr taskRepositorySqlite) GetWebhook(ctx context.Context, id int64) (domain.Webhook, error) {
+	var webhook domain.Webhook
+	err := pr.dbHandler.QueryRow(ctx, "SELECT * FROM task_webhook WHERE rowid = ?", id).StructScan(&webhook)
+	return webhook, repoError(err)
+}
+
+// GetWebhooks returns every webhook, by rowid
+func (pr taskRepositorySqlite) GetWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	webhooks := make([]domain.Webhook, 0)
+	rows, err := pr.dbHandler.Query(ctx, "SELECT * FROM task_webhook ORDER BY rowid")
+	if err != nil {
+		return webhooks, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var webhook domain.Webhook
+		if err := rows.StructScan(&webhook); err != nil {
+			return make([]domain.Webhook, 0), err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.Webhook, 0), err
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a webhook along with its deliveries
+func (pr taskRepositorySqlite) DeleteWebhook(ctx context.Context, id int64) error {
+	err := pr.dbHandler.WithTx(ctx, func(tx db.Tx) error {
+		if _, err := tx.Execute(ctx, "DELETE FROM task_webhook_delivery WHERE webhookId = ?", id); err != nil {
+			return err
+		}
+		res, err := tx.Execute(ctx, "DELETE FROM task_webhook WHERE rowid = ?", id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return errors.ErrorObjectNotFound
+		}
+		return nil
+	})
+	return repoError(err)
+}
+
+// AddWebhookDelivery queues a delivery
+func (pr taskRepositorySqlite) AddWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) (int64, error) {
+	res, err := pr.dbHandler.Execute(ctx, "INSERT INTO task_webhook_delivery (webhookId, event, payload, status, attempts, nextAttempt, lastStatus, lastError, created) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", delivery.WebhookID, delivery.Event, delivery.Payload, delivery.Status, delivery.Attempts, delivery.NextAttempt.String(), delivery.LastStatus, delivery.LastError, delivery.Created.String())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetWebhookDelivery gets a delivery by its rowid
+func (pr taskRepositorySqlite) GetWebhookDelivery(ctx context.Context, id int64) (domain.WebhookDelivery, error) {
+	var delivery domain.WebhookDelivery
+	err := pr.dbHandler.QueryRow(ctx, "SELECT * FROM task_webhook_delivery WHERE rowid = ?", id).StructScan(&delivery)
+	return delivery, repoError(err)
+}
+
+// GetWebhookDeliveries returns the deliveries matching q
+func (pr taskRepositorySqlite) GetWebhookDeliveries(ctx context.Context, q DeliveryQuery) ([]domain.WebhookDelivery, error) {
+	var conditions []string
+	var args []interface{}
+	if q.WebhookID != 0 {
+		conditions = append(conditions, "webhookId = ?")
+		args = append(args, q.WebhookID)
+	}
+	if q.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, q.Status)
+	}
+	if q.DueBefore != nil {
+		conditions = append(conditions, "nextAttempt <= ?")
+		args = append(args, domain.Time(*q.DueBefore).String())
+	}
+	query := "SELECT * FROM task_webhook_delivery"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY rowid"
+	if q.Newest {
+		query += " DESC"
+	}
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	deliveries := make([]domain.WebhookDelivery, 0)
+	rows, err := pr.dbHandler.Query(ctx, query, args...)
+	if err != nil {
+		return deliveries, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var delivery domain.WebhookDelivery
+		if err := rows.StructScan(&delivery); err != nil {
+			return make([]domain.WebhookDelivery, 0), err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.WebhookDelivery, 0), err
+	}
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery records the outcome of an attempt of a delivery, or its replay
+func (pr taskRepositorySqlite) UpdateWebhookDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	res, err := pr.dbHandler.Execute(ctx, "UPDATE task_webhook_delivery SET status = ?, attempts = ?, nextAttempt = ?, lastStatus = ?, lastError = ? WHERE rowid = ?", delivery.Status, delivery.Attempts, delivery.NextAttempt.String(), delivery.LastStatus, delivery.LastError, delivery.Rowid)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrorObjectNotFound
+	}
+	return nil
+}
+
+// AddCalendarFeed adds a calendar feed. Its token hash has to be unique.
+func (pr taskRepositorySqlite) AddCalendarFeed(ctx context.Context, feed domain.CalendarFeed) (int64, error) {
+	res, err := pr.dbHandler.Execute(ctx, "INSERT INTO task_calendar_feed (name, tokenHash, created) VALUES (?, ?, ?)", feed.Name, feed.TokenHash, feed.Created.String())
+	if err != nil {
+		return 0, repoError(err)
+	}
+	return res.LastInsertId()
+}
+
+// GetCalendarFeeds returns every calendar feed, by rowid
+func (pr taskRepositorySqlite) GetCalendarFeeds(ctx context.Context) ([]domain.CalendarFeed, error) {
+	feeds := make([]domain.CalendarFeed, 0)
+	rows, err := pr.dbHandler.Query(ctx, "SELECT * FROM task_calendar_feed ORDER BY rowid")
+	if err != nil {
+		return feeds, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var feed domain.CalendarFeed
+		if err := rows.StructScan(&feed); err != nil {
+			return make([]domain.CalendarFeed, 0), err
+		}
+		feeds = append(feeds, feed)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]domain.CalendarFeed, 0), err
+	}
+	return feeds, nil
+}
+
+// GetCalendarFeedByToken gets the calendar feed with the token of hash tokenHash
+func (pr taskRepositorySqlite) GetCalendarFeedByToken(ctx context.Context, tokenHash string) (domain.CalendarFeed, error) {
+	var feed domain.CalendarFeed
+	err := pr.dbHandler.QueryRow(ctx, "SELECT * FROM task_calendar_feed WHERE tokenHash = ?", tokenHash).StructScan(&feed)
+	return feed, repoError(err)
+}
+
+// DeleteCalendarFeed deletes a calendar feed, its token stops working
+func (pr taskRepositorySqlite) DeleteCalendarFeed(ctx context.Context, id int64) error {
+	res, err := pr.dbHandler.Execute(ctx, "DELETE FROM task_calendar_feed WHERE rowid = ?", id)
+	if err != nil {
+		return repoError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errors.ErrorObjectNotFound
+	}
+	return nil
+}
+
+// AddImportedTodo records the uid of a to-do which was imported as a task. A uid which was
+// recorded already is an AlreadyExists error.
+func (pr taskRepositorySqlite) AddImportedTodo(ctx context.Context, todo domain.ImportedTodo) error {
+	_, err := pr.dbHandler.Execute(ctx, "INSERT INTO task_calendar_import (uid, taskId) VALUES (?, ?)", todo.UID, todo.TaskID)
+	return repoError(err)
+}
+
+// GetImportedTodo gets the to-do which was imported with uid
+func (pr taskRepositorySqlite) GetImportedTodo(ctx context.Context, uid string) (domain.ImportedTodo, error) {
+	var todo domain.ImportedTodo
+	err := pr.dbHandler.QueryRow(ctx, "SELECT uid, taskId FROM task_calendar_import WHERE uid = ?", uid).StructScan(&todo)
+	return todo, repoError(err)
+}
+
+// WithTransaction calls fn with a repository whose statements all run in one transaction. The
+// transaction is committed if fn returns nil, and rolled back otherwise.
+func (pr taskRepositorySqlite) WithTransaction(ctx context.Context, fn func(repo ITaskRepo) error) error {
+	return pr.dbHandler.WithTx(ctx, func(tx db.Tx) error {
+		return fn(taskRepositorySqlite{tx})
+	})
+}
@@ -0,0 +1,497 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"server/db"
+	"server/domain"
+	"server/errors"
+)
+
+// newTestSqliteRepo opens an in memory db, with every migration of SqliteMigrations applied
+func newTestSqliteRepo(t *testing.T) ITaskRepo {
+	handler := db.NewSqliteHandler(":memory:", 0)
+	// every connection to :memory: opens a new db, so stick to one
+	handler.Conn.SetMaxOpenConns(1)
+	// foreign keys are enforced, like they are by postgres
+	if _, err := handler.Execute(context.Background(), "PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(handler, SqliteMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return newTaskRepoSqlite(handler)
+}
+
+// testRepos returns a fresh repository of every backend. Postgres is only included when
+// TASK_TEST_POSTGRES_URL is set, see newTestPostgresRepo.
+func testRepos(t *testing.T) map[string]ITaskRepo {
+	repos := map[string]ITaskRepo{"sqlite": newTestSqliteRepo(t), "inmemory": newTestInMemoryRepo()}
+	if url := os.Getenv(postgresURLEnv); url != "" {
+		repos["postgres"] = newTestPostgresRepo(t, url)
+	}
+	return repos
+}
+
+func newTestInMemoryRepo() ITaskRepo {
+	return newInMemoryTaskRepo()
+}
+
+func seedTasks(t *testing.T, repo ITaskRepo) {
+	due := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+	tasks := []domain.Task{
+		{Rowid: 1, Title: "write report", Priority: 3, Status: domain.Pending, DueDate: domain.Time(due.AddDate(0, 0, 3))},
+		{Rowid: 2, Title: "review report", Priority: 5, Status: domain.InProgress, DueDate: domain.Time(due.AddDate(0, 0, 1))},
+		{Rowid: 3, Title: "buy milk", Priority: 1, Status: domain.Done, DueDate: domain.Time(due.AddDate(0, 0, 2))},
+		{Rowid: 4, Title: "call 100%", Priority: 3, Status: domain.Pending, DueDate: domain.Time(due.AddDate(0, 0, 5))},
+		{Rowid: 5, Title: "fix bike", Priority: 4, Status: domain.Pending, DueDate: domain.Time(due.AddDate(0, 0, 4))},
+	}
+	for _, task := range tasks {
+		if _, err := repo.AddTask(context.Background(), task); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func titles(tasks []domain.Task) []string {
+	t := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		t = append(t, task.Title)
+	}
+	return t
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpdateTaskRenamesByID(t *testing.T) {
+	for repoName, repo := range testRepos(t) {
+		ctx := context.Background()
+		seedTasks(t, repo)
+
+		task, err := repo.GetTaskByID(ctx, 2)
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		task.Title = "review/final report"
+		if err := repo.UpdateTask(ctx, task); err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+
+		if _, err := repo.GetTaskByTitle(ctx, "review report"); err == nil {
+			t.Errorf("%s: old title should not be found", repoName)
+		}
+		renamed, err := repo.GetTaskByID(ctx, 2)
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if renamed.Title != "review/final report" {
+			t.Errorf("%s: got title %q", repoName, renamed.Title)
+		}
+
+		// other tasks are untouched
+		other, _ := repo.GetTaskByID(ctx, 1)
+		if other.Title != "write report" {
+			t.Errorf("%s: update leaked to another task: %q", repoName, other.Title)
+		}
+
+		renamed.Title = "write report"
+		if err := repo.UpdateTask(ctx, renamed); err == nil {
+			t.Errorf("%s: renaming to an existing title should fail", repoName)
+		}
+	}
+}
+
+func TestGetSubtree(t *testing.T) {
+	for repoName, repo := range testRepos(t) {
+		ctx := context.Background()
+		seedTasks(t, repo)
+
+		// 1 <- 2 <- 3, and 1 <- 4
+		for child, parent := range map[int64]int64{2: 1, 3: 2, 4: 1} {
+			task, _ := repo.GetTaskByID(ctx, child)
+			p := parent
+			task.ParentID = &p
+			if err := repo.UpdateTask(ctx, task); err != nil {
+				t.Fatalf("%s: %v", repoName, err)
+			}
+		}
+
+		children, err := repo.GetChildTasks(ctx, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if got, want := titles(children), []string{"review report", "call 100%"}; !equal(got, want) {
+			t.Errorf("%s: got children %v, want %v", repoName, got, want)
+		}
+
+		subtree, err := repo.GetSubtree(ctx, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if got, want := titles(subtree), []string{"write report", "review report", "call 100%", "buy milk"}; !equal(got, want) {
+			t.Errorf("%s: got subtree %v, want %v", repoName, got, want)
+		}
+
+		if _, err := repo.GetSubtree(ctx, 42); err == nil {
+			t.Errorf("%s: expected an error for a missing root", repoName)
+		}
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	for repoName, repo := range testRepos(t) {
+		ctx := context.Background()
+		seedTasks(t, repo)
+
+		for _, d := range []domain.Dependency{{TaskID: 1, BlockedBy: 2}, {TaskID: 1, BlockedBy: 3}, {TaskID: 4, BlockedBy: 1}} {
+			if err := repo.AddDependency(ctx, d); err != nil {
+				t.Fatalf("%s: %v", repoName, err)
+			}
+		}
+		if err := repo.AddDependency(ctx, domain.Dependency{TaskID: 1, BlockedBy: 2}); err == nil {
+			t.Errorf("%s: expected an error for a duplicate dependency", repoName)
+		}
+
+		blockers, err := repo.GetBlockers(ctx, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if got, want := titles(blockers), []string{"review report", "buy milk"}; !equal(got, want) {
+			t.Errorf("%s: got blockers %v, want %v", repoName, got, want)
+		}
+
+		if err := repo.RemoveDependency(ctx, domain.Dependency{TaskID: 1, BlockedBy: 3}); err != nil {
+			t.Errorf("%s: %v", repoName, err)
+		}
+		if err := repo.RemoveDependency(ctx, domain.Dependency{TaskID: 1, BlockedBy: 3}); err == nil {
+			t.Errorf("%s: expected an error for a missing dependency", repoName)
+		}
+
+		// deleting a task drops the dependencies it is part of
+		if err := repo.DeleteTask(ctx, 1); err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		dependencies, err := repo.GetAllDependencies(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if len(dependencies) != 0 {
+			t.Errorf("%s: got dependencies %v after delete", repoName, dependencies)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	for repoName, repo := range testRepos(t) {
+		ctx := context.Background()
+		seedTasks(t, repo)
+
+		for id, tags := range map[int64][]string{1: {"work", "urgent"}, 2: {"work"}, 3: {"home", "urgent"}} {
+			if err := repo.AddTags(ctx, id, tags); err != nil {
+				t.Fatalf("%s: %v", repoName, err)
+			}
+		}
+		// adding a tag twice is fine
+		if err := repo.AddTags(ctx, 2, []string{"work"}); err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+
+		task, _ := repo.GetTaskByID(ctx, 1)
+		if got, want := task.Tags, []string{"urgent", "work"}; !equal(got, want) {
+			t.Errorf("%s: got tags %v, want %v", repoName, got, want)
+		}
+
+		anyOf, err := repo.GetPaginatedTasks(ctx, Query{Tags: []string{"work", "urgent"}})
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if got, want := titles(anyOf.Tasks), []string{"write report", "review report", "buy milk"}; !equal(got, want) {
+			t.Errorf("%s: got any of %v, want %v", repoName, got, want)
+		}
+		allOf, err := repo.GetPaginatedTasks(ctx, Query{Tags: []string{"work", "urgent"}, AllTags: true})
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if got, want := titles(allOf.Tasks), []string{"write report"}; !equal(got, want) {
+			t.Errorf("%s: got all of %v, want %v", repoName, got, want)
+		}
+
+		if err := repo.RemoveTag(ctx, 3, "urgent"); err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if err := repo.RemoveTag(ctx, 3, "urgent"); err == nil {
+			t.Errorf("%s: expected an error removing a missing tag", repoName)
+		}
+		counts, err := repo.GetTagCounts(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		want := []domain.TagCount{{Tag: "work", Count: 2}, {Tag: "home", Count: 1}, {Tag: "urgent", Count: 1}}
+		if len(counts) != len(want) {
+			t.Fatalf("%s: got counts %v, want %v", repoName, counts, want)
+		}
+		for i := range want {
+			if counts[i] != want[i] {
+				t.Errorf("%s: got counts %v, want %v", repoName, counts, want)
+				break
+			}
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	for repoName, repo := range testRepos(t) {
+		ctx := context.Background()
+		seedTasks(t, repo)
+
+		changed := domain.Time(time.Date(2030, 1, 2, 10, 0, 0, 0, time.UTC))
+		entries := []domain.HistoryEntry{
+			{TaskID: 1, OldStatus: domain.Pending, NewStatus: domain.InProgress, Changed: changed, Username: "adi"},
+			{TaskID: 1, OldStatus: domain.InProgress, NewStatus: domain.Done, Changed: changed},
+		}
+		for _, e := range entries {
+			if err := repo.AddHistory(ctx, e); err != nil {
+				t.Fatalf("%s: %v", repoName, err)
+			}
+		}
+
+		history, err := repo.GetHistory(ctx, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if len(history) != 2 || history[0].Username != "adi" || history[1].NewStatus != domain.Done {
+			t.Errorf("%s: got history %v", repoName, history)
+		}
+
+		if err := repo.DeleteTask(ctx, 1); err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if history, _ := repo.GetHistory(ctx, 1); len(history) != 0 {
+			t.Errorf("%s: history of a deleted task should be gone, got %v", repoName, history)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	for repoName, repo := range testRepos(t) {
+		ctx := context.Background()
+		seedTasks(t, repo)
+
+		before, _ := repo.GetTaskByID(ctx, 1)
+		after := before
+		after.Priority = 5
+		changed := time.Date(2030, 1, 2, 10, 0, 0, 0, time.UTC)
+		entries := []domain.AuditEntry{
+			{TaskID: 1, Action: domain.AuditUpdate, Changes: domain.Diff(&before, &after), Snapshot: domain.Snapshot(after), Changed: domain.Time(changed), Username: "adi"},
+			{TaskID: 2, Action: domain.AuditDelete, Changes: domain.FieldChanges{}, Snapshot: domain.Snapshot(before), Changed: domain.Time(changed.AddDate(0, 0, 1))},
+		}
+		for _, e := range entries {
+			if err := repo.AddAuditEntry(ctx, e); err != nil {
+				t.Fatalf("%s: %v", repoName, err)
+			}
+		}
+
+		entry, err := repo.GetAuditEntry(ctx, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if want := (domain.FieldChanges{{Field: "priority", Old: "3", New: "5"}}); len(entry.Changes) != 1 || entry.Changes[0] != want[0] {
+			t.Errorf("%s: got changes %v, want %v", repoName, entry.Changes, want)
+		}
+		if entry.Snapshot.Priority != 5 || entry.Snapshot.Title != "write report" || !time.Time(entry.Snapshot.DueDate).Equal(time.Time(after.DueDate)) {
+			t.Errorf("%s: got snapshot %v", repoName, entry.Snapshot)
+		}
+
+		from := changed.Add(time.Hour)
+		for name, c := range map[string]struct {
+			q    AuditQuery
+			want int
+		}{
+			"all":  {AuditQuery{}, 2},
+			"task": {AuditQuery{TaskID: 1}, 1},
+			"user": {AuditQuery{Username: "adi"}, 1},
+			"from": {AuditQuery{From: &from}, 1},
+		} {
+			log, err := repo.GetAuditLog(ctx, c.q)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", repoName, name, err)
+			}
+			if len(log) != c.want {
+				t.Errorf("%s/%s: got %d entries, want %d", repoName, name, len(log), c.want)
+			}
+		}
+	}
+}
+
+func TestTrash(t *testing.T) {
+	for repoName, repo := range testRepos(t) {
+		ctx := context.Background()
+		seedTasks(t, repo)
+
+		if err := repo.TrashTask(ctx, 2, time.Date(2030, 1, 2, 10, 0, 0, 0, time.UTC)); err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if err := repo.TrashTask(ctx, 2, time.Now()); err == nil {
+			t.Errorf("%s: expected an error when trashing a task twice", repoName)
+		}
+		if _, err := repo.GetTaskByID(ctx, 2); err == nil {
+			t.Errorf("%s: a task in the trash should not be found", repoName)
+		}
+		page, _ := repo.GetPaginatedTasks(ctx, Query{})
+		if got, want := titles(page.Tasks), []string{"write report", "buy milk", "call 100%", "fix bike"}; !equal(got, want) {
+			t.Errorf("%s: got %v, want %v", repoName, got, want)
+		}
+		if _, err := repo.AddTask(ctx, domain.Task{Rowid: 6, Title: "review report", DueDate: domain.Time(time.Now())}); err != nil {
+			t.Errorf("%s: title of a task in the trash should be free, got %v", repoName, err)
+		}
+
+		trash, err := repo.GetTrash(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if len(trash) != 1 || trash[0].Rowid != 2 || trash[0].DeletedAt == nil {
+			t.Errorf("%s: got trash %v", repoName, trash)
+		}
+
+		if err := repo.RestoreFromTrash(ctx, 2); err != errors.ErrorObjectAlreadyExists {
+			t.Errorf("%s: got %v when restoring a task whose title was taken", repoName, err)
+		}
+		// only the task which is not in the trash goes
+		if err := repo.DeleteTaskByTitle(ctx, "review report"); err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if err := repo.RestoreFromTrash(ctx, 2); err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if task, err := repo.GetTaskByID(ctx, 2); err != nil || task.DeletedAt != nil {
+			t.Errorf("%s: got %v, %v after restoring", repoName, task, err)
+		}
+		if err := repo.RestoreFromTrash(ctx, 2); err == nil {
+			t.Errorf("%s: expected an error when restoring a task which is not in the trash", repoName)
+		}
+	}
+}
+
+func TestDeleteTrashedParent(t *testing.T) {
+	for repoName, repo := range testRepos(t) {
+		ctx := context.Background()
+		due := domain.Time(time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC))
+		parentID := int64(1)
+		for _, task := range []domain.Task{
+			{Rowid: 1, Title: "parent", Status: domain.Pending, DueDate: due},
+			{Rowid: 2, Title: "child", Status: domain.Pending, DueDate: due, ParentID: &parentID},
+		} {
+			if _, err := repo.AddTask(ctx, task); err != nil {
+				t.Fatalf("%s: %v", repoName, err)
+			}
+		}
+		for _, id := range []int64{2, 1} {
+			if err := repo.TrashTask(ctx, id, time.Now()); err != nil {
+				t.Fatalf("%s: %v", repoName, err)
+			}
+		}
+
+		// the parent goes first, and its child in the trash is left without a parent
+		if err := repo.DeleteTask(ctx, 1); err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if child, err := repo.GetTrashedTask(ctx, 2); err != nil || child.ParentID != nil {
+			t.Errorf("%s: got %+v, %v, want the child without a parent", repoName, child, err)
+		}
+		if err := repo.RestoreFromTrash(ctx, 2); err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if child, err := repo.GetTaskByID(ctx, 2); err != nil || child.ParentID != nil {
+			t.Errorf("%s: got %+v, %v after restoring the child", repoName, child, err)
+		}
+		if err := repo.DeleteTask(ctx, 2); err != nil {
+			t.Errorf("%s: %v", repoName, err)
+		}
+	}
+}
+
+func TestWithTransaction(t *testing.T) {
+	for repoName, repo := range testRepos(t) {
+		ctx := context.Background()
+		seedTasks(t, repo)
+
+		failed := fmt.Errorf("failed")
+		err := repo.WithTransaction(ctx, func(tx ITaskRepo) error {
+			if _, err := tx.AddTask(ctx, domain.Task{Rowid: 6, Title: "paint fence", DueDate: domain.Time(time.Now())}); err != nil {
+				return err
+			}
+			if err := tx.DeleteTask(ctx, 1); err != nil {
+				return err
+			}
+			return failed
+		})
+		if err != failed {
+			t.Errorf("%s: got %v, want %v", repoName, err, failed)
+		}
+		if _, err := repo.GetTaskByID(ctx, 6); err == nil {
+			t.Errorf("%s: a task added in a rolled back transaction should not be found", repoName)
+		}
+		if _, err := repo.GetTaskByID(ctx, 1); err != nil {
+			t.Errorf("%s: a task deleted in a rolled back transaction should be found, got %v", repoName, err)
+		}
+
+		err = repo.WithTransaction(ctx, func(tx ITaskRepo) error {
+			_, err := tx.AddTask(ctx, domain.Task{Rowid: 6, Title: "paint fence", DueDate: domain.Time(time.Now())})
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", repoName, err)
+		}
+		if _, err := repo.GetTaskByID(ctx, 6); err != nil {
+			t.Errorf("%s: a task added in a committed transaction should be found, got %v", repoName, err)
+		}
+	}
+}
+
+func TestUTCTimesMigration(t *testing.T) {
+	handler := db.NewSqliteHandler(":memory:", 0)
+	handler.Conn.SetMaxOpenConns(1)
+	ctx := context.Background()
+	first, _ := db.NewMigrator(handler, SqliteMigrations[:1])
+	if _, err := first.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// rows as the old build wrote them, and created left to CURRENT_TIMESTAMP
+	if _, err := handler.Execute(ctx, "INSERT INTO task (title, dueDate, status, priority) VALUES ('old', '2030-01-02 03:04:05', 'Pending', 1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	all, _ := db.NewMigrator(handler, SqliteMigrations)
+	if _, err := all.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var dueDate, created string
+	if err := handler.QueryRow(ctx, "SELECT dueDate, created FROM task").Scan(&dueDate, &created); err != nil {
+		t.Fatal(err)
+	}
+	if dueDate != "2030-01-02T03:04:05Z" || len(created) != len("2030-01-02T03:04:05Z") {
+		t.Errorf("got dueDate %s and created %s", dueDate, created)
+	}
+	task, err := newTaskRepoSqlite(handler).GetTaskByTitle(ctx, "old")
+	if err != nil || !time.Time(task.DueDate).Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("got %v, %v", task.DueDate, err)
+	}
+}
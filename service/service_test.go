@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"server/domain"
+	"server/repository/task"
+)
+
+func init() {
+	task.InitializeMockTaskRepo()
+}
+
+// newTestService is a task service on the mock repository, with the default workflow
+func newTestService() TaskServiceImpl {
+	return TaskServiceImpl{task.Repository(), domain.DefaultWorkflow, nil}
+}
+
+// debugContext makes the mock repository answer from debugMap. Tasks are put in its
+// tasksByID entry.
+func debugContext(debugMap map[string]interface{}, tasks ...domain.Task) context.Context {
+	if debugMap == nil {
+		debugMap = make(map[string]interface{})
+	}
+	byID := make(map[int64]domain.Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.Rowid] = t
+	}
+	debugMap["tasksByID"] = byID
+	return context.WithValue(context.Background(), task.Debug, debugMap)
+}
+
+// testTask is a pending task with an effort and, unless parentID is 0, a parent
+func testTask(id, parentID int64, effort time.Duration) domain.Task {
+	t := domain.Task{Rowid: id, Title: "task", Priority: 3, Status: domain.Pending, Effort: domain.Duration(effort), DueDate: domain.Time(time.Now().Add(time.Hour))}
+	if parentID != 0 {
+		t.ParentID = &parentID
+	}
+	return t
+}
+
+// stored is the task with id, as the mock repository holds it
+func stored(ctx context.Context, id int64) domain.Task {
+	t, _ := task.Repository().GetTaskByID(ctx, id)
+	return t
+}
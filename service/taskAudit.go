@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+	"server/repository/task"
+)
+
+// GetAuditLog gets the revisions of tasks matching the filters of the request, oldest first
+func (ts TaskServiceImpl) GetAuditLog(ctx context.Context, r api.GetAuditLogRequest) api.GetAuditLogResponse {
+	q := task.AuditQuery{Username: r.User}
+	if r.TaskID != "" {
+		q.TaskID, _ = strconv.ParseInt(r.TaskID, 10, 64)
+	}
+	if r.From != "" {
+		from, _ := domain.ParseTime(r.From, time.Now())
+		q.From = &from
+	}
+	if r.To != "" {
+		to, _ := domain.ParseTime(r.To, time.Now())
+		q.To = &to
+	}
+	if r.Limit != "" {
+		q.Limit, _ = strconv.Atoi(r.Limit)
+	}
+
+	entries, err := ts.repo.GetAuditLog(ctx, q)
+	if err != nil {
+		return api.GetAuditLogResponse{Response: api.NewErrorResponse(err), Entries: []domain.AuditEntry{}}
+	}
+	return api.GetAuditLogResponse{Response: api.NewStdResponse(), Entries: entries}
+}
+
+// RestoreTask takes a task out of the trash when the request has no revision. Otherwise it
+// brings the task back to the state it had at that revision of its audit log, taking it out of
+// the trash if needed. Tags are left as they are, except for a purged task, which is added back
+// with its old id and the tags of the revision.
+func (ts TaskServiceImpl) RestoreTask(ctx context.Context, id int64, r api.RestoreTaskRequest) api.Response {
+	if r.Revision == 0 {
+		return ts.restoreFromTrash(ctx, id)
+	}
+
+	entry, err := ts.repo.GetAuditEntry(ctx, r.Revision)
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	if entry.TaskID != id {
+		return api.NewErrorResponse(errors.ErrorInvalidArgument.WithMessage("Revision %d is not a revision of task %d", r.Revision, id).WithField("revision"))
+	}
+	restored := domain.Task(entry.Snapshot)
+	restored.Rowid = id
+	restored.DeletedAt = nil
+
+	current, err := ts.repo.GetTaskByID(ctx, id)
+	inTrash := false
+	if err != nil {
+		trashed, ok, trashErr := ts.trashedTask(ctx, id)
+		if trashErr != nil {
+			return api.NewErrorResponse(trashErr)
+		}
+		if !ok {
+			return ts.restorePurged(ctx, restored, err)
+		}
+		if err := ts.checkParent(ctx, restored); err != nil {
+			return api.NewErrorResponse(err)
+		}
+		current, inTrash = trashed, true
+	}
+
+	restored.Created = current.Created
+	restored.Tags = current.Tags
+	err = ts.inTransaction(ctx, func(tx TaskServiceImpl) error {
+		if inTrash {
+			if err := tx.repo.RestoreFromTrash(ctx, id); err != nil {
+				return titleConflict(err, current.Title)
+			}
+		}
+		return tx.saveTask(ctx, current, restored, domain.AuditRestore)
+	})
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// restorePurged adds a task, which was deleted and purged from the trash, back with its old id.
+// notFound is returned if the task was never deleted.
+func (ts TaskServiceImpl) restorePurged(ctx context.Context, restored domain.Task, notFound error) api.Response {
+	entries, err := ts.repo.GetAuditLog(ctx, task.AuditQuery{TaskID: restored.Rowid})
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	if len(entries) == 0 || entries[len(entries)-1].Action != domain.AuditDelete {
+		return api.NewErrorResponse(notFound)
+	}
+
+	if err := ts.checkParent(ctx, restored); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	err = ts.inTransaction(ctx, func(tx TaskServiceImpl) error {
+		if _, err := tx.repo.AddTask(ctx, restored); err != nil {
+			return err
+		}
+		if err := tx.audit(ctx, domain.AuditRestore, nil, &restored); err != nil {
+			return err
+		}
+		return tx.rollUpEffort(ctx, restored.ParentID)
+	})
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// audit records a revision of a task, made by the user of ctx, queues its events for the
+// webhooks and publishes it. It is called in the transaction of the change. old is nil for a new
+// or restored task, and task is nil for a deleted one. Updates which change nothing are not
+// recorded.
+func (ts TaskServiceImpl) audit(ctx context.Context, action domain.AuditAction, old, task *domain.Task) error {
+	changes := domain.Diff(old, task)
+	if len(changes) == 0 && (action == domain.AuditUpdate || action == domain.AuditReopen) {
+		return nil
+	}
+
+	snapshot := task
+	if snapshot == nil {
+		snapshot = old
+	}
+	entry := domain.AuditEntry{
+		TaskID:   snapshot.Rowid,
+		Action:   action,
+		Changes:  changes,
+		Snapshot: domain.Snapshot(*snapshot),
+		Changed:  domain.Time(time.Now()),
+		Username: api.Username(ctx),
+	}
+	if err := ts.repo.AddAuditEntry(ctx, entry); err != nil {
+		return err
+	}
+	if err := ts.queueDeliveries(ctx, old, task, entry); err != nil {
+		return err
+	}
+	ts.publish(old, task, entry)
+	return nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"server/api"
+	"server/errors"
+)
+
+// BulkTasks runs the operations of the request in order, in one transaction. If an operation
+// fails, the transaction is rolled back, and the operations after it are not run. The events of
+// the operations are only published once the transaction is committed.
+func (ts TaskServiceImpl) BulkTasks(ctx context.Context, r api.BulkTasksRequest) api.BulkTasksResponse {
+	results := make([]api.BulkResult, 0, len(r.Operations))
+	err := ts.inTransaction(ctx, func(tx TaskServiceImpl) error {
+		for i, op := range r.Operations {
+			result := tx.bulkOperation(ctx, op)
+			results = append(results, result)
+			if !result.Success() {
+				// the request fails with the error of the operation
+				failed := result.GetErrors()[0]
+				failed.Message = fmt.Sprintf("Operation %d failed, no operation was applied: %s", i, failed.Message)
+				return failed
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		for _, op := range r.Operations[len(results):] {
+			notRun := api.NewErrorResponse(errors.ErrorConflict.WithMessage("Not run, an earlier operation failed"))
+			results = append(results, api.BulkResult{Response: notRun, Op: op.Op, TaskID: op.ID})
+		}
+		return api.BulkTasksResponse{Response: api.NewErrorResponse(err), Results: results}
+	}
+	for i := range results {
+		results[i].Applied = true
+	}
+	return api.BulkTasksResponse{Response: api.NewStdResponse(), Results: results}
+}
+
+// bulkOperation runs a single operation of a bulk request
+func (ts TaskServiceImpl) bulkOperation(ctx context.Context, op api.BulkOperation) api.BulkResult {
+	result := api.BulkResult{Op: op.Op, TaskID: op.ID}
+	switch op.Op {
+	case api.BulkCreate:
+		resp := ts.CreateTask(ctx, *op.Create)
+		result.Response, result.TaskID = resp.Response, resp.TaskID
+	case api.BulkUpdate:
+		result.Response = ts.UpdateTaskByID(ctx, op.ID, *op.Update)
+	case api.BulkDelete:
+		result.Response = ts.DeleteTaskByID(ctx, op.ID)
+	default:
+		result.Response = api.NewErrorResponse(errors.ErrorInvalidArgument.WithMessage("Unknown operation %s", op.Op).WithField("op"))
+	}
+	return result
+}
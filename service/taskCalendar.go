@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+	"server/repository/task"
+)
+
+// CreateCalendarFeed makes a calendar feed, read with a token made up by the server
+func (ts TaskServiceImpl) CreateCalendarFeed(ctx context.Context, r api.CreateCalendarFeedRequest) api.CreateCalendarFeedResponse {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return api.CreateCalendarFeedResponse{Response: api.NewErrorResponse(err), FeedID: -1}
+	}
+	token := hex.EncodeToString(b)
+
+	feed := domain.CalendarFeed{Name: r.Name, TokenHash: domain.HashToken(token), Created: domain.Time(time.Now())}
+	id, err := ts.repo.AddCalendarFeed(ctx, feed)
+	if err != nil {
+		return api.CreateCalendarFeedResponse{Response: api.NewErrorResponse(err), FeedID: -1}
+	}
+	return api.CreateCalendarFeedResponse{Response: api.NewStdResponse(), FeedID: id, Token: token}
+}
+
+// GetCalendarFeeds lists the calendar feeds, without their tokens
+func (ts TaskServiceImpl) GetCalendarFeeds(ctx context.Context) api.GetCalendarFeedsResponse {
+	feeds, err := ts.repo.GetCalendarFeeds(ctx)
+	if err != nil {
+		return api.GetCalendarFeedsResponse{Response: api.NewErrorResponse(err), Feeds: []domain.CalendarFeed{}}
+	}
+	return api.GetCalendarFeedsResponse{Response: api.NewStdResponse(), Feeds: feeds}
+}
+
+// DeleteCalendarFeed deletes a calendar feed, its token stops working
+func (ts TaskServiceImpl) DeleteCalendarFeed(ctx context.Context, id int64) api.Response {
+	if err := ts.repo.DeleteCalendarFeed(ctx, id); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// GetCalendar gets the tasks of the calendar feed of the token of the request which are not
+// done, and match its filters, by due date
+func (ts TaskServiceImpl) GetCalendar(ctx context.Context, r api.GetCalendarRequest) api.GetCalendarResponse {
+	feed, err := ts.repo.GetCalendarFeedByToken(ctx, domain.HashToken(r.Token))
+	if err == errors.ErrorObjectNotFound {
+		err = errors.ErrorObjectNotFound.WithMessage("Unknown calendar feed").WithField("token")
+	}
+	if err != nil {
+		return api.GetCalendarResponse{Response: api.NewErrorResponse(err), Tasks: []domain.Task{}}
+	}
+
+	q := taskQuery(r.Filter)
+	q.SortBy = task.SortByDueDate
+	page, err := ts.repo.GetPaginatedTasks(ctx, q)
+	if err != nil {
+		return api.GetCalendarResponse{Response: api.NewErrorResponse(err), Tasks: []domain.Task{}}
+	}
+	tasks := make([]domain.Task, 0, len(page.Tasks))
+	for _, t := range page.Tasks {
+		if t.Status != domain.Done {
+			tasks = append(tasks, t)
+		}
+	}
+	return api.GetCalendarResponse{Response: api.NewStdResponse(), Feed: feed, Tasks: tasks}
+}
+
+// ImportCalendar imports the to-dos of a calendar in one transaction, like a bulk request. A to-do
+// is created as a task, unless its uid was imported before: then the title, description, due date
+// and priority of its task are updated, or it is left out if the task is in the trash. A uid
+// which comes again in the same calendar is only imported the first time. The to-dos which the
+// request left out are listed in the response.
+func (ts TaskServiceImpl) ImportCalendar(ctx context.Context, r api.ImportCalendarRequest) api.ImportCalendarResponse {
+	resp := api.BulkTasksResponse{Results: []api.BulkResult{}}
+	err := ts.inTransaction(ctx, func(tx TaskServiceImpl) error {
+		ops := make([]api.BulkOperation, 0, len(r.Tasks))
+		uids := make([]string, 0, len(r.Tasks))
+		seen := make(map[string]bool, len(r.Tasks))
+		for i := range r.Tasks {
+			uid := r.UIDs[i]
+			if uid != "" && seen[uid] {
+				continue
+			}
+			seen[uid] = true
+			op, err := tx.importOperation(ctx, uid, &r.Tasks[i])
+			if err != nil {
+				return err
+			}
+			if op != nil {
+				ops = append(ops, *op)
+				uids = append(uids, uid)
+			}
+		}
+
+		resp = tx.BulkTasks(ctx, api.BulkTasksRequest{Operations: ops})
+		if !resp.Success() {
+			return resp.GetErrors()[0]
+		}
+		for i, result := range resp.Results {
+			if result.Op != api.BulkCreate || uids[i] == "" {
+				continue
+			}
+			if err := tx.repo.AddImportedTodo(ctx, domain.ImportedTodo{UID: uids[i], TaskID: result.TaskID}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil && (resp.Response == nil || resp.Success()) {
+		// the to-dos could not be looked up, or their uids recorded, so nothing was applied
+		for i := range resp.Results {
+			resp.Results[i].Applied = false
+		}
+		resp.Response = api.NewErrorResponse(err)
+	}
+	return api.ImportCalendarResponse{BulkTasksResponse: resp, Skipped: r.Skipped}
+}
+
+// importOperation is the bulk operation of an imported to-do with uid: the create of a new task,
+// or the update of the task the to-do was imported as. It is nil when that task is in the trash.
+func (ts TaskServiceImpl) importOperation(ctx context.Context, uid string, create *api.CreateTaskRequest) (*api.BulkOperation, error) {
+	createOp := &api.BulkOperation{Op: api.BulkCreate, Create: create}
+	if uid == "" {
+		return createOp, nil
+	}
+	imported, err := ts.repo.GetImportedTodo(ctx, uid)
+	if errors.Is(err, errors.ErrorObjectNotFound) {
+		return createOp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ts.repo.GetTaskByID(ctx, imported.TaskID); err == nil {
+		update := api.UpdateTaskRequest{Title: create.Title, Description: create.Description, DueDate: create.DueDate, Priority: create.Priority}
+		return &api.BulkOperation{Op: api.BulkUpdate, ID: imported.TaskID, Update: &update}, nil
+	} else if !errors.Is(err, errors.ErrorObjectNotFound) {
+		return nil, err
+	}
+	// the task is in the trash, as purging a task forgets its uid
+	return nil, nil
+}
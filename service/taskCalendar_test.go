@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+	"server/ical"
+	"server/repository/task"
+)
+
+// importRequest is the import request of the to-dos of a calendar, given as "uid summary" lines.
+// A summary ending with "done" is a completed to-do, one ending with "someday" has no due date,
+// and one ending with "overdue" was due in 2020.
+func importRequest(t *testing.T, todos ...string) api.ImportCalendarRequest {
+	cal := "BEGIN:VCALENDAR\r\n"
+	for _, todo := range todos {
+		parts := strings.SplitN(todo, " ", 2)
+		cal += "BEGIN:VTODO\r\nUID:" + parts[0] + "\r\nSUMMARY:" + parts[1] + "\r\n"
+		switch {
+		case strings.HasSuffix(parts[1], "overdue"):
+			cal += "DUE:20200102T150000Z\r\n"
+		case !strings.HasSuffix(parts[1], "someday"):
+			cal += "DUE:20300102T150000Z\r\n"
+		}
+		if strings.HasSuffix(parts[1], "done") {
+			cal += "STATUS:COMPLETED\r\n"
+		}
+		cal += "END:VTODO\r\n"
+	}
+	cal += "END:VCALENDAR\r\n"
+	parsed, err := ical.ParseTodos(strings.NewReader(cal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := api.ImportCalendarRequest{Todos: parsed}
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestImportCalendar(t *testing.T) {
+	ctx := context.Background()
+	repo := task.NewInMemoryTaskRepo()
+	ts := TaskServiceImpl{repo, domain.DefaultWorkflow, nil}
+
+	resp := ts.ImportCalendar(ctx, importRequest(t, "a water plants", "b feed cat", "a water plants again", "c buy milk done"))
+	if !resp.Success() || len(resp.Results) != 2 {
+		t.Fatalf("got %v, want the first of each uid created", resp)
+	}
+	plants, cat := resp.Results[0].TaskID, resp.Results[1].TaskID
+
+	// importing the calendar again updates the tasks rather than making new ones
+	resp = ts.ImportCalendar(ctx, importRequest(t, "a water all plants", "b feed cat", "d walk dog"))
+	if !resp.Success() || len(resp.Results) != 3 {
+		t.Fatalf("got %v", resp)
+	}
+	if r := resp.Results[0]; r.Op != api.BulkUpdate || r.TaskID != plants || !r.Applied {
+		t.Errorf("got %+v, want task %d updated", r, plants)
+	}
+	if r := resp.Results[2]; r.Op != api.BulkCreate {
+		t.Errorf("got %+v, want a new task for a new uid", r)
+	}
+	if stored, _ := repo.GetTaskByID(ctx, plants); stored.Title != "water all plants" {
+		t.Errorf("got %v, want the title of the to-do", stored)
+	}
+	if all, _ := repo.GetAllTasks(ctx); len(all) != 3 {
+		t.Errorf("got %d tasks, want 3", len(all))
+	}
+
+	// a to-do whose task is in the trash is left out
+	if resp := ts.DeleteTaskByID(ctx, cat); !resp.Success() {
+		t.Fatalf("got %v", resp)
+	}
+	resp = ts.ImportCalendar(ctx, importRequest(t, "b feed cat", "a water all plants"))
+	if !resp.Success() || len(resp.Results) != 1 || resp.Results[0].TaskID != plants {
+		t.Errorf("got %v, want task %d only", resp, plants)
+	}
+	if all, _ := repo.GetAllTasks(ctx); len(all) != 2 {
+		t.Errorf("got %d tasks, want the one in the trash not made again", len(all))
+	}
+
+	// a failed import records no uid
+	resp = ts.ImportCalendar(ctx, importRequest(t, "e walk dog", "f feed fish"))
+	if resp.Success() || resp.Results[0].Applied {
+		t.Fatalf("got %v, want the import to fail on the title which is taken", resp)
+	}
+	if _, err := repo.GetImportedTodo(ctx, "e"); err != errors.ErrorObjectNotFound {
+		t.Errorf("got %v, want the uid of the rolled back task forgotten", err)
+	}
+}
+
+func TestImportCalendarDueDates(t *testing.T) {
+	ctx := context.Background()
+	repo := task.NewInMemoryTaskRepo()
+	ts := TaskServiceImpl{repo, domain.DefaultWorkflow, nil}
+
+	resp := ts.ImportCalendar(ctx, importRequest(t, "a pay rent overdue", "b paint fence someday", "c call mum"))
+	if !resp.Success() || len(resp.Results) != 2 {
+		t.Fatalf("got %v, want the to-dos with a due date imported", resp)
+	}
+	if len(resp.Skipped) != 1 || resp.Skipped[0].Index != 1 || resp.Skipped[0].UID != "b" {
+		t.Errorf("got %v skipped, want the to-do without a due date", resp.Skipped)
+	}
+	rent, _ := repo.GetTaskByID(ctx, resp.Results[0].TaskID)
+	if due := time.Time(rent.DueDate); !due.Equal(time.Date(2020, 1, 2, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %v, want the overdue to-do due when it was", due)
+	}
+}
+
+func TestGetCalendar(t *testing.T) {
+	ctx := context.Background()
+	repo := task.NewInMemoryTaskRepo()
+	ts := TaskServiceImpl{repo, domain.DefaultWorkflow, nil}
+	due := time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)
+	tasks := []domain.Task{
+		{Title: "later", Status: domain.Pending, DueDate: domain.Time(due.Add(time.Hour)), Tags: []string{"work"}},
+		{Title: "done", Status: domain.Done, DueDate: domain.Time(due)},
+		{Title: "sooner", Status: domain.InProgress, DueDate: domain.Time(due), Tags: []string{"work"}},
+		{Title: "home", Status: domain.Pending, DueDate: domain.Time(due.Add(30 * time.Minute))},
+	}
+	for _, t := range tasks {
+		if _, err := repo.AddTask(ctx, t); err != nil {
+			panic(err)
+		}
+	}
+	feed := ts.CreateCalendarFeed(ctx, api.CreateCalendarFeedRequest{Name: "phone"})
+	if !feed.Success() || feed.Token == "" {
+		t.Fatalf("got %v", feed)
+	}
+
+	get := func(query string) api.GetCalendarResponse {
+		values, _ := url.ParseQuery(query)
+		r := api.NewGetCalendarRequest(values)
+		if err := r.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		return ts.GetCalendar(ctx, r)
+	}
+	resp := get("token=" + feed.Token)
+	if !resp.Success() || resp.Feed.Rowid != feed.FeedID || strings.Join(taskTitles(resp.Tasks), ",") != "sooner,home,later" {
+		t.Errorf("got %v with tasks %v, want the tasks which are not done by due date", resp, taskTitles(resp.Tasks))
+	}
+	if resp := get("tag=work&token=" + feed.Token); strings.Join(taskTitles(resp.Tasks), ",") != "sooner,later" {
+		t.Errorf("got tasks %v, want the ones tagged work", taskTitles(resp.Tasks))
+	}
+
+	resp = get("token=unknown")
+	if resp.Success() || resp.GetErrors()[0].Code != errors.ErrorObjectNotFound.StringCode() || resp.GetErrors()[0].Field != "token" {
+		t.Errorf("got %v, want an unknown token", resp)
+	}
+	if resp := ts.DeleteCalendarFeed(ctx, feed.FeedID); !resp.Success() {
+		t.Fatalf("got %v", resp)
+	}
+	if resp := get("token=" + feed.Token); resp.Success() {
+		t.Errorf("got %v, want the token of a deleted feed refused", resp)
+	}
+}
+
+// taskTitles are the titles of tasks, in order
+func taskTitles(tasks []domain.Task) []string {
+	titles := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		titles = append(titles, t.Title)
+	}
+	return titles
+}
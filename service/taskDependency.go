@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+)
+
+// AddDependency marks a task as blocked by another task. Dependencies which would make
+// a cycle are rejected, since none of the tasks in the cycle could ever be started.
+func (ts TaskServiceImpl) AddDependency(ctx context.Context, id int64, r api.AddDependencyRequest) api.Response {
+	if id == r.BlockedBy {
+		return api.NewErrorResponse(errors.ErrorInvalidArgument.WithMessage("Task %d cannot be blocked by itself", id).WithField("blockedBy"))
+	}
+	if _, err := ts.repo.GetTaskByID(ctx, id); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	if _, err := ts.repo.GetTaskByID(ctx, r.BlockedBy); err != nil {
+		return api.NewErrorResponse(errors.ErrorInvalidArgument.WithMessage("Blocking task %d not found", r.BlockedBy).WithField("blockedBy"))
+	}
+
+	dependencies, err := ts.repo.GetAllDependencies(ctx)
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	if isBlockedBy(dependencies, r.BlockedBy, id) {
+		return api.NewErrorResponse(errors.ErrorConflict.WithMessage("Task %d is already blocked by task %d, adding this dependency would create a cycle", r.BlockedBy, id))
+	}
+
+	if err := ts.repo.AddDependency(ctx, domain.Dependency{TaskID: id, BlockedBy: r.BlockedBy}); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// RemoveDependency removes a dependency between two tasks
+func (ts TaskServiceImpl) RemoveDependency(ctx context.Context, id int64, blockedBy int64) api.Response {
+	if err := ts.repo.RemoveDependency(ctx, domain.Dependency{TaskID: id, BlockedBy: blockedBy}); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// GetBlockers gets the tasks which block a task, and whether it is still blocked
+func (ts TaskServiceImpl) GetBlockers(ctx context.Context, id int64) api.GetBlockersResponse {
+	if _, err := ts.repo.GetTaskByID(ctx, id); err != nil {
+		return api.GetBlockersResponse{Response: api.NewErrorResponse(err), Blockers: []domain.Task{}}
+	}
+	blockers, err := ts.repo.GetBlockers(ctx, id)
+	if err != nil {
+		return api.GetBlockersResponse{Response: api.NewErrorResponse(err), Blockers: []domain.Task{}}
+	}
+	return api.GetBlockersResponse{Response: api.NewStdResponse(), Blockers: blockers, Blocked: len(pendingBlockers(blockers)) > 0}
+}
+
+// GetTasksInDependencyOrder lists all tasks so that every task comes after the tasks
+// blocking it. Tasks which are free to go are ordered by id.
+func (ts TaskServiceImpl) GetTasksInDependencyOrder(ctx context.Context) api.GetBulkTasksResponse {
+	tasks, err := ts.repo.GetAllTasks(ctx)
+	if err != nil {
+		return api.GetBulkTasksResponse{Response: api.NewErrorResponse(err), Tasks: []domain.Task{}}
+	}
+	dependencies, err := ts.repo.GetAllDependencies(ctx)
+	if err != nil {
+		return api.GetBulkTasksResponse{Response: api.NewErrorResponse(err), Tasks: []domain.Task{}}
+	}
+
+	ordered, err := topologicalSort(tasks, dependencies)
+	if err != nil {
+		return api.GetBulkTasksResponse{Response: api.NewErrorResponse(err), Tasks: []domain.Task{}}
+	}
+	return api.GetBulkTasksResponse{Response: api.NewStdResponse(), Tasks: ordered, Total: int64(len(ordered))}
+}
+
+// checkBlockers ensures that a task only moves to domain.InProgress, or straight to
+// domain.Done, once all of its blockers are done.
+func (ts TaskServiceImpl) checkBlockers(ctx context.Context, old, task domain.Task) error {
+	if task.Status == old.Status || (task.Status != domain.InProgress && task.Status != domain.Done) {
+		return nil
+	}
+	if task.Status == domain.Done && old.Status == domain.InProgress {
+		// its blockers were done when it was started
+		return nil
+	}
+
+	blockers, err := ts.repo.GetBlockers(ctx, task.Rowid)
+	if err != nil {
+		return err
+	}
+	if pending := pendingBlockers(blockers); len(pending) > 0 {
+		verb := "start"
+		if task.Status == domain.Done {
+			verb = "complete"
+		}
+		return errors.ErrorConflict.WithMessage("Cannot %s task %d, it is blocked by task %d which is %s", verb, task.Rowid, pending[0].Rowid, pending[0].Status)
+	}
+	return nil
+}
+
+// pendingBlockers filters out blockers which are done
+func pendingBlockers(blockers []domain.Task) []domain.Task {
+	pending := make([]domain.Task, 0)
+	for _, b := range blockers {
+		if b.Status != domain.Done {
+			pending = append(pending, b)
+		}
+	}
+	return pending
+}
+
+// isBlockedBy checks whether task is blocked by blocker, directly or through other tasks
+func isBlockedBy(dependencies []domain.Dependency, task, blocker int64) bool {
+	blockedBy := make(map[int64][]int64)
+	for _, d := range dependencies {
+		blockedBy[d.TaskID] = append(blockedBy[d.TaskID], d.BlockedBy)
+	}
+
+	visited := make(map[int64]bool)
+	stack := []int64{task}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == blocker {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, blockedBy[current]...)
+	}
+	return false
+}
+
+// topologicalSort orders tasks with Kahn's algorithm, picking the lowest id whenever
+// more than one task is free.
+func topologicalSort(tasks []domain.Task, dependencies []domain.Dependency) ([]domain.Task, error) {
+	byID := make(map[int64]domain.Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.Rowid] = t
+	}
+
+	inDegree := make(map[int64]int, len(tasks))
+	unblocks := make(map[int64][]int64)
+	for _, d := range dependencies {
+		if _, ok := byID[d.TaskID]; !ok {
+			continue
+		}
+		if _, ok := byID[d.BlockedBy]; !ok {
+			continue
+		}
+		inDegree[d.TaskID]++
+		unblocks[d.BlockedBy] = append(unblocks[d.BlockedBy], d.TaskID)
+	}
+
+	free := make([]int64, 0)
+	for id := range byID {
+		if inDegree[id] == 0 {
+			free = append(free, id)
+		}
+	}
+
+	ordered := make([]domain.Task, 0, len(tasks))
+	for len(free) > 0 {
+		sort.Slice(free, func(i, j int) bool { return free[i] < free[j] })
+		id := free[0]
+		free = free[1:]
+		ordered = append(ordered, byID[id])
+		for _, next := range unblocks[id] {
+			inDegree[next]--
+			if inDegree[next] == 0 {
+				free = append(free, next)
+			}
+		}
+	}
+
+	if len(ordered) != len(byID) {
+		return ordered, errors.ErrorConflict.WithMessage("Dependencies between tasks have a cycle")
+	}
+	return ordered, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+)
+
+func TestAddDependency(t *testing.T) {
+	ts := newTestService()
+	tasks := []domain.Task{testTask(1, 0, time.Hour), testTask(2, 0, time.Hour), testTask(3, 0, time.Hour)}
+	cases := []struct {
+		name          string
+		dependencies  []domain.Dependency
+		id, blockedBy int64
+		code          string
+	}{
+		{"blocked by itself", nil, 1, 1, errors.ErrorInvalidArgument.StringCode()},
+		{"unknown blocker", nil, 1, 9, errors.ErrorInvalidArgument.StringCode()},
+		{"unknown task", nil, 9, 1, errors.ErrorObjectNotFound.StringCode()},
+		{"direct cycle", []domain.Dependency{{TaskID: 2, BlockedBy: 1}}, 1, 2, errors.ErrorConflict.StringCode()},
+		{"indirect cycle", []domain.Dependency{{TaskID: 2, BlockedBy: 1}, {TaskID: 3, BlockedBy: 2}}, 1, 3, errors.ErrorConflict.StringCode()},
+		{"no cycle", []domain.Dependency{{TaskID: 2, BlockedBy: 1}, {TaskID: 3, BlockedBy: 2}}, 3, 1, ""},
+	}
+	for _, c := range cases {
+		ctx := debugContext(map[string]interface{}{"dependencies": c.dependencies}, tasks...)
+		resp := ts.AddDependency(ctx, c.id, api.AddDependencyRequest{BlockedBy: c.blockedBy})
+		if c.code == "" {
+			if !resp.Success() {
+				t.Errorf("%s: got %v", c.name, resp)
+			}
+			continue
+		}
+		if resp.Success() || resp.GetErrors()[0].Code != c.code {
+			t.Errorf("%s: got %v, want %s", c.name, resp, c.code)
+		}
+	}
+}
+
+func TestCheckBlockers(t *testing.T) {
+	ts := newTestService()
+	pending := testTask(2, 0, time.Hour)
+	done := pending
+	done.Status = domain.Done
+	started := testTask(1, 0, time.Hour)
+	started.Status = domain.InProgress
+	cases := []struct {
+		name    string
+		task    domain.Task
+		blocker domain.Task
+		status  domain.Status
+		success bool
+	}{
+		{"starting a blocked task", testTask(1, 0, time.Hour), pending, domain.InProgress, false},
+		{"completing a blocked task", testTask(1, 0, time.Hour), pending, domain.Done, false},
+		{"starting an unblocked task", testTask(1, 0, time.Hour), done, domain.InProgress, true},
+		{"completing an unblocked task", testTask(1, 0, time.Hour), done, domain.Done, true},
+		{"completing a started task", started, pending, domain.Done, true},
+	}
+	for _, c := range cases {
+		ctx := debugContext(map[string]interface{}{"tasks": []domain.Task{c.blocker}}, c.task, c.blocker)
+		resp := ts.UpdateTaskByID(ctx, 1, api.UpdateTaskRequest{Status: string(c.status)})
+		if resp.Success() != c.success {
+			t.Errorf("%s: got %v", c.name, resp)
+		}
+		if !c.success && resp.GetErrors()[0].Code != errors.ErrorConflict.StringCode() {
+			t.Errorf("%s: got %v, want a conflict", c.name, resp)
+		}
+	}
+}
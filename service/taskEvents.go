@@ -0,0 +1,22 @@
+package service
+
+import (
+	"server/domain"
+	"server/events"
+)
+
+// publish publishes a change of a task, recorded in the audit log as entry. old is nil for a new
+// or restored task, and task is nil for a deleted one. Status changes are published as updates.
+func (ts TaskServiceImpl) publish(old, task *domain.Task, entry domain.AuditEntry) {
+	if ts.publisher == nil {
+		return
+	}
+	e := events.Event{Type: domain.EventTaskUpdated, TaskID: entry.TaskID, Task: domain.Task(entry.Snapshot), Time: entry.Changed}
+	switch {
+	case task == nil:
+		e.Type = domain.EventTaskDeleted
+	case old == nil:
+		e.Type = domain.EventTaskCreated
+	}
+	ts.publisher.Publish(e)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+)
+
+// GetChildTasks gets the direct subtasks of a task
+func (ts TaskServiceImpl) GetChildTasks(ctx context.Context, id int64) api.GetBulkTasksResponse {
+	if _, err := ts.repo.GetTaskByID(ctx, id); err != nil {
+		return api.GetBulkTasksResponse{Response: api.NewErrorResponse(err), Tasks: []domain.Task{}}
+	}
+	children, err := ts.repo.GetChildTasks(ctx, id)
+	if err != nil {
+		return api.GetBulkTasksResponse{Response: api.NewErrorResponse(err), Tasks: []domain.Task{}}
+	}
+	return api.GetBulkTasksResponse{Response: api.NewStdResponse(), Tasks: children, Total: int64(len(children))}
+}
+
+// GetSubtree gets a task along with all of its descendants, as a tree
+func (ts TaskServiceImpl) GetSubtree(ctx context.Context, id int64) api.GetTaskTreeResponse {
+	tasks, err := ts.repo.GetSubtree(ctx, id)
+	if err != nil {
+		return api.GetTaskTreeResponse{Response: api.NewErrorResponse(err)}
+	}
+	return api.GetTaskTreeResponse{Response: api.NewStdResponse(), Tree: buildTree(tasks)}
+}
+
+// buildTree arranges a flat list of tasks, with the root first, into a tree
+func buildTree(tasks []domain.Task) api.TaskTree {
+	children := make(map[int64][]domain.Task)
+	for _, t := range tasks[1:] {
+		if t.ParentID != nil {
+			children[*t.ParentID] = append(children[*t.ParentID], t)
+		}
+	}
+
+	var build func(t domain.Task) api.TaskTree
+	build = func(t domain.Task) api.TaskTree {
+		tree := api.TaskTree{Task: t, Children: make([]api.TaskTree, 0)}
+		for _, c := range children[t.Rowid] {
+			tree.Children = append(tree.Children, build(c))
+		}
+		return tree
+	}
+	return build(tasks[0])
+}
+
+// checkParent ensures that the parent of a task exists, and that the task is not
+// made a subtask of itself or of one of its own descendants.
+func (ts TaskServiceImpl) checkParent(ctx context.Context, task domain.Task) error {
+	if task.ParentID == nil {
+		return nil
+	}
+
+	visited := make(map[int64]bool)
+	for id := *task.ParentID; ; {
+		if id == task.Rowid {
+			return errors.ErrorInvalidArgument.WithMessage("Task %d cannot be a subtask of itself or of its subtasks", task.Rowid).WithField("parentId")
+		}
+		if visited[id] {
+			// already broken in the repository, nothing this update can do about it
+			return nil
+		}
+		visited[id] = true
+
+		parent, err := ts.repo.GetTaskByID(ctx, id)
+		if err != nil {
+			return errors.ErrorInvalidArgument.WithMessage("Parent task %d not found", id).WithField("parentId")
+		}
+		if parent.ParentID == nil {
+			return nil
+		}
+		id = *parent.ParentID
+	}
+}
+
+// checkChildrenDone ensures that a task only moves to domain.Done once none of its
+// subtasks are Pending or In-Progress.
+func (ts TaskServiceImpl) checkChildrenDone(ctx context.Context, old, task domain.Task) error {
+	if task.Status != domain.Done || old.Status == domain.Done {
+		return nil
+	}
+
+	children, err := ts.repo.GetChildTasks(ctx, task.Rowid)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		if c.Status == domain.Pending || c.Status == domain.InProgress {
+			return errors.ErrorConflict.WithMessage("Cannot mark task %d as %s, subtask %d is %s", task.Rowid, domain.Done, c.Rowid, c.Status)
+		}
+	}
+	return nil
+}
+
+// rollUpEffort sets the effort of a parent task to the sum of efforts of its subtasks,
+// and then does the same for every ancestor. A task without subtasks keeps its own effort.
+func (ts TaskServiceImpl) rollUpEffort(ctx context.Context, parentID *int64) error {
+	visited := make(map[int64]bool)
+	for parentID != nil && !visited[*parentID] {
+		visited[*parentID] = true
+
+		parent, err := ts.repo.GetTaskByID(ctx, *parentID)
+		if err != nil {
+			return err
+		}
+		children, err := ts.repo.GetChildTasks(ctx, parent.Rowid)
+		if err != nil {
+			return err
+		}
+		if len(children) == 0 {
+			return nil
+		}
+
+		var effort domain.Duration
+		for _, c := range children {
+			effort += c.Effort
+		}
+		if effort != parent.Effort {
+			old := parent
+			parent.Effort = effort
+			if err := ts.repo.UpdateTask(ctx, parent); err != nil {
+				return err
+			}
+			if err := ts.audit(ctx, domain.AuditUpdate, &old, &parent); err != nil {
+				return err
+			}
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+)
+
+func TestCheckParent(t *testing.T) {
+	ts := newTestService()
+	// 1 is the parent of 2, which is the parent of 3
+	tasks := []domain.Task{testTask(1, 0, time.Hour), testTask(2, 1, time.Hour), testTask(3, 2, time.Hour), testTask(4, 0, time.Hour)}
+	cases := []struct {
+		id, parentID int64
+		code         string
+	}{
+		{1, 1, errors.ErrorInvalidArgument.StringCode()},
+		{1, 2, errors.ErrorInvalidArgument.StringCode()},
+		{1, 3, errors.ErrorInvalidArgument.StringCode()},
+		{2, 99, errors.ErrorInvalidArgument.StringCode()},
+		{3, 4, ""},
+		{4, 3, ""},
+		{2, 4, ""},
+	}
+	for _, c := range cases {
+		ctx := debugContext(nil, tasks...)
+		resp := ts.UpdateTaskByID(ctx, c.id, api.UpdateTaskRequest{ParentID: c.parentID})
+		if c.code == "" {
+			if !resp.Success() || *stored(ctx, c.id).ParentID != c.parentID {
+				t.Errorf("moving %d under %d: got %v, want it moved", c.id, c.parentID, resp)
+			}
+			continue
+		}
+		if resp.Success() || resp.GetErrors()[0].Code != c.code || resp.GetErrors()[0].Field != "parentId" {
+			t.Errorf("moving %d under %d: got %v, want %s on parentId", c.id, c.parentID, resp, c.code)
+		}
+	}
+}
+
+func TestCheckChildrenDone(t *testing.T) {
+	ts := newTestService()
+	child := testTask(2, 1, time.Hour)
+	done := child
+	done.Status = domain.Done
+	cases := []struct {
+		child   domain.Task
+		success bool
+	}{
+		{child, false},
+		{done, true},
+	}
+	for _, c := range cases {
+		ctx := debugContext(nil, testTask(1, 0, time.Hour), c.child)
+		resp := ts.UpdateTaskByID(ctx, 1, api.UpdateTaskRequest{Status: string(domain.Done)})
+		if resp.Success() != c.success {
+			t.Errorf("with a %s subtask: got %v", c.child.Status, resp)
+		}
+		if !c.success && resp.GetErrors()[0].Code != errors.ErrorConflict.StringCode() {
+			t.Errorf("got %v, want a conflict", resp)
+		}
+	}
+}
+
+func TestRollUpEffort(t *testing.T) {
+	ts := newTestService()
+	// 1 is the parent of 2, which is the parent of 3 and 4. 5 has no subtasks.
+	tasks := func() []domain.Task {
+		return []domain.Task{testTask(1, 0, 3*time.Hour), testTask(2, 1, 3*time.Hour), testTask(3, 2, time.Hour), testTask(4, 2, 2*time.Hour), testTask(5, 0, 8*time.Hour)}
+	}
+	cases := []struct {
+		name    string
+		id      int64
+		r       api.UpdateTaskRequest
+		efforts map[int64]time.Duration
+	}{
+		{"effort of a subtask", 3, api.UpdateTaskRequest{Effort: "5h"}, map[int64]time.Duration{1: 7 * time.Hour, 2: 7 * time.Hour}},
+		{"reparenting to a leaf", 4, api.UpdateTaskRequest{ParentID: 5}, map[int64]time.Duration{1: time.Hour, 2: time.Hour, 5: 2 * time.Hour}},
+		{"reparenting to the top", 3, api.UpdateTaskRequest{ParentID: 1}, map[int64]time.Duration{1: 3 * time.Hour, 2: 2 * time.Hour}},
+	}
+	for _, c := range cases {
+		ctx := debugContext(nil, tasks()...)
+		if resp := ts.UpdateTaskByID(ctx, c.id, c.r); !resp.Success() {
+			t.Fatalf("%s: %v", c.name, resp)
+		}
+		for id, effort := range c.efforts {
+			if got := time.Duration(stored(ctx, id).Effort); got != effort {
+				t.Errorf("%s: got effort %s for task %d, want %s", c.name, got, id, effort)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"server/api"
+	"server/domain"
+)
+
+// ReopenTask moves a task back to an earlier status, which the workflow only allows as a reopen,
+// like Done to Pending
+func (ts TaskServiceImpl) ReopenTask(ctx context.Context, id int64, r api.ReopenTaskRequest) api.Response {
+	task, err := ts.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+
+	old := task
+	task.Status = domain.Status(r.Status)
+	if err := ts.saveTask(ctx, old, task, domain.AuditReopen); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// GetHistory gets the status transitions of a task, oldest first
+func (ts TaskServiceImpl) GetHistory(ctx context.Context, id int64) api.GetHistoryResponse {
+	if _, err := ts.repo.GetTaskByID(ctx, id); err != nil {
+		return api.GetHistoryResponse{Response: api.NewErrorResponse(err), History: []domain.HistoryEntry{}}
+	}
+	history, err := ts.repo.GetHistory(ctx, id)
+	if err != nil {
+		return api.GetHistoryResponse{Response: api.NewErrorResponse(err), History: []domain.HistoryEntry{}}
+	}
+	return api.GetHistoryResponse{Response: api.NewStdResponse(), History: history}
+}
+
+// recordTransition adds a history entry for a status change, made by the user of ctx
+func (ts TaskServiceImpl) recordTransition(ctx context.Context, taskID int64, from, to domain.Status) error {
+	if from == to {
+		return nil
+	}
+	entry := domain.HistoryEntry{
+		TaskID:    taskID,
+		OldStatus: from,
+		NewStatus: to,
+		Changed:   domain.Time(time.Now()),
+		Username:  api.Username(ctx),
+	}
+	return ts.repo.AddHistory(ctx, entry)
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"server/api"
+	"server/domain"
+)
+
+// GetCompletions gets the completed occurrences of a recurring task, oldest first
+func (ts TaskServiceImpl) GetCompletions(ctx context.Context, id int64) api.GetCompletionsResponse {
+	if _, err := ts.repo.GetTaskByID(ctx, id); err != nil {
+		return api.GetCompletionsResponse{Response: api.NewErrorResponse(err), Completions: []domain.Completion{}}
+	}
+	completions, err := ts.repo.GetCompletions(ctx, id)
+	if err != nil {
+		return api.GetCompletionsResponse{Response: api.NewErrorResponse(err), Completions: []domain.Completion{}}
+	}
+	return api.GetCompletionsResponse{Response: api.NewStdResponse(), Completions: completions}
+}
+
+// completeOccurrence handles a recurring task moving to domain.Done. The occurrence is recorded
+// as a completion, and the task goes back to domain.Pending with the due date of its next
+// occurrence. Occurrences which are already past are skipped. Once the recurrence has ended,
+// the task stays done.
+func (ts TaskServiceImpl) completeOccurrence(ctx context.Context, old domain.Task, task *domain.Task) error {
+	if task.Recurrence == nil || task.Status != domain.Done || old.Status == domain.Done {
+		return nil
+	}
+
+	now := time.Now()
+	completion := domain.Completion{TaskID: task.Rowid, DueDate: task.DueDate, Completed: domain.Time(now)}
+	if err := ts.repo.AddCompletion(ctx, completion); err != nil {
+		return err
+	}
+
+	// occurrences are counted in the timezone of the user, so that they keep their time of day
+	// across daylight saving changes
+	next, ok := task.Recurrence.Next(time.Time(task.DueDate).In(domain.Location()))
+	for ok && !next.After(now) {
+		next, ok = task.Recurrence.Next(next)
+	}
+	if !ok {
+		return nil
+	}
+	task.DueDate = domain.Time(next)
+	task.Status = domain.Pending
+	return ts.recordTransition(ctx, task.Rowid, domain.Done, domain.Pending)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"server/api"
+	"server/domain"
+	"server/notify"
+	"server/repository/task"
+)
+
+// SendReminders sends, through every notifier, the reminders of the tasks which are not done and
+// are coming due or overdue at now. Each task is reminded at its own lead times and at
+// defaultLeadTimes, and once more when it is due. A reminder is sent once per notifier and due
+// date, and a notifier which fails is tried again on the next call. The error of the first
+// failure is returned, once every reminder has been tried.
+func (ts TaskServiceImpl) SendReminders(ctx context.Context, now time.Time, notifiers []notify.Notifier, defaultLeadTimes []domain.Duration) api.Response {
+	dueBefore := now.Add(domain.MaxLeadTime)
+	page, err := ts.repo.GetPaginatedTasks(ctx, task.Query{Statuses: []domain.Status{domain.Pending, domain.InProgress}, DueBefore: &dueBefore})
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+
+	var failure error
+	for _, t := range page.Tasks {
+		if err := ts.remind(ctx, t, now, notifiers, defaultLeadTimes); err != nil && failure == nil {
+			failure = err
+		}
+	}
+	if failure != nil {
+		return api.NewErrorResponse(failure)
+	}
+	return api.NewStdResponse()
+}
+
+// remind sends the reminder of a task which is due at now through each notifier, unless it went
+// through that notifier already
+func (ts TaskServiceImpl) remind(ctx context.Context, t domain.Task, now time.Time, notifiers []notify.Notifier, defaultLeadTimes []domain.Duration) error {
+	leadTimes := domain.SortLeadTimes(append(append([]domain.Duration{}, t.Reminders...), defaultLeadTimes...))
+	sent, err := ts.repo.GetSentReminders(ctx, t.Rowid)
+	if err != nil {
+		return err
+	}
+
+	var failure error
+	for _, n := range notifiers {
+		sentBy := make([]domain.SentReminder, 0, len(sent))
+		for _, s := range sent {
+			if s.Notifier == n.Name() {
+				sentBy = append(sentBy, s)
+			}
+		}
+		leadTime, ok := domain.DueReminder(t, leadTimes, sentBy, now)
+		if !ok {
+			continue
+		}
+		if err := n.Notify(ctx, domain.Reminder{Task: t, LeadTime: leadTime}); err != nil {
+			log.Printf("Could not remind task %d through %s: %v", t.Rowid, n.Name(), err)
+			if failure == nil {
+				failure = err
+			}
+			continue
+		}
+		reminder := domain.SentReminder{TaskID: t.Rowid, DueDate: t.DueDate, LeadTime: leadTime, Notifier: n.Name(), Sent: domain.Time(now)}
+		if err := ts.repo.AddSentReminder(ctx, reminder); err != nil && failure == nil {
+			failure = err
+		}
+	}
+	return failure
+}
+
+// RemindEvery sends the reminders which are due once every interval, until ctx is done. It is
+// meant to be run in its own goroutine.
+func RemindEvery(ctx context.Context, ts ITaskService, interval time.Duration, notifiers []notify.Notifier, defaultLeadTimes []domain.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if resp := ts.SendReminders(ctx, time.Now(), notifiers, defaultLeadTimes); !resp.Success() {
+			log.Printf("Could not send reminders: %v", resp)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// leadTimes reads the lead times of reminders, which were validated already
+func leadTimes(reminders []string) []domain.Duration {
+	durations := make([]domain.Duration, 0, len(reminders))
+	for _, r := range reminders {
+		d, _ := time.ParseDuration(r)
+		durations = append(durations, domain.Duration(d))
+	}
+	return domain.SortLeadTimes(durations)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"server/domain"
+	"server/notify"
+	"server/repository/task"
+)
+
+// recordingNotifier records the reminders it is given, and fails while failing is set
+type recordingNotifier struct {
+	name      string
+	failing   bool
+	reminders []domain.Reminder
+}
+
+func (n *recordingNotifier) Name() string {
+	return n.name
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, reminder domain.Reminder) error {
+	if n.failing {
+		return fmt.Errorf("%s is down", n.name)
+	}
+	n.reminders = append(n.reminders, reminder)
+	return nil
+}
+
+func TestSendReminders(t *testing.T) {
+	ctx := context.Background()
+	repo := task.NewInMemoryTaskRepo()
+	ts := TaskServiceImpl{repo, domain.DefaultWorkflow, nil}
+	now := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+	tasks := []domain.Task{
+		{Title: "coming due", Status: domain.Pending, DueDate: domain.Time(now.Add(30 * time.Minute)), Reminders: []domain.Duration{domain.Duration(time.Hour)}},
+		{Title: "later", Status: domain.Pending, DueDate: domain.Time(now.Add(48 * time.Hour)), Reminders: []domain.Duration{domain.Duration(time.Hour)}},
+		{Title: "done", Status: domain.Done, DueDate: domain.Time(now.Add(30 * time.Minute)), Reminders: []domain.Duration{domain.Duration(time.Hour)}},
+	}
+	for _, t := range tasks {
+		if _, err := repo.AddTask(ctx, t); err != nil {
+			panic(err)
+		}
+	}
+
+	up := &recordingNotifier{name: "up"}
+	down := &recordingNotifier{name: "down", failing: true}
+	notifiers := []notify.Notifier{up, down}
+
+	if resp := ts.SendReminders(ctx, now, notifiers, nil); resp.Success() {
+		t.Error("expected the failure of a notifier to be reported")
+	}
+	if len(up.reminders) != 1 || up.reminders[0].Task.Title != "coming due" || time.Duration(up.reminders[0].LeadTime) != time.Hour {
+		t.Errorf("got %v, want the reminder of the task coming due", up.reminders)
+	}
+
+	// the notifier which failed gets the reminder on the next run, the other one not again
+	down.failing = false
+	if resp := ts.SendReminders(ctx, now.Add(time.Minute), notifiers, nil); !resp.Success() {
+		t.Errorf("got %v", resp)
+	}
+	if len(up.reminders) != 1 || len(down.reminders) != 1 {
+		t.Errorf("got %d and %d reminders, want 1 each", len(up.reminders), len(down.reminders))
+	}
+	if resp := ts.SendReminders(ctx, now.Add(2*time.Minute), notifiers, nil); !resp.Success() || len(up.reminders) != 1 || len(down.reminders) != 1 {
+		t.Errorf("got %v, with %d and %d reminders, want nothing sent twice", resp, len(up.reminders), len(down.reminders))
+	}
+	if sent, _ := repo.GetSentReminders(ctx, 1); len(sent) != 2 {
+		t.Errorf("got sent reminders %v, want one per notifier", sent)
+	}
+}
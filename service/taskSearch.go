@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"strconv"
+
+	"server/api"
+	"server/domain"
+	"server/repository/task"
+)
+
+// defaultSearchLimit is the number of results of a search which does not ask for a limit
+const defaultSearchLimit = 20
+
+// SearchTasks ranks tasks by how well their title and description match the words of a search
+func (ts TaskServiceImpl) SearchTasks(ctx context.Context, r api.SearchTasksRequest) api.SearchTasksResponse {
+	q := task.SearchQuery{Text: r.Q, Limit: defaultSearchLimit}
+	if r.Limit != "" {
+		q.Limit, _ = strconv.Atoi(r.Limit)
+	}
+
+	results, err := ts.repo.SearchTasks(ctx, q)
+	if err != nil {
+		return api.SearchTasksResponse{Response: api.NewErrorResponse(err), Results: []domain.SearchResult{}}
+	}
+	return api.SearchTasksResponse{Response: api.NewStdResponse(), Results: results}
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"log"
 	"server/errors"
+	"strconv"
 	"time"
 
 	"server/api"
 	"server/domain"
+	"server/events"
+	"server/notify"
 	"server/repository/task"
+	"server/validation"
 )
 
 var (
@@ -31,15 +35,51 @@ func init() {
 type ITaskService interface {
 	CreateTask(ctx context.Context, r api.CreateTaskRequest) api.CreateTaskResponse
 	GetTask(ctx context.Context, name string) api.GetTaskResponse
+	GetTaskByID(ctx context.Context, id int64) api.GetTaskResponse
 	GetAllTasks(ctx context.Context) api.GetBulkTasksResponse
+	GetAllPaginatedTasks(ctx context.Context, r api.GetTasksRequest) api.GetBulkTasksResponse
 	DeleteTask(ctx context.Context, name string) api.Response
+	DeleteTaskByID(ctx context.Context, id int64) api.Response
 	UpdateTask(ctx context.Context, r api.UpdateTaskRequest) api.Response
+	UpdateTaskByID(ctx context.Context, id int64, r api.UpdateTaskRequest) api.Response
+	PatchTaskByID(ctx context.Context, id int64, r api.PatchTaskRequest) api.Response
+	GetChildTasks(ctx context.Context, id int64) api.GetBulkTasksResponse
+	GetSubtree(ctx context.Context, id int64) api.GetTaskTreeResponse
+	AddDependency(ctx context.Context, id int64, r api.AddDependencyRequest) api.Response
+	RemoveDependency(ctx context.Context, id int64, blockedBy int64) api.Response
+	GetBlockers(ctx context.Context, id int64) api.GetBlockersResponse
+	GetTasksInDependencyOrder(ctx context.Context) api.GetBulkTasksResponse
+	AddTags(ctx context.Context, id int64, r api.TagTaskRequest) api.Response
+	RemoveTag(ctx context.Context, id int64, tag string) api.Response
+	GetTagCounts(ctx context.Context) api.GetTagCountsResponse
+	GetCompletions(ctx context.Context, id int64) api.GetCompletionsResponse
+	ReopenTask(ctx context.Context, id int64, r api.ReopenTaskRequest) api.Response
+	GetHistory(ctx context.Context, id int64) api.GetHistoryResponse
+	GetAuditLog(ctx context.Context, r api.GetAuditLogRequest) api.GetAuditLogResponse
+	RestoreTask(ctx context.Context, id int64, r api.RestoreTaskRequest) api.Response
+	GetTrash(ctx context.Context) api.GetBulkTasksResponse
+	PurgeTrash(ctx context.Context, before time.Time) api.Response
+	SendReminders(ctx context.Context, now time.Time, notifiers []notify.Notifier, defaultLeadTimes []domain.Duration) api.Response
+	CreateWebhook(ctx context.Context, r api.CreateWebhookRequest) api.CreateWebhookResponse
+	GetWebhooks(ctx context.Context) api.GetWebhooksResponse
+	DeleteWebhook(ctx context.Context, id int64) api.Response
+	GetWebhookDeliveries(ctx context.Context, id int64, r api.GetDeliveriesRequest) api.GetDeliveriesResponse
+	ReplayDelivery(ctx context.Context, id int64) api.Response
+	DeliverWebhooks(ctx context.Context, now time.Time) api.Response
+	CreateCalendarFeed(ctx context.Context, r api.CreateCalendarFeedRequest) api.CreateCalendarFeedResponse
+	GetCalendarFeeds(ctx context.Context) api.GetCalendarFeedsResponse
+	DeleteCalendarFeed(ctx context.Context, id int64) api.Response
+	GetCalendar(ctx context.Context, r api.GetCalendarRequest) api.GetCalendarResponse
+	ImportCalendar(ctx context.Context, r api.ImportCalendarRequest) api.ImportCalendarResponse
+	SearchTasks(ctx context.Context, r api.SearchTasksRequest) api.SearchTasksResponse
+	BulkTasks(ctx context.Context, r api.BulkTasksRequest) api.BulkTasksResponse
 }
 
-// InitializeTaskService initializes the task service
-func InitializeTaskService(repo task.ITaskRepo) error {
+// InitializeTaskService initializes the task service. Status changes of tasks follow workflow,
+// and every change is published to publisher.
+func InitializeTaskService(repo task.ITaskRepo, workflow domain.Workflow, publisher events.Publisher) error {
 	builder := Initializers[taskServiceCode]
-	if err := build(builder, repo); err != nil {
+	if err := build(builder, repo, workflow, publisher); err != nil {
 		return err
 	}
 	return nil
@@ -51,26 +91,35 @@ type taskServiceBuilder struct {
 
 // Build is used to initialize channel service
 func (tsb *taskServiceBuilder) Build(args ...interface{}) error {
-	if len(args) != 1 {
+	if len(args) != 3 {
 		return errors.ErrorArgumentMismatch
 	}
-	value := args[0]
-	repo, ok := value.(task.ITaskRepo)
+	repo, ok := args[0].(task.ITaskRepo)
 	if !ok {
 		return errors.ErrorInvalidType
 	}
-	TaskService = TaskServiceImpl{repo}
+	workflow, ok := args[1].(domain.Workflow)
+	if !ok {
+		return errors.ErrorInvalidType
+	}
+	publisher, ok := args[2].(events.Publisher)
+	if !ok {
+		return errors.ErrorInvalidType
+	}
+	TaskService = TaskServiceImpl{repo, workflow, publisher}
 	return nil
 }
 
 // TaskServiceImpl implements ITaskService
 type TaskServiceImpl struct {
-	repo task.ITaskRepo
+	repo      task.ITaskRepo
+	workflow  domain.Workflow
+	publisher events.Publisher
 }
 
 // CreateTask creates task and stores in the repository
 func (ts TaskServiceImpl) CreateTask(ctx context.Context, r api.CreateTaskRequest) api.CreateTaskResponse {
-	dueDate, _ := time.Parse(domain.DateFormat, r.DueDate)
+	dueDate, _ := domain.ParseTime(r.DueDate, time.Now())
 	effort, _ := time.ParseDuration(r.Effort)
 	task := domain.Task{
 		Title:       r.Title,
@@ -79,12 +128,34 @@ func (ts TaskServiceImpl) CreateTask(ctx context.Context, r api.CreateTaskReques
 		DueDate:     domain.Time(dueDate),
 		Effort:      domain.Duration(effort),
 		Status:      domain.Pending,
+		Tags:        r.Tags,
+		Reminders:   leadTimes(r.Reminders),
+	}
+	if r.Recurrence != "" {
+		recurrence, _ := domain.ParseRecurrence(r.Recurrence)
+		task.Recurrence = &recurrence
+	}
+	if r.ParentID != 0 {
+		if _, err := ts.repo.GetTaskByID(ctx, r.ParentID); err != nil {
+			return api.CreateTaskResponse{Response: api.NewErrorResponse(errors.ErrorInvalidArgument.WithMessage("Parent task %d not found", r.ParentID).WithField("parentId")), TaskID: -1}
+		}
+		task.ParentID = &r.ParentID
 	}
-	id, err := ts.repo.AddTask(ctx, task)
+	err := ts.inTransaction(ctx, func(tx TaskServiceImpl) error {
+		id, err := tx.repo.AddTask(ctx, task)
+		if err != nil {
+			return err
+		}
+		task.Rowid = id
+		if err := tx.audit(ctx, domain.AuditCreate, nil, &task); err != nil {
+			return err
+		}
+		return tx.rollUpEffort(ctx, task.ParentID)
+	})
 	if err != nil {
 		return api.CreateTaskResponse{Response: api.NewErrorResponse(err), TaskID: -1}
 	}
-	return api.CreateTaskResponse{Response: api.NewStdResponse(), TaskID: id}
+	return api.CreateTaskResponse{Response: api.NewStdResponse(), TaskID: task.Rowid}
 
 }
 
@@ -98,6 +169,15 @@ func (ts TaskServiceImpl) GetTask(ctx context.Context, title string) api.GetTask
 	return api.GetTaskResponse{Response: api.NewStdResponse(), Task: task}
 }
 
+// GetTaskByID gets a task by it's id
+func (ts TaskServiceImpl) GetTaskByID(ctx context.Context, id int64) api.GetTaskResponse {
+	task, err := ts.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return api.GetTaskResponse{Response: api.NewErrorResponse(err), Task: domain.Task{}}
+	}
+	return api.GetTaskResponse{Response: api.NewStdResponse(), Task: task}
+}
+
 // GetAllTasks gets all the tasks from a repository. Don't use this when db gets too big, use
 // GetAllPaginatedTasks, which supports pagination.
 func (ts TaskServiceImpl) GetAllTasks(ctx context.Context) api.GetBulkTasksResponse {
@@ -105,30 +185,170 @@ func (ts TaskServiceImpl) GetAllTasks(ctx context.Context) api.GetBulkTasksRespo
 	if err != nil {
 		return api.GetBulkTasksResponse{Response: api.NewErrorResponse(err), Tasks: []domain.Task{}}
 	}
-	return api.GetBulkTasksResponse{Response: api.NewStdResponse(), Tasks: tasks}
+	return api.GetBulkTasksResponse{Response: api.NewStdResponse(), Tasks: tasks, Total: int64(len(tasks))}
+}
+
+// GetAllPaginatedTasks gets tasks matching the filters of the request, one page at a time.
+// The cursor of the next page is returned in the response, if there is one.
+func (ts TaskServiceImpl) GetAllPaginatedTasks(ctx context.Context, r api.GetTasksRequest) api.GetBulkTasksResponse {
+	page, err := ts.repo.GetPaginatedTasks(ctx, taskQuery(r))
+	if err != nil {
+		return api.GetBulkTasksResponse{Response: api.NewErrorResponse(err), Tasks: []domain.Task{}}
+	}
+	return api.GetBulkTasksResponse{Response: api.NewStdResponse(), Tasks: page.Tasks, NextCursor: page.NextCursor, Total: page.Total}
 }
 
-// DeleteTask deletes a task. I don't plan to use it much.
+// taskQuery converts a validated GetTasksRequest to a repository query
+func taskQuery(r api.GetTasksRequest) task.Query {
+	q := task.Query{
+		Title:      r.Title,
+		Tags:       r.Tag,
+		AllTags:    r.TagMode == "all",
+		SortBy:     task.SortField(r.Sort),
+		Descending: r.Order == "desc",
+		Cursor:     r.Cursor,
+	}
+	for _, s := range r.Status {
+		q.Statuses = append(q.Statuses, domain.Status(s))
+	}
+	if r.MinPriority != "" {
+		p, _ := strconv.Atoi(r.MinPriority)
+		q.MinPriority = domain.Priority(p)
+	}
+	if r.MaxPriority != "" {
+		p, _ := strconv.Atoi(r.MaxPriority)
+		q.MaxPriority = domain.Priority(p)
+	}
+	if r.DueBefore != "" {
+		dueBefore, _ := domain.ParseTime(r.DueBefore, time.Now())
+		q.DueBefore = &dueBefore
+	}
+	if r.DueAfter != "" {
+		dueAfter, _ := domain.ParseTime(r.DueAfter, time.Now())
+		q.DueAfter = &dueAfter
+	}
+	if r.Limit != "" {
+		q.Limit, _ = strconv.Atoi(r.Limit)
+	}
+	return q
+}
+
+// DeleteTask moves a task, found by its title, to the trash
 func (ts TaskServiceImpl) DeleteTask(ctx context.Context, title string) api.Response {
 	task, err := ts.repo.GetTaskByTitle(ctx, title)
 	if err != nil {
 		return api.NewErrorResponse(err)
 	}
 
-	err = ts.repo.DeleteTask(ctx, task.Rowid)
+	return ts.deleteTask(ctx, task)
+}
+
+// DeleteTaskByID moves a task to the trash
+func (ts TaskServiceImpl) DeleteTaskByID(ctx context.Context, id int64) api.Response {
+	task, err := ts.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+
+	return ts.deleteTask(ctx, task)
+}
+
+// deleteTask moves a task which has no subtasks to the trash, and rolls up the effort of its parent.
+// It is deleted for good once it is purged from the trash.
+func (ts TaskServiceImpl) deleteTask(ctx context.Context, task domain.Task) api.Response {
+	err := ts.inTransaction(ctx, func(tx TaskServiceImpl) error {
+		children, err := tx.repo.GetChildTasks(ctx, task.Rowid)
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			return errors.ErrorConflict.WithMessage("Cannot delete task %d, it has %d subtasks", task.Rowid, len(children))
+		}
+
+		if err := tx.repo.TrashTask(ctx, task.Rowid, time.Now()); err != nil {
+			return err
+		}
+		if err := tx.audit(ctx, domain.AuditDelete, &task, nil); err != nil {
+			return err
+		}
+		return tx.rollUpEffort(ctx, task.ParentID)
+	})
 	if err != nil {
 		return api.NewErrorResponse(err)
 	}
 	return api.NewStdResponse()
 }
 
-// UpdateTask deletes a task. I don't plan to use it much.
+// UpdateTask updates a task, found by the title of the request. Title itself can't be changed here,
+// use UpdateTaskByID for that.
 func (ts TaskServiceImpl) UpdateTask(ctx context.Context, r api.UpdateTaskRequest) api.Response {
+	if r.Title == "" {
+		return api.NewErrorResponse(errors.ErrorInvalidArgument.WithMessage("Cannot have empty title").WithField("title"))
+	}
 	task, err := ts.repo.GetTaskByTitle(ctx, r.Title)
 	if err != nil {
 		return api.NewErrorResponse(err)
 	}
 
+	return ts.updateTask(ctx, task, r)
+}
+
+// UpdateTaskByID updates a task by it's id. A non empty title in the request renames the task.
+func (ts TaskServiceImpl) UpdateTaskByID(ctx context.Context, id int64, r api.UpdateTaskRequest) api.Response {
+	task, err := ts.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+
+	if r.Title != "" {
+		task.Title = r.Title
+	}
+	return ts.updateTask(ctx, task, r)
+}
+
+// PatchTaskByID applies a merge patch or a json patch on a task. Unlike UpdateTaskByID, fields
+// can be cleared. The patched task is validated as a whole before it is saved.
+func (ts TaskServiceImpl) PatchTaskByID(ctx context.Context, id int64, r api.PatchTaskRequest) api.Response {
+	task, err := ts.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+
+	old := task
+	original := api.NewTaskDocument(task)
+	patched, err := r.Apply(original)
+	if err != nil {
+		return api.NewErrorResponse(invalid(err))
+	}
+	if err := patched.Validate(original); err != nil {
+		return api.NewErrorResponse(invalid(err))
+	}
+
+	dueDate, _ := domain.ParseTime(patched.DueDate, time.Now())
+	effort, _ := time.ParseDuration(patched.Effort)
+	task.Title = patched.Title
+	task.Description = patched.Description
+	task.DueDate = domain.Time(dueDate)
+	task.Priority = domain.Priority(patched.Priority)
+	task.Effort = domain.Duration(effort)
+	task.Status = domain.Status(patched.Status)
+	task.ParentID = patched.ParentID
+	task.Recurrence = nil
+	if patched.Recurrence != nil {
+		recurrence, _ := domain.ParseRecurrence(*patched.Recurrence)
+		task.Recurrence = &recurrence
+	}
+	task.Reminders = leadTimes(patched.Reminders)
+
+	if err := ts.saveTask(ctx, old, task, domain.AuditUpdate); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// updateTask sets the non empty values of the request on the task, and persists it
+func (ts TaskServiceImpl) updateTask(ctx context.Context, task domain.Task, r api.UpdateTaskRequest) api.Response {
+	old := task
 	// set new values
 	if r.Status != "" {
 		task.Status = domain.Status(r.Status)
@@ -137,7 +357,7 @@ func (ts TaskServiceImpl) UpdateTask(ctx context.Context, r api.UpdateTaskReques
 		task.Description = r.Description
 	}
 	if r.DueDate != "" {
-		dueDate, _ := time.Parse(domain.DateFormat, r.DueDate)
+		dueDate, _ := domain.ParseTime(r.DueDate, time.Now())
 		task.DueDate = domain.Time(dueDate)
 	}
 	if r.Priority != 0 {
@@ -150,10 +370,87 @@ func (ts TaskServiceImpl) UpdateTask(ctx context.Context, r api.UpdateTaskReques
 	if r.Status != "" {
 		task.Status = domain.Status(r.Status)
 	}
+	if r.ParentID != 0 {
+		task.ParentID = &r.ParentID
+	}
+	if r.Recurrence != "" {
+		recurrence, _ := domain.ParseRecurrence(r.Recurrence)
+		task.Recurrence = &recurrence
+	}
+	if r.Reminders != nil {
+		task.Reminders = leadTimes(r.Reminders)
+	}
 
-	err = ts.repo.UpdateTask(ctx, task)
-	if err != nil {
+	if err := ts.saveTask(ctx, old, task, domain.AuditUpdate); err != nil {
 		return api.NewErrorResponse(err)
 	}
 	return api.NewStdResponse()
 }
+
+// saveTask persists the changes from old to task. Every update of a task goes through here,
+// so that the workflow and the rules on subtasks and dependencies are always checked, changes
+// are audited, and recurring tasks come back once they are done. action is recorded in the
+// audit log. Reopening and restoring a task allow the reopen transitions of the workflow.
+// Everything is saved in one transaction, or nothing is.
+func (ts TaskServiceImpl) saveTask(ctx context.Context, old, task domain.Task, action domain.AuditAction) error {
+	return ts.inTransaction(ctx, func(tx TaskServiceImpl) error {
+		reopen := action == domain.AuditReopen || action == domain.AuditRestore
+		if err := tx.workflow.CheckTransition(old.Status, task.Status, reopen); err != nil {
+			return err
+		}
+		if err := tx.checkParent(ctx, task); err != nil {
+			return err
+		}
+		if err := tx.checkChildrenDone(ctx, old, task); err != nil {
+			return err
+		}
+		if err := tx.checkBlockers(ctx, old, task); err != nil {
+			return err
+		}
+		if err := tx.recordTransition(ctx, task.Rowid, old.Status, task.Status); err != nil {
+			return err
+		}
+		if err := tx.completeOccurrence(ctx, old, &task); err != nil {
+			return err
+		}
+
+		if err := tx.repo.UpdateTask(ctx, task); err != nil {
+			return err
+		}
+		if err := tx.audit(ctx, action, &old, &task); err != nil {
+			return err
+		}
+
+		if err := tx.rollUpEffort(ctx, task.ParentID); err != nil {
+			return err
+		}
+		if old.ParentID != nil && (task.ParentID == nil || *old.ParentID != *task.ParentID) {
+			return tx.rollUpEffort(ctx, old.ParentID)
+		}
+		return nil
+	})
+}
+
+// inTransaction calls fn with a service whose repository runs in one transaction. The events of
+// fn are only published once the transaction is committed. In a transaction already, like the
+// ones of BulkTasks, fn runs in it.
+func (ts TaskServiceImpl) inTransaction(ctx context.Context, fn func(tx TaskServiceImpl) error) error {
+	var batch events.Batch
+	err := ts.repo.WithTransaction(ctx, func(repo task.ITaskRepo) error {
+		return fn(TaskServiceImpl{repo, ts.workflow, &batch})
+	})
+	if err == nil && ts.publisher != nil {
+		batch.Flush(ts.publisher)
+	}
+	return err
+}
+
+// invalid marks an error of a request as errors.ErrorInvalidArgument, unless it has a code
+// already or lists broken validation rules
+func invalid(err error) error {
+	switch err.(type) {
+	case errors.AggError, validation.Errors:
+		return err
+	}
+	return errors.ErrorInvalidArgument.WithMessage("%s", err.Error())
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"server/api"
+	"server/domain"
+	"server/events"
+	"server/repository/task"
+)
+
+func TestSaveTaskInTransaction(t *testing.T) {
+	ctx := context.Background()
+	repo := task.NewInMemoryTaskRepo()
+	hub := events.NewHub(10)
+	ts := TaskServiceImpl{repo, domain.DefaultWorkflow, hub}
+	sub := hub.Subscribe(0, events.Filter{})
+	defer sub.Close()
+	if _, err := repo.AddWebhook(ctx, domain.Webhook{URL: "http://example.com", Secret: "s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	first := ts.CreateTask(ctx, api.CreateTaskRequest{Title: "water plants", Priority: 3, Recurrence: "daily"})
+	second := ts.CreateTask(ctx, api.CreateTaskRequest{Title: "feed cat", Priority: 3})
+	if !first.Success() || !second.Success() {
+		t.Fatalf("got %v, %v", first, second)
+	}
+
+	// completing the recurring task records a completion and a transition, which are rolled back
+	// along with the update when the new title is taken
+	resp := ts.UpdateTaskByID(ctx, first.TaskID, api.UpdateTaskRequest{Title: "feed cat", Status: string(domain.Done)})
+	if resp.Success() {
+		t.Fatal("expected the update to fail")
+	}
+	if completions, _ := repo.GetCompletions(ctx, first.TaskID); len(completions) != 0 {
+		t.Errorf("got completions %v", completions)
+	}
+	if history, _ := repo.GetHistory(ctx, first.TaskID); len(history) != 0 {
+		t.Errorf("got history %v", history)
+	}
+	if entries, _ := repo.GetAuditLog(ctx, task.AuditQuery{TaskID: first.TaskID}); len(entries) != 1 {
+		t.Errorf("got audit entries %v, want the creation only", entries)
+	}
+	if stored, _ := repo.GetTaskByID(ctx, first.TaskID); stored.Status != domain.Pending || stored.Title != "water plants" {
+		t.Errorf("got %v after the failed update", stored)
+	}
+	if deliveries, _ := repo.GetWebhookDeliveries(ctx, task.DeliveryQuery{}); len(deliveries) != 2 {
+		t.Errorf("got deliveries %v, want the ones of the 2 creations", deliveries)
+	}
+	if len(sub.C) != 2 {
+		t.Errorf("got %d events, want the 2 creations", len(sub.C))
+	}
+
+	if resp := ts.DeleteTaskByID(ctx, second.TaskID); !resp.Success() {
+		t.Fatalf("got %v", resp)
+	}
+	if trash, _ := repo.GetTrash(ctx); len(trash) != 1 || len(sub.C) != 3 {
+		t.Errorf("got trash %v and %d events after deleting", trash, len(sub.C))
+	}
+}
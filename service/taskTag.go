@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	"server/api"
+	"server/domain"
+)
+
+// AddTags tags a task. Tags the task already has are left as they are.
+func (ts TaskServiceImpl) AddTags(ctx context.Context, id int64, r api.TagTaskRequest) api.Response {
+	if _, err := ts.repo.GetTaskByID(ctx, id); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	if err := ts.repo.AddTags(ctx, id, r.Tags); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// RemoveTag removes a tag from a task
+func (ts TaskServiceImpl) RemoveTag(ctx context.Context, id int64, tag string) api.Response {
+	if err := ts.repo.RemoveTag(ctx, id, tag); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// GetTagCounts gets every tag in use, most used first
+func (ts TaskServiceImpl) GetTagCounts(ctx context.Context) api.GetTagCountsResponse {
+	counts, err := ts.repo.GetTagCounts(ctx)
+	if err != nil {
+		return api.GetTagCountsResponse{Response: api.NewErrorResponse(err), Tags: []domain.TagCount{}}
+	}
+	return api.GetTagCountsResponse{Response: api.NewStdResponse(), Tags: counts}
+}
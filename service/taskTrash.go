@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+)
+
+// GetTrash gets the deleted tasks which have not been purged yet
+func (ts TaskServiceImpl) GetTrash(ctx context.Context) api.GetBulkTasksResponse {
+	trash, err := ts.repo.GetTrash(ctx)
+	if err != nil {
+		return api.GetBulkTasksResponse{Response: api.NewErrorResponse(err), Tasks: []domain.Task{}}
+	}
+	return api.GetBulkTasksResponse{Response: api.NewStdResponse(), Tasks: trash, Total: int64(len(trash))}
+}
+
+// PurgeTrash permanently deletes the tasks which were moved to the trash before a time.
+// Their audit log is kept. A task which cannot be deleted is logged and skipped, so that it does
+// not hold back the others, and the response has the first error.
+func (ts TaskServiceImpl) PurgeTrash(ctx context.Context, before time.Time) api.Response {
+	trash, err := ts.repo.GetTrash(ctx)
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	var failed error
+	for _, t := range trash {
+		if t.DeletedAt == nil || !time.Time(*t.DeletedAt).Before(before) {
+			continue
+		}
+		if err := ts.repo.DeleteTask(ctx, t.Rowid); err != nil {
+			log.Printf("Could not purge task %d from trash: %v", t.Rowid, err)
+			if failed == nil {
+				failed = err
+			}
+			continue
+		}
+		log.Printf("Purged task %d from trash", t.Rowid)
+	}
+	if failed != nil {
+		return api.NewErrorResponse(failed)
+	}
+	return api.NewStdResponse()
+}
+
+// PurgeTrashEvery purges tasks which have been in the trash for longer than retention, once
+// every interval, until ctx is done. It is meant to be run in its own goroutine.
+func PurgeTrashEvery(ctx context.Context, ts ITaskService, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if resp := ts.PurgeTrash(ctx, time.Now().Add(-retention)); !resp.Success() {
+			log.Printf("Could not purge trash: %v", resp)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// restoreFromTrash takes a task out of the trash. Its parent has to be out of the trash already,
+// and its title must not have been taken by another task since it was deleted.
+func (ts TaskServiceImpl) restoreFromTrash(ctx context.Context, id int64) api.Response {
+	task, ok, err := ts.trashedTask(ctx, id)
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	if !ok {
+		return api.NewErrorResponse(errors.ErrorObjectNotFound.WithMessage("Task %d is not in the trash", id))
+	}
+	if err := ts.checkParent(ctx, task); err != nil {
+		return api.NewErrorResponse(err)
+	}
+
+	err = ts.inTransaction(ctx, func(tx TaskServiceImpl) error {
+		if err := tx.repo.RestoreFromTrash(ctx, id); err != nil {
+			return titleConflict(err, task.Title)
+		}
+		task.DeletedAt = nil
+		if err := tx.audit(ctx, domain.AuditRestore, nil, &task); err != nil {
+			return err
+		}
+		return tx.rollUpEffort(ctx, task.ParentID)
+	})
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// titleConflict tells which title is taken when a task cannot be restored because of it
+func titleConflict(err error, title string) error {
+	if err == errors.ErrorObjectAlreadyExists {
+		return errors.ErrorObjectAlreadyExists.WithMessage("Another task is titled %q", title)
+	}
+	return err
+}
+
+// trashedTask finds a task in the trash
+func (ts TaskServiceImpl) trashedTask(ctx context.Context, id int64) (domain.Task, bool, error) {
+	task, err := ts.repo.GetTrashedTask(ctx, id)
+	if err == errors.ErrorObjectNotFound {
+		return domain.Task{}, false, nil
+	}
+	return task, err == nil, err
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+	"server/repository/task"
+)
+
+// trashed is a task which was moved to the trash at deletedAt
+func trashed(t domain.Task, deletedAt time.Time) domain.Task {
+	at := domain.Time(deletedAt)
+	t.DeletedAt = &at
+	return t
+}
+
+func TestPurgeTrash(t *testing.T) {
+	ts := newTestService()
+	now := time.Now()
+	trash := []domain.Task{
+		trashed(testTask(1, 0, time.Hour), now.Add(-48*time.Hour)),
+		trashed(testTask(2, 0, time.Hour), now.Add(-time.Hour)),
+		trashed(testTask(3, 0, time.Hour), now.Add(-25*time.Hour)),
+	}
+	debugMap := map[string]interface{}{"trash": trash}
+	ctx := debugContext(debugMap)
+	if resp := ts.PurgeTrash(ctx, now.Add(-24*time.Hour)); !resp.Success() {
+		t.Fatalf("got %v", resp)
+	}
+	if deleted := fmt.Sprint(debugMap["deleted"]); deleted != "[1 3]" {
+		t.Errorf("purged %s, want [1 3]", deleted)
+	}
+
+	// a task which cannot be deleted does not hold back the others
+	debugMap = map[string]interface{}{"trash": trash, "deleteErrors": map[int64]error{1: fmt.Errorf("locked")}}
+	ctx = debugContext(debugMap)
+	if resp := ts.PurgeTrash(ctx, now.Add(-24*time.Hour)); resp.Success() {
+		t.Error("expected the failed purge to be reported")
+	}
+	if deleted := fmt.Sprint(debugMap["deleted"]); deleted != "[3]" {
+		t.Errorf("purged %s, want [3]", deleted)
+	}
+}
+
+func TestRestoreFromTrash(t *testing.T) {
+	ts := newTestService()
+	now := time.Now()
+	parent := testTask(1, 0, time.Hour)
+	sibling := testTask(2, 1, time.Hour)
+	cases := []struct {
+		name   string
+		trash  []domain.Task
+		id     int64
+		code   string
+		effort time.Duration
+	}{
+		{"subtask", []domain.Task{trashed(testTask(3, 1, 2*time.Hour), now)}, 3, "", 3 * time.Hour},
+		{"subtask of a trashed task", []domain.Task{trashed(testTask(3, 4, 2*time.Hour), now), trashed(testTask(4, 0, 0), now)}, 3, errors.ErrorInvalidArgument.StringCode(), time.Hour},
+		{"task not in the trash", nil, 3, errors.ErrorObjectNotFound.StringCode(), time.Hour},
+	}
+	for _, c := range cases {
+		ctx := debugContext(map[string]interface{}{"trash": c.trash}, parent, sibling)
+		resp := ts.RestoreTask(ctx, c.id, api.RestoreTaskRequest{})
+		if c.code == "" {
+			if !resp.Success() {
+				t.Errorf("%s: got %v", c.name, resp)
+			} else if restored := stored(ctx, c.id); restored.DeletedAt != nil || restored.Rowid != c.id {
+				t.Errorf("%s: got %v after the restore", c.name, restored)
+			}
+		} else if resp.Success() || resp.GetErrors()[0].Code != c.code {
+			t.Errorf("%s: got %v, want %s", c.name, resp, c.code)
+		}
+		if effort := time.Duration(stored(ctx, 1).Effort); effort != c.effort {
+			t.Errorf("%s: parent effort %v, want %v", c.name, effort, c.effort)
+		}
+	}
+}
+
+func TestRestoreTakenTitle(t *testing.T) {
+	ctx := context.Background()
+	repo := task.NewInMemoryTaskRepo()
+	ts := TaskServiceImpl{repo, domain.DefaultWorkflow, nil}
+	due := domain.Time(time.Now().Add(time.Hour))
+
+	id, err := repo.AddTask(ctx, domain.Task{Title: "report", DueDate: due})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.TrashTask(ctx, id, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.AddTask(ctx, domain.Task{Title: "report", DueDate: due}); err != nil {
+		t.Fatal(err)
+	}
+	resp := ts.RestoreTask(ctx, id, api.RestoreTaskRequest{})
+	if resp.Success() || resp.GetErrors()[0].Code != errors.ErrorObjectAlreadyExists.StringCode() {
+		t.Errorf("got %v, want the title reported as taken", resp)
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"server/api"
+	"server/domain"
+	"server/errors"
+	"server/notify"
+	"server/repository/task"
+)
+
+const (
+	// defaultDeliveryLogSize is how many deliveries the delivery log shows without a limit
+	defaultDeliveryLogSize = 50
+	// deliveryBatchSize is the most deliveries DeliverWebhooks sends in one go
+	deliveryBatchSize = 100
+)
+
+// webhookPayload is the json body of a delivery. Task is the task after the change, or right
+// before it was deleted, and Changes are the changed fields, like in the audit log.
+type webhookPayload struct {
+	Event    domain.TaskEvent    `json:"event"`
+	TaskID   int64               `json:"taskId"`
+	Task     domain.Task         `json:"task"`
+	Changes  domain.FieldChanges `json:"changes"`
+	Username string              `json:"username"`
+	Occurred domain.Time         `json:"occurred"`
+}
+
+// CreateWebhook subscribes a URL to events of tasks
+func (ts TaskServiceImpl) CreateWebhook(ctx context.Context, r api.CreateWebhookRequest) api.CreateWebhookResponse {
+	secret := r.Secret
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return api.CreateWebhookResponse{Response: api.NewErrorResponse(err), WebhookID: -1}
+		}
+		secret = hex.EncodeToString(b)
+	}
+	events := make(domain.EventList, 0, len(r.Events))
+	for _, e := range r.Events {
+		events = append(events, domain.TaskEvent(e))
+	}
+
+	webhook := domain.Webhook{URL: r.URL, Secret: secret, Events: events, Created: domain.Time(time.Now())}
+	id, err := ts.repo.AddWebhook(ctx, webhook)
+	if err != nil {
+		return api.CreateWebhookResponse{Response: api.NewErrorResponse(err), WebhookID: -1}
+	}
+	return api.CreateWebhookResponse{Response: api.NewStdResponse(), WebhookID: id, Secret: secret}
+}
+
+// GetWebhooks lists the webhooks, without their secrets
+func (ts TaskServiceImpl) GetWebhooks(ctx context.Context) api.GetWebhooksResponse {
+	webhooks, err := ts.repo.GetWebhooks(ctx)
+	if err != nil {
+		return api.GetWebhooksResponse{Response: api.NewErrorResponse(err), Webhooks: []domain.Webhook{}}
+	}
+	return api.GetWebhooksResponse{Response: api.NewStdResponse(), Webhooks: webhooks}
+}
+
+// DeleteWebhook unsubscribes a webhook, and drops its delivery log
+func (ts TaskServiceImpl) DeleteWebhook(ctx context.Context, id int64) api.Response {
+	if err := ts.repo.DeleteWebhook(ctx, id); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// GetWebhookDeliveries gets the delivery log of a webhook, newest first
+func (ts TaskServiceImpl) GetWebhookDeliveries(ctx context.Context, id int64, r api.GetDeliveriesRequest) api.GetDeliveriesResponse {
+	if _, err := ts.repo.GetWebhook(ctx, id); err != nil {
+		return api.GetDeliveriesResponse{Response: api.NewErrorResponse(err), Deliveries: []domain.WebhookDelivery{}}
+	}
+	q := task.DeliveryQuery{WebhookID: id, Status: domain.DeliveryStatus(r.Status), Newest: true, Limit: defaultDeliveryLogSize}
+	if r.Limit != "" {
+		q.Limit, _ = strconv.Atoi(r.Limit)
+	}
+
+	deliveries, err := ts.repo.GetWebhookDeliveries(ctx, q)
+	if err != nil {
+		return api.GetDeliveriesResponse{Response: api.NewErrorResponse(err), Deliveries: []domain.WebhookDelivery{}}
+	}
+	return api.GetDeliveriesResponse{Response: api.NewStdResponse(), Deliveries: deliveries}
+}
+
+// ReplayDelivery sends a failed delivery again, with the same payload, on the next run of
+// DeliverWebhooks
+func (ts TaskServiceImpl) ReplayDelivery(ctx context.Context, id int64) api.Response {
+	delivery, err := ts.repo.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	if delivery.Status != domain.DeliveryFailed {
+		return api.NewErrorResponse(errors.ErrorConflict.WithMessage("Delivery %d is %s, only failed deliveries can be replayed", id, delivery.Status))
+	}
+	delivery.Replay(time.Now())
+	if err := ts.repo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		return api.NewErrorResponse(err)
+	}
+	return api.NewStdResponse()
+}
+
+// DeliverWebhooks sends the deliveries which are due at now. A delivery which fails is tried
+// again later, waiting longer every time, until it fails for good and waits for a replay.
+func (ts TaskServiceImpl) DeliverWebhooks(ctx context.Context, now time.Time) api.Response {
+	deliveries, err := ts.repo.GetWebhookDeliveries(ctx, task.DeliveryQuery{Status: domain.DeliveryPending, DueBefore: &now, Limit: deliveryBatchSize})
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	if len(deliveries) == 0 {
+		return api.NewStdResponse()
+	}
+	webhooks, err := ts.repo.GetWebhooks(ctx)
+	if err != nil {
+		return api.NewErrorResponse(err)
+	}
+	byID := make(map[int64]domain.Webhook, len(webhooks))
+	for _, w := range webhooks {
+		byID[w.Rowid] = w
+	}
+
+	for _, d := range deliveries {
+		webhook, ok := byID[d.WebhookID]
+		if !ok {
+			continue
+		}
+		status, err := notify.NewWebhook(webhook.URL).Deliver(ctx, webhook.Secret, d)
+		if err != nil {
+			log.Printf("Could not deliver %s to webhook %d: %v", d.Event, webhook.Rowid, err)
+			d.Failed(time.Now(), status, err)
+		} else {
+			d.Delivered(status)
+		}
+		if err := ts.repo.UpdateWebhookDelivery(ctx, d); err != nil {
+			return api.NewErrorResponse(err)
+		}
+	}
+	return api.NewStdResponse()
+}
+
+// DeliverWebhooksEvery sends the deliveries which are due once every interval, until ctx is
+// done. It is meant to be run in its own goroutine.
+func DeliverWebhooksEvery(ctx context.Context, ts ITaskService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if resp := ts.DeliverWebhooks(ctx, time.Now()); !resp.Success() {
+			log.Printf("Could not deliver webhooks: %v", resp)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// queueDeliveries queues the events of a change of a task, recorded in the audit log as entry,
+// for every webhook subscribed to them. It is called from audit, in the transaction of the
+// change, so that the deliveries are rolled back along with a change which fails.
+func (ts TaskServiceImpl) queueDeliveries(ctx context.Context, old, t *domain.Task, entry domain.AuditEntry) error {
+	webhooks, err := ts.repo.GetWebhooks(ctx)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+	for _, event := range domain.Events(old, t) {
+		payload, err := json.Marshal(webhookPayload{
+			Event:    event,
+			TaskID:   entry.TaskID,
+			Task:     domain.Task(entry.Snapshot),
+			Changes:  entry.Changes,
+			Username: entry.Username,
+			Occurred: entry.Changed,
+		})
+		if err != nil {
+			return err
+		}
+		for _, w := range webhooks {
+			if !w.Events.Has(event) {
+				continue
+			}
+			delivery := domain.WebhookDelivery{
+				WebhookID:   w.Rowid,
+				Event:       event,
+				Payload:     string(payload),
+				Status:      domain.DeliveryPending,
+				NextAttempt: entry.Changed,
+				Created:     entry.Changed,
+			}
+			if _, err := ts.repo.AddWebhookDelivery(ctx, delivery); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/domain"
+	"server/errors"
+	"server/notify"
+)
+
+// receiver is a webhook receiver which answers status, and checks the signature of what it gets
+// with secret
+func receiver(t *testing.T, secret string, status int, received *[]domain.TaskEvent) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if got := r.Header.Get(notify.SignatureHeader); got != notify.Sign(secret, body) {
+			t.Errorf("got signature %q for %s", got, body)
+		}
+		*received = append(*received, domain.TaskEvent(r.Header.Get(notify.EventHeader)))
+		w.WriteHeader(status)
+	}))
+}
+
+func TestQueueDeliveries(t *testing.T) {
+	webhooks := []domain.Webhook{
+		{Rowid: 1, URL: "http://all", Secret: "s1"},
+		{Rowid: 2, URL: "http://status", Secret: "s2", Events: domain.EventList{domain.EventTaskStatusChanged}},
+	}
+	debugMap := map[string]interface{}{"webhooks": webhooks}
+	ctx := debugContext(debugMap)
+	ts := newTestService()
+
+	old := testTask(1, 0, 0)
+	done := old
+	done.Status = domain.Done
+	entry := domain.AuditEntry{TaskID: 1, Action: domain.AuditUpdate, Changes: domain.Diff(&old, &done), Snapshot: domain.Snapshot(done), Changed: domain.Time(time.Now()), Username: "ann"}
+	if err := ts.queueDeliveries(ctx, &old, &done, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, _ := debugMap["deliveries"].([]domain.WebhookDelivery)
+	want := []struct {
+		webhookID int64
+		event     domain.TaskEvent
+	}{{1, domain.EventTaskUpdated}, {1, domain.EventTaskStatusChanged}, {2, domain.EventTaskStatusChanged}}
+	if len(deliveries) != len(want) {
+		t.Fatalf("got deliveries %v, want %v", deliveries, want)
+	}
+	for i, d := range deliveries {
+		if d.WebhookID != want[i].webhookID || d.Event != want[i].event || d.Status != domain.DeliveryPending || d.NextAttempt != entry.Changed {
+			t.Errorf("got delivery %v, want %v due at once", d, want[i])
+		}
+		var payload webhookPayload
+		if err := json.Unmarshal([]byte(d.Payload), &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Event != d.Event || payload.TaskID != 1 || payload.Task.Status != domain.Done || payload.Username != "ann" {
+			t.Errorf("got payload %s", d.Payload)
+		}
+	}
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	var accepted, refused []domain.TaskEvent
+	up := receiver(t, "s1", http.StatusNoContent, &accepted)
+	defer up.Close()
+	down := receiver(t, "s2", http.StatusBadGateway, &refused)
+	defer down.Close()
+
+	now := time.Now()
+	due := domain.Time(now.Add(-time.Minute))
+	debugMap := map[string]interface{}{
+		"webhooks": []domain.Webhook{{Rowid: 1, URL: up.URL, Secret: "s1"}, {Rowid: 2, URL: down.URL, Secret: "s2"}},
+		"deliveries": []domain.WebhookDelivery{
+			{Rowid: 1, WebhookID: 1, Event: domain.EventTaskCreated, Payload: `{"taskId":1}`, Status: domain.DeliveryPending, NextAttempt: due},
+			{Rowid: 2, WebhookID: 2, Event: domain.EventTaskUpdated, Payload: `{"taskId":2}`, Status: domain.DeliveryPending, Attempts: 2, NextAttempt: due},
+			{Rowid: 3, WebhookID: 2, Event: domain.EventTaskDeleted, Payload: `{"taskId":3}`, Status: domain.DeliveryPending, Attempts: domain.MaxDeliveryAttempts - 1, NextAttempt: due},
+		},
+	}
+	ctx := debugContext(debugMap)
+	ts := newTestService()
+
+	if resp := ts.DeliverWebhooks(ctx, now); !resp.Success() {
+		t.Fatalf("got %v", resp)
+	}
+	if len(accepted) != 1 || accepted[0] != domain.EventTaskCreated || len(refused) != 2 {
+		t.Errorf("got %v accepted and %v refused", accepted, refused)
+	}
+
+	deliveries := debugMap["deliveries"].([]domain.WebhookDelivery)
+	if d := deliveries[0]; d.Status != domain.DeliveryDelivered || d.Attempts != 1 || d.LastStatus != http.StatusNoContent {
+		t.Errorf("got %v, want it delivered", d)
+	}
+	// the third attempt waits 4 times the first retry delay, 30s
+	d := deliveries[1]
+	wait := time.Time(d.NextAttempt).Sub(now)
+	if d.Status != domain.DeliveryPending || d.Attempts != 3 || d.LastStatus != http.StatusBadGateway || d.LastError == "" || wait < 2*time.Minute || wait > 2*time.Minute+10*time.Second {
+		t.Errorf("got %v, waiting %v, want it retried in 2m", d, wait)
+	}
+	if d := deliveries[2]; d.Status != domain.DeliveryFailed || d.Attempts != domain.MaxDeliveryAttempts || time.Time(d.NextAttempt) != time.Time(due) {
+		t.Errorf("got %v, want it failed for good", d)
+	}
+}
+
+func TestReplayDelivery(t *testing.T) {
+	failed := domain.WebhookDelivery{Rowid: 1, WebhookID: 1, Status: domain.DeliveryFailed, Attempts: domain.MaxDeliveryAttempts, LastError: "down"}
+	debugMap := map[string]interface{}{"delivery": failed, "deliveries": []domain.WebhookDelivery{failed}}
+	ctx := debugContext(debugMap)
+	ts := newTestService()
+
+	before := time.Now()
+	if resp := ts.ReplayDelivery(ctx, 1); !resp.Success() {
+		t.Fatalf("got %v", resp)
+	}
+	d := debugMap["deliveries"].([]domain.WebhookDelivery)[0]
+	if d.Status != domain.DeliveryPending || d.Attempts != 0 || time.Time(d.NextAttempt).Before(before) {
+		t.Errorf("got %v, want it pending from now", d)
+	}
+
+	// the delivery is now pending, and cannot be replayed again
+	resp := ts.ReplayDelivery(ctx, 1)
+	if resp.Success() || resp.GetErrors()[0].Code != errors.ErrorConflict.StringCode() {
+		t.Errorf("got %v, want a conflict", resp)
+	}
+}
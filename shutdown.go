@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout is how long requests in flight get to finish on shutdown
+const shutdownTimeout = 15 * time.Second
+
+var (
+	shutdownMu    sync.Mutex
+	shutdownHooks []func()
+
+	// backgroundCtx is cancelled on shutdown, to stop the background jobs before the hooks run
+	backgroundCtx, stopBackground = context.WithCancel(context.Background())
+	background                    sync.WaitGroup
+)
+
+// runInBackground runs fn in its own goroutine, with a context which is cancelled when the server
+// shuts down. The shutdown hooks only run once fn has returned.
+func runInBackground(fn func(ctx context.Context)) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		fn(backgroundCtx)
+	}()
+}
+
+// onShutdown registers fn to run once the server has stopped serving requests
+func onShutdown(fn func()) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	shutdownHooks = append(shutdownHooks, fn)
+}
+
+// shutdownOnSignal waits for SIGINT or SIGTERM, shuts srv down gracefully, stops the background
+// jobs and runs the shutdown hooks. done is closed when they have all returned.
+func shutdownOnSignal(srv *http.Server, done chan<- struct{}) {
+	defer close(done)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	log.Printf("Received %v, shutting down", <-signals)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Could not shut down gracefully: %s", err.Error())
+	}
+	stopBackground()
+	background.Wait()
+
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	for _, fn := range shutdownHooks {
+		fn()
+	}
+}
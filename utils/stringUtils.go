@@ -11,7 +11,7 @@ func IsBlank(s string) bool {
 	}
 
 	for _, char := range s {
-		if unicode.IsSpace(char) {
+		if !unicode.IsSpace(char) {
 			return false
 		}
 	}
@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Required is broken by empty values: empty strings, zero numbers, nil and empty lists
+var Required = Rule{
+	Name: "required",
+	Check: func(value interface{}) string {
+		if isEmpty(value) {
+			return "Cannot be empty"
+		}
+		return ""
+	},
+}
+
+// MaxLength is broken by strings longer than max bytes
+func MaxLength(max int) Rule {
+	return Rule{
+		Name: "maxLength",
+		Check: func(value interface{}) string {
+			if len(fmt.Sprint(value)) > max {
+				return fmt.Sprintf("Cannot be longer than %d characters", max)
+			}
+			return ""
+		},
+	}
+}
+
+// Range is broken by numbers outside min to max, both included. Numbers can be given as
+// strings, like query parameters are, and strings which are not numbers break the rule too.
+func Range(min, max int64) Rule {
+	return Rule{
+		Name: "range",
+		Check: func(value interface{}) string {
+			if n, ok := toInt(value); !ok || n < min || n > max {
+				return fmt.Sprintf("Has to be a number from %d to %d", min, max)
+			}
+			return ""
+		},
+	}
+}
+
+// Min is broken by numbers less than min, or strings which are not numbers
+func Min(min int64) Rule {
+	return Rule{
+		Name: "min",
+		Check: func(value interface{}) string {
+			if n, ok := toInt(value); !ok || n < min {
+				return fmt.Sprintf("Has to be a number of at least %d", min)
+			}
+			return ""
+		},
+	}
+}
+
+// OneOf is broken by values other than the ones given
+func OneOf(values ...string) Rule {
+	return Rule{
+		Name: "oneOf",
+		Check: func(value interface{}) string {
+			s := fmt.Sprint(value)
+			for _, v := range values {
+				if s == v {
+					return ""
+				}
+			}
+			return fmt.Sprintf("Has to be one of %s", strings.Join(values, ", "))
+		},
+	}
+}
+
+// Duration is broken by strings which time.ParseDuration does not accept
+var Duration = Rule{
+	Name: "duration",
+	Check: func(value interface{}) string {
+		if _, err := time.ParseDuration(fmt.Sprint(value)); err != nil {
+			return "Has to be a duration like 1h30m"
+		}
+		return ""
+	},
+}
+
+// Func is a rule named name, broken when check returns an error. The message is the error.
+func Func(name string, check func(value interface{}) error) Rule {
+	return Rule{
+		Name: name,
+		Check: func(value interface{}) string {
+			if err := check(value); err != nil {
+				return err.Error()
+			}
+			return ""
+		},
+	}
+}
+
+// toInt reads a number of any integer type, or a string of one
+func toInt(value interface{}) (int64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	case reflect.String:
+		n, err := strconv.ParseInt(rv.String(), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
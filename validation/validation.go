@@ -0,0 +1,114 @@
+// Package validation checks the fields of requests against rules, and reports every rule which
+// is broken at once, rather than only the first one.
+//
+// Sample usage
+//
+//	v := validation.Validator{}
+//	v.Field("title", r.Title, validation.Required, validation.MaxLength(30))
+//	v.Field("priority", r.Priority, validation.Required, validation.Range(1, 5))
+//	return v.Err()
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldError is a rule broken by a field of a request
+type FieldError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// Errors are all the rules broken by a request. Validator.Err returns them as an error.
+type Errors []FieldError
+
+// Error lists every broken rule, along with its field
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fe := range e {
+		messages = append(messages, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Rule is a named check of a value. Check returns why the value breaks the rule, or an empty
+// string if it does not.
+type Rule struct {
+	Name  string
+	Check func(value interface{}) string
+}
+
+// Validator collects the broken rules of the fields of a request. The zero value is ready to use.
+type Validator struct {
+	errs Errors
+}
+
+// Field checks value against rules in order, and records the first rule it breaks. An empty
+// value passes every rule except Required, so that rules of optional fields only apply to the
+// fields which are present. Field returns whether value passed all the rules.
+func (v *Validator) Field(field string, value interface{}, rules ...Rule) bool {
+	empty := isEmpty(value)
+	for _, rule := range rules {
+		if empty && rule.Name != Required.Name {
+			continue
+		}
+		if message := rule.Check(value); message != "" {
+			v.errs = append(v.errs, FieldError{Field: field, Rule: rule.Name, Message: message})
+			return false
+		}
+	}
+	return true
+}
+
+// Add records a broken rule, for checks which are not worth a Rule of their own
+func (v *Validator) Add(field, rule, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+// Merge records the broken rules of a nested request, with prefix added to their fields. Errors
+// other than Errors are recorded as a broken rule of prefix itself.
+func (v *Validator) Merge(prefix string, err error) {
+	if err == nil {
+		return
+	}
+	errs, ok := err.(Errors)
+	if !ok {
+		v.Add(prefix, "valid", "%s", err.Error())
+		return
+	}
+	for _, fe := range errs {
+		fe.Field = prefix + "." + fe.Field
+		v.errs = append(v.errs, fe)
+	}
+}
+
+// Valid tells if no rule was broken so far
+func (v *Validator) Valid() bool {
+	return len(v.errs) == 0
+}
+
+// Err returns the broken rules as Errors, or nil if there are none
+func (v *Validator) Err() error {
+	if v.Valid() {
+		return nil
+	}
+	return v.errs
+}
+
+// isEmpty tells if value is the zero value of its type, or an empty slice or map
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	case reflect.Ptr:
+		return rv.IsNil()
+	}
+	return reflect.DeepEqual(value, reflect.Zero(rv.Type()).Interface())
+}
@@ -0,0 +1,34 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	v := Validator{}
+	v.Field("title", "", Required, MaxLength(3))
+	v.Field("description", "", MaxLength(3))
+	v.Field("priority", uint8(7), Required, Range(1, 5))
+	v.Field("limit", "ten", Range(1, 10))
+	v.Field("order", "asc", OneOf("asc", "desc"))
+	v.Field("effort", "1h30m", Duration)
+	v.Field("rule", "x", Func("rule", func(interface{}) error { return errors.New("Bad rule") }))
+	v.Merge("operations[0]", Errors{{Field: "id", Rule: "min", Message: "Too small"}})
+
+	want := Errors{
+		{Field: "title", Rule: "required", Message: "Cannot be empty"},
+		{Field: "priority", Rule: "range", Message: "Has to be a number from 1 to 5"},
+		{Field: "limit", Rule: "range", Message: "Has to be a number from 1 to 10"},
+		{Field: "rule", Rule: "rule", Message: "Bad rule"},
+		{Field: "operations[0].id", Rule: "min", Message: "Too small"},
+	}
+	if !reflect.DeepEqual(v.Err(), want) {
+		t.Errorf("got %#v, want %#v", v.Err(), want)
+	}
+
+	if err := (&Validator{}).Err(); err != nil {
+		t.Errorf("got %v, want no error", err)
+	}
+}